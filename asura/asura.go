@@ -2,117 +2,129 @@ package asura
 
 import (
 	"bytes"
-	"context"
 	"fmt"
 	"image"
 	"image/jpeg"
 	"io"
-	"log"
-	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
+	"scrape/browser"
+	"scrape/debugbundle"
+	"scrape/logging"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"scrape/parser"
+	"scrape/webClient"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/chai2010/webp"
 	"github.com/chromedp/chromedp"
 )
 
+var logger = logging.Site("asura")
+
 // chapterImage holds URL and order
 type chapterImage struct {
 	Order int
 	URL   string
 }
 
-// download manga chapters
-func DownloadChapters(url string) {
-
-	// step zero call clean up func
-	var tempDirList []string
-	parser.CleanupTempDirs(&tempDirList)
+// DownloadChapter downloads the chapter images to its staging directory and creates the chapter cbz file
+func DownloadChapter(chapter parser.Chapter) error {
+	chapterName := chapter.Filename
+	fmt.Printf("%s\t%s\n", chapterName, chapter.URL)
 
-	// Step 1: grab the list of chapter urls from the provided link
-	chapters, chapterListError := extractChapterLinksFromURL(url)
-	if chapterListError != nil {
-		log.Fatalf("[asura - extractChapterLinksFromURL] error, %v", chapterListError)
+	chapterImages, err := sortedChapterImages(chapter.URL)
+	if err != nil {
+		return fmt.Errorf("failed to get and sort images: %w", err)
+	}
+	if len(chapterImages) == 0 {
+		return fmt.Errorf("%s: %w", chapterName, debugbundle.NoMatch(chapter.URL, "script (page image URLs)", "images"))
 	}
-	// Step 2: create the chapter map with filename as key and url as value
-	chapterMap := chapterFilenames(chapters)
 
-	// Step 3: get existing CBZ files so their download can be skipped
-	existing, err := parser.GetDownloadedCBZ(".")
+	// the staging directory is the same for the chapter on every run, and is kept until the cbz file is created
+	stage, err := parser.StageChapter(chapter.URL)
 	if err != nil {
-		log.Fatalf("Failed to read current directory: %v", err)
+		return fmt.Errorf("could not create the staging directory: %w", err)
 	}
+	logger.Debug("staging chapter", "chapter", chapter.Number, "dir", stage.Dir)
 
-	// Step 4: delete any downloaded (existing) chapters from the map
-	// (the map key is the filename and value is true or false for some reason)
-	for downloadedChapter := range existing {
-		delete(chapterMap, downloadedChapter)
+	// the pages are named by their Order, so the archive keeps the reading order
+	for _, image := range chapterImages {
+		err := stage.Page(fmt.Sprintf("%03d.jpg", image.Order), image.URL, func() error {
+			return downloadChapterImage(image, chapter.URL, stage.Dir)
+		})
+		if err != nil {
+			logger.Error("failed to download image", "chapter", chapter.Number, "page", image.Order, "url", image.URL, "error", err)
+		}
 	}
 
-	// Step 5: sort the map keys to get ordered chapter list
-	chapterList, keySortError := parser.SortKeys(chapterMap)
-	if chapterListError != nil {
-		log.Fatalf("[asura - parser.SortKeys] error, %v", keySortError)
+	// create the cbz file *(chapterName == filename)
+	if err := stage.Archive(chapterName, len(chapterImages)); err != nil {
+		return err
 	}
+	fmt.Printf("Downloaded: %s\n", chapterName)
+	return nil
+}
 
-	// Step 6: download each chapter
-	for _, chapterName := range chapterList {
-		fmt.Printf("%s\t%s\n", chapterName, chapterMap[chapterName])
-		chapterImages, sortChImgsErr := sortedChapterImages(chapterMap[chapterName])
-		if sortChImgsErr != nil {
-			log.Fatalf("[asura - sortedChapterImages] Failed to get and sort images: %v", sortChImgsErr)
-		}
-
-		// create the temp directory (chapterName and random string create the temp dir name)
-		tempDir, err := parser.CreateTempDir(chapterName)
-		if err != nil {
-			log.Printf("[asura - parser.CreateTempDir] could not create temp directory: %s", tempDir)
-		}
+// SeriesChapters returns the chapters listed on the series page, built from the same chapter map used for downloads
+func SeriesChapters(seriesURL string) ([]parser.Chapter, error) {
+	chapterMap, err := seriesChapterMap(seriesURL)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ChaptersFromMap(chapterMap), nil
+}
 
-		// append to the list of temp directories that need to be removed once the download func finishes or
-		// crashes/hard exits
-		tempDirList = append(tempDirList, tempDir)
-		log.Printf("Created tempdir: %s", tempDir)
+// ChapterImages returns the ordered page image URLs of a chapter
+func ChapterImages(chapterURL string) ([]string, error) {
+	chapterImages, err := sortedChapterImages(chapterURL)
+	if err != nil {
+		return nil, err
+	}
 
-		// download chapter images to temp directory
-		for _, image := range chapterImages {
-			if err := downloadChapterImage(image, tempDir); err != nil {
-				log.Printf("[asura - DownloadChapterImage] Error: %v", err)
-			}
-		}
+	urls := make([]string, len(chapterImages))
+	for i, img := range chapterImages {
+		urls[i] = img.URL
+	}
+	return urls, nil
+}
 
-		// create the cbz file *(chapterName == filename)
-		parser.CreateCbzFromDir(tempDir, chapterName)
-		fmt.Printf("Downloaded: %s\n", chapterName)
+// fetches the series page and creates the chapter map with filename as key and url as value
+func seriesChapterMap(seriesURL string) (map[string]string, error) {
+	chapters, err := extractChapterLinksFromURL(seriesURL)
+	if err != nil {
+		return nil, err
 	}
+	return chapterFilenames(chapters), nil
 }
 
 // Fetches the series page and returns all valid chapter URLs
 func extractChapterLinksFromURL(seriesURL string) ([]string, error) {
-	log.Printf("[asura - extractChapterLinksFromURL] Fetching series page: %s\n", seriesURL)
+	logger.Info("fetching series page", "series", seriesURL)
 
-	resp, err := http.Get(seriesURL)
+	req, err := webClient.NewRequest(webClient.PageRequest, seriesURL, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch series page: %w", err)
+	}
+	resp, err := webClient.NewHTTPClient().Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("[asura - extractChapterLinksFromURL] failed to fetch series page: %w", err)
+		return nil, fmt.Errorf("failed to fetch series page: %w", err)
 	}
 	defer resp.Body.Close()
 
-	log.Printf("[asura - extractChapterLinksFromURL] HTTP status code: %d\n", resp.StatusCode)
+	logger.Debug("series page fetched", "series", seriesURL, "status", resp.StatusCode)
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("[asura - extractChapterLinksFromURL] failed to fetch series page: status code %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to fetch series page: status code %d", resp.StatusCode)
 	}
 
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("[asura - extractChapterLinksFromURL] failed to parse HTML: %w", err)
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
 	chapterURLs := make(map[string]struct{})
@@ -122,7 +134,7 @@ func extractChapterLinksFromURL(seriesURL string) ([]string, error) {
 			return
 		}
 		href = strings.TrimSpace(href)
-		log.Printf("[asura - extractChapterLinksFromURL] Found href: %s\n", href)
+		logger.Debug("found link", "series", seriesURL, "url", href)
 
 		// Keep any link that contains "/chapter/"
 		if strings.Contains(href, "/chapter/") {
@@ -130,7 +142,7 @@ func extractChapterLinksFromURL(seriesURL string) ([]string, error) {
 			if !strings.HasPrefix(href, "http") {
 				href = "https://asuracomic.net/series/" + strings.TrimPrefix(href, "/")
 			}
-			log.Printf("[asura - extractChapterLinksFromURL] Matched chapter URL: %s\n", href)
+			logger.Debug("matched chapter URL", "series", seriesURL, "url", href)
 			chapterURLs[href] = struct{}{}
 		}
 	})
@@ -144,7 +156,7 @@ func extractChapterLinksFromURL(seriesURL string) ([]string, error) {
 		return urls[i] > urls[j] // descending
 	})
 
-	log.Printf("[asura - extractChapterLinksFromURL] Total chapters found: %d\n", len(urls))
+	logger.Info("chapters found", "series", seriesURL, "chapters", len(urls))
 	return urls, nil
 }
 
@@ -189,60 +201,46 @@ func chapterFilenames(urls []string) map[string]string {
 
 // Fetches all image URLs from a chapter page (unsorted)
 func rawChapterImageUrls(chapterURL string) ([]string, error) {
-	log.Printf("[asura - rawChapterImageUrls] Starting fetch for: %s", chapterURL)
-
-	ctx, cancel := chromedp.NewContext(context.Background())
-	defer cancel()
+	logger.Info("fetching chapter page", "url", chapterURL)
 
 	var html string
 
 	// --- Navigation ---
 	startNav := time.Now()
-	if err := chromedp.Run(ctx,
+	if err := browser.Run(0,
 		chromedp.Navigate(chapterURL),
 		chromedp.WaitReady("body"),
 		chromedp.OuterHTML("html", &html),
 	); err != nil {
-		return nil, fmt.Errorf("[asura - rawChapterImageUrls] navigation failed: %w", err)
+		return nil, fmt.Errorf("navigation failed: %w", err)
 	}
 	elapsedNav := time.Since(startNav)
-	log.Printf("[asura - rawChapterImageUrls] Navigation complete in %s. HTML length: %d", elapsedNav, len(html))
+	logger.Debug("navigation complete", "url", chapterURL, "elapsed", elapsedNav, "html_length", len(html))
 
 	// --- HTML Parsing ---
 	startParse := time.Now()
-	tmpFile, err := os.CreateTemp("/tmp", "asura-chapter-*.html")
-	if err == nil {
-		tmpFile.WriteString(html)
-		tmpFile.Close()
-		defer os.Remove(tmpFile.Name())
-		log.Printf("[asura - rawChapterImageUrls] Saved page to %s for inspection", tmpFile.Name())
-	}
 	scripts := extractScriptsFromHTML(html)
 	elapsedParse := time.Since(startParse)
-	log.Printf("[asura - rawChapterImageUrls] HTML parsing complete in %s. Total <script> tags found: %d", elapsedParse, len(scripts))
+	logger.Debug("HTML parsing complete", "url", chapterURL, "elapsed", elapsedParse, "scripts", len(scripts))
 
 	// --- Extraction & Download Timing ---
 	startExtract := time.Now()
+	urls := scriptImageURLs(scripts)
+	elapsedExtract := time.Since(startExtract)
+	logger.Debug("image URL extraction complete", "url", chapterURL, "elapsed", elapsedExtract, "images", len(urls))
+
+	return urls, nil
+}
+
+// Returns the chapter image URLs found in the script blocks of the chapter page (unsorted)
+func scriptImageURLs(scripts []string) []string {
 	var urls []string
 	for i, script := range scripts {
 		matches := extractImageURLsFromScript(script)
-		log.Printf("[asura - rawChapterImageUrls] Script %d matches found: %d", i, len(matches))
+		logger.Debug("script image URLs", "script", i, "images", len(matches))
 		urls = append(urls, matches...)
 	}
-	elapsedExtract := time.Since(startExtract)
-	log.Printf("[asura - rawChapterImageUrls] Extraction complete in %s. Total raw image URLs extracted: %d", elapsedExtract, len(urls))
-
-	// --- Optional: measure total download time for all images ---
-	startDownload := time.Now()
-	for _, url := range urls {
-		if _, err := http.Get(url); err != nil {
-			log.Printf("[asura - rawChapterImageUrls] Warning: failed to download %s: %v", url, err)
-		}
-	}
-	elapsedDownload := time.Since(startDownload)
-	log.Printf("[asura - rawChapterImageUrls] Total download time for all images: %s", elapsedDownload)
-
-	return urls, nil
+	return urls
 }
 
 // filterImageURLs filters URLs to only those with filenames like xx-optimized.webp
@@ -254,11 +252,11 @@ func filterImageURLs(urls []string) []string {
 		if re.MatchString(u) {
 			filtered = append(filtered, u)
 		} else {
-			log.Printf("[asura - filterImageURLs] Ignored URL (does not match pattern): %s", u)
+			logger.Debug("ignored image URL, does not match the page pattern", "url", u)
 		}
 	}
 
-	log.Printf("[asura - filterImageURLs] Filtered image URLs count: %d", len(filtered))
+	logger.Debug("filtered image URLs", "images", len(filtered))
 	return filtered
 }
 
@@ -277,13 +275,13 @@ func buildChapterImages(urls []string) []chapterImage {
 		if len(m) == 2 {
 			num, err := strconv.Atoi(m[1])
 			if err != nil {
-				log.Printf("[asura - buildChapterImages] Failed to parse number from URL %s: %v", u, err)
+				logger.Warn("failed to parse the page number", "url", u, "error", err)
 				continue
 			}
 			tmpList = append(tmpList, temp{order: num, url: u})
-			log.Printf("[asura - buildChapterImages] Added URL %s with order %d", u, num)
+			logger.Debug("added image", "url", u, "order", num)
 		} else {
-			log.Printf("[asura - buildChapterImages] Skipping URL (regex did not match): %s", u)
+			logger.Debug("skipping image URL, no page number", "url", u)
 		}
 	}
 
@@ -299,10 +297,10 @@ func buildChapterImages(urls []string) []chapterImage {
 			Order: i + 1,
 			URL:   t.url,
 		}
-		log.Printf("[asura - buildChapterImages] Final ChapterImage [%d]: %s", images[i].Order, images[i].URL)
+		logger.Debug("chapter image", "page", images[i].Order, "url", images[i].URL)
 	}
 
-	log.Printf("[asura - buildChapterImages] Total chapter images built: %d", len(images))
+	logger.Debug("chapter images built", "images", len(images))
 	return images
 }
 
@@ -317,26 +315,26 @@ func sortedChapterImages(chapterURL string) ([]chapterImage, error) {
 	deduped := deduplicateURLs(filtered)
 	chapterImages := buildChapterImages(deduped)
 
-	log.Printf("[asura - sortedChapterImages] Total sorted chapter images after deduplication: %d", len(chapterImages))
+	logger.Info("chapter images found", "url", chapterURL, "images", len(chapterImages))
 	return chapterImages, nil
 }
 
 // deduplicateURLs removes duplicate URLs from a slice while preserving order
 func deduplicateURLs(urls []string) []string {
-	log.Printf("[asura - deduplicateURLs] Starting deduplication. Total URLs: %d", len(urls))
+	logger.Debug("deduplicating image URLs", "images", len(urls))
 	seen := make(map[string]struct{})
 	var deduped []string
 
 	for _, u := range urls {
 		if _, ok := seen[u]; ok {
-			log.Printf("[asura - deduplicateURLs] Skipping duplicate URL: %s", u)
+			logger.Debug("skipping duplicate image URL", "url", u)
 			continue
 		}
 		seen[u] = struct{}{}
 		deduped = append(deduped, u)
 	}
 
-	log.Printf("[asura - deduplicateURLs] Deduplication complete. Remaining URLs: %d", len(deduped))
+	logger.Debug("deduplication complete", "images", len(deduped))
 	return deduped
 }
 
@@ -367,10 +365,10 @@ func SortChapterImages(chapterImages []chapterImage) {
 }
 */
 
-// Downloads and converts a chapterImage (with Order + URL)
+// Downloads and converts a chapterImage (with Order + URL) shown on chapterURL
 // into a JPG file saved under targetDir, naming files sequentially using Order.
-func downloadChapterImage(img chapterImage, targetDir string) error {
-	resp, err := http.Get(img.URL)
+func downloadChapterImage(img chapterImage, chapterURL, targetDir string) error {
+	resp, err := webClient.GetImage(img.URL, chapterURL)
 	if err != nil {
 		return fmt.Errorf("failed to download image (Order %d): %w", img.Order, err)
 	}
@@ -400,7 +398,7 @@ func downloadChapterImage(img chapterImage, targetDir string) error {
 		if err != nil {
 			return fmt.Errorf("failed to save jpeg image for %s: %w", img.URL, err)
 		}
-		log.Printf("[asura - DownloadChapterImage] Saved JPEG directly: %s", outputFile)
+		logger.Debug("saved JPEG", "page", img.Order, "file", outputFile)
 		return nil
 	}
 
@@ -428,12 +426,53 @@ func downloadChapterImage(img chapterImage, targetDir string) error {
 	}
 	defer outFile.Close()
 
-	opts := jpeg.Options{Quality: 90}
+	opts := jpeg.Options{Quality: parser.JPEGQuality()}
 	err = jpeg.Encode(outFile, decoded, &opts)
 	if err != nil {
 		return fmt.Errorf("failed to encode jpeg for %s: %w", img.URL, err)
 	}
 
-	log.Printf("[asura - DownloadChapterImage] Wrote %s (Order %d)", outputFile, img.Order)
+	logger.Debug("converted image to JPEG", "page", img.Order, "file", outputFile)
 	return nil
 }
+
+// SeriesInfo scrapes the series metadata (title, authors, genres, cover etc) from an asura series page
+func SeriesInfo(seriesURL string) (*parser.SeriesInfo, error) {
+	doc, err := webClient.FetchDocument(seriesURL)
+	if err != nil {
+		return nil, err
+	}
+
+	info := parser.OpenGraphSeriesInfo(doc, seriesURL)
+
+	if title := parser.CleanText(doc.Find("span.text-xl.font-bold").First().Text()); title != "" {
+		info.Title = title
+	}
+	if cover := doc.Find(`img[alt="poster"]`).First().AttrOr("src", ""); cover != "" {
+		info.CoverURL = parser.ResolveURL(seriesURL, cover)
+	}
+	if synopsis := parser.CleanText(doc.Find("span.font-medium.text-sm").First().Text()); synopsis != "" {
+		info.Synopsis = synopsis
+	}
+
+	// the details grid is a list of <h3>Label</h3><h3>Value</h3> pairs
+	doc.Find("h3").Each(func(_ int, s *goquery.Selection) {
+		value := parser.CleanText(s.Next().Text())
+		switch parser.CleanText(s.Text()) {
+		case "Status":
+			info.Status = value
+		case "Author":
+			info.Authors = parser.SplitList(value)
+		case "Artist":
+			info.Artists = parser.SplitList(value)
+		case "Genres":
+			s.Parent().Find("button").Each(func(_ int, b *goquery.Selection) {
+				if genre := parser.CleanText(b.Text()); genre != "" {
+					info.Genres = append(info.Genres, genre)
+				}
+			})
+		}
+	})
+
+	return info, nil
+}
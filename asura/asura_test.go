@@ -0,0 +1,66 @@
+package asura
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"scrape/replay/replaytest"
+	"testing"
+)
+
+const seriesURL = "https://asuracomic.net/series/nano-machine-4b3d9cdc"
+
+func TestSeriesChapters(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	chapters, err := SeriesChapters(seriesURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// relative chapter links are resolved against the series path, sub chapters use a dot
+	want := []struct{ number, filename, url string }{
+		{"1", "ch001.cbz", seriesURL + "/chapter/1"},
+		{"2", "ch002.cbz", seriesURL + "/chapter/2"},
+		{"54.4", "ch054.4.cbz", seriesURL + "/chapter/54-4"},
+	}
+	if len(chapters) != len(want) {
+		t.Fatalf("SeriesChapters() returned %d chapters, expected %d: %+v", len(chapters), len(want), chapters)
+	}
+	for i, ch := range chapters {
+		if ch.Number != want[i].number || ch.Filename != want[i].filename || ch.URL != want[i].url {
+			t.Errorf("chapter %d = %+v, expected %+v", i, ch, want[i])
+		}
+	}
+}
+
+// the chapter page is loaded in the browser, its image URLs are extracted from the scripts of the recorded page
+func TestChapterPageImages(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	resp, err := http.Get(seriesURL + "/chapter/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := scriptImageURLs(extractScriptsFromHTML(string(page)))
+	images := buildChapterImages(deduplicateURLs(filterImageURLs(raw)))
+
+	want := []string{
+		"https://gg.asuracomic.net/storage/media/100/conversions/01-optimized.webp",
+		"https://gg.asuracomic.net/storage/media/101/conversions/02-optimized.webp",
+	}
+	if len(images) != len(want) {
+		t.Fatalf("found %d chapter images, expected %d: %+v", len(images), len(want), images)
+	}
+	for i, img := range images {
+		if img.Order != i+1 || img.URL != want[i] {
+			t.Errorf("image %d = %+v, expected order %d %s", i, img, i+1, want[i])
+		}
+	}
+}
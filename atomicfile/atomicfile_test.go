@@ -0,0 +1,56 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFile(path, []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new" {
+		t.Errorf("%s holds %q (%v), expected %q", path, data, err, "new")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("%s mode = %v, expected 0600", path, mode)
+	}
+}
+
+func TestWriteFailureKeepsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ch001.cbz")
+	if err := os.WriteFile(path, []byte("complete"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := errors.New("interrupted")
+	err := Write(path, 0644, func(w io.Writer) error {
+		w.Write([]byte("half"))
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("Write() error = %v, expected %v", err, failed)
+	}
+
+	// the file is untouched and no temporary file is left behind
+	if data, _ := os.ReadFile(path); string(data) != "complete" {
+		t.Errorf("%s holds %q after a failed write", path, data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files in %s after a failed write, expected 1", len(entries), dir)
+	}
+}
@@ -0,0 +1,22 @@
+// login flows for the sites that gate chapters behind an account: a form POST or a scripted browser login, both
+// leaving the session cookies in the shared cookie jar
+package auth
+
+import "errors"
+
+// Credentials are the username and password of a site account
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Flow logs in to a site
+type Flow interface {
+	// URL is the login page, a redirect to it shows the session has expired
+	URL() string
+	// Login signs in with creds, the session cookies are stored in the shared cookie jar
+	Login(creds Credentials) error
+}
+
+// ErrRejected is returned when the site shows the login form again after the credentials were submitted
+var ErrRejected = errors.New("the site rejected the login, check the username and password")
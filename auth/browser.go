@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"scrape/browser"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// BrowserLogin logs in by filling in the login page in a browser tab, for the sites whose login form needs scripts
+// (eg: a token computed by the page). The tab's cookies are saved to the shared cookie jar when it closes.
+type BrowserLogin struct {
+	// LoginURL is the login page
+	LoginURL string
+	// Username, Password and Submit select the credential inputs and the submit button
+	Username string
+	Password string
+	Submit   string
+	// LoggedIn selects an element only shown once logged in, eg: the account menu
+	LoggedIn string
+	// Timeout bounds the whole login, 1 minute when 0
+	Timeout time.Duration
+}
+
+func (b *BrowserLogin) URL() string {
+	return b.LoginURL
+}
+
+// Login types creds into the login page, submits it and waits for the LoggedIn element
+func (b *BrowserLogin) Login(creds Credentials) error {
+	timeout := b.Timeout
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	ctx, cancel, err := browser.NewTab(timeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	err = chromedp.Run(ctx,
+		chromedp.Navigate(b.LoginURL),
+		chromedp.WaitVisible(b.Username, chromedp.ByQuery),
+		chromedp.SendKeys(b.Username, creds.Username, chromedp.ByQuery),
+		chromedp.SendKeys(b.Password, creds.Password, chromedp.ByQuery),
+		chromedp.Click(b.Submit, chromedp.ByQuery),
+		chromedp.WaitVisible(b.LoggedIn, chromedp.ByQuery),
+	)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %s did not show %q after logging in", ErrRejected, b.LoginURL, b.LoggedIn)
+	}
+	if err != nil {
+		return fmt.Errorf("browser login at %s failed: %w", b.LoginURL, err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"fmt"
+	"scrape/debugbundle"
+	"scrape/webClient"
+
+	"github.com/gocolly/colly"
+)
+
+// FormLogin logs in by POSTing the site's login form with colly
+type FormLogin struct {
+	// LoginURL is the page with the login form
+	LoginURL string
+	// Form selects the login form, the values of its inputs (eg: a CSRF token) are sent with the credentials
+	Form string
+	// UsernameField and PasswordField are the names of the credential inputs
+	UsernameField string
+	PasswordField string
+	// Fields are set on the form before it is sent, eg: {"remember": "1"}
+	Fields map[string]string
+}
+
+func (f *FormLogin) URL() string {
+	return f.LoginURL
+}
+
+// Login fetches the login form, fills in creds and submits it to the form's action. The login is rejected when the
+// response shows the form again.
+func (f *FormLogin) Login(creds Credentials) error {
+	action, data, err := f.form()
+	if err != nil {
+		return err
+	}
+	for name, value := range f.Fields {
+		data[name] = value
+	}
+	data[f.UsernameField] = creds.Username
+	data[f.PasswordField] = creds.Password
+
+	c := webClient.NewCollector()
+	rejected := false
+	c.OnHTML(f.Form, func(e *colly.HTMLElement) {
+		rejected = true
+	})
+	if err := c.Post(action, data); err != nil {
+		return fmt.Errorf("login to %s failed: %w", action, err)
+	}
+	c.Wait()
+
+	if rejected {
+		return ErrRejected
+	}
+	return nil
+}
+
+// form returns the action of the login form and the values of its inputs
+func (f *FormLogin) form() (string, map[string]string, error) {
+	var action string
+	data := make(map[string]string)
+	found := false
+
+	c := webClient.NewCollector()
+	c.OnHTML(f.Form, func(e *colly.HTMLElement) {
+		if found {
+			return
+		}
+		found = true
+
+		// a form without an action is sent to its own page
+		action = e.Request.URL.String()
+		if a := e.Attr("action"); a != "" {
+			action = e.Request.AbsoluteURL(a)
+		}
+		e.ForEach("input[name]", func(_ int, input *colly.HTMLElement) {
+			switch input.Attr("type") {
+			case "checkbox", "radio":
+				if _, checked := input.DOM.Attr("checked"); !checked {
+					return
+				}
+			case "submit", "button", "image":
+				return
+			}
+			data[input.Attr("name")] = input.Attr("value")
+		})
+	})
+
+	if err := c.Visit(f.LoginURL); err != nil {
+		return "", nil, fmt.Errorf("failed to fetch the login page %s: %w", f.LoginURL, err)
+	}
+	c.Wait()
+
+	if !found {
+		return "", nil, debugbundle.NoMatch(f.LoginURL, f.Form, "login form")
+	}
+	return action, data, nil
+}
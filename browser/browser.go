@@ -0,0 +1,284 @@
+// shared headless Chrome for the sites that need a browser: one browser process per run, with a bounded pool of tabs
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"scrape/debugbundle"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+)
+
+// Options are applied to the shared browser and every tab opened in it
+type Options struct {
+	// RemoteURL is the DevTools URL of an already running browser to connect to instead of launching one, either
+	// ws://host:port/ or http://host:port/
+	RemoteURL string
+	// ExecPath is the Chrome / Chromium binary to launch, found on the PATH when empty
+	ExecPath  string
+	UserAgent string
+	Headless  bool
+	// Width and Height are the browser window (viewport) size
+	Width  int
+	Height int
+	// MaxTabs bounds the number of tabs open at the same time, NewTab blocks until a tab is free
+	MaxTabs int
+	// Timeout is the default timeout of a tab, used when NewTab is given no timeout
+	Timeout time.Duration
+	// CaptureImages makes the sites that support it package the page images from the browser's network traffic
+	// instead of downloading them again
+	CaptureImages bool
+	// ScrollSites are the sites whose chapter pages are scrolled until no more lazy loaded images appear, see ScrollFor
+	ScrollSites []string
+	// Cookies is the jar every tab starts with the cookies of, and hands the browser's cookies back to when closed
+	Cookies CookieJar
+	// ProxyServer is the --proxy-server every request of a launched browser goes through, see ProxyServer
+	ProxyServer string
+	// ProxyPAC is a proxy auto-config script choosing the proxy by host, see ProxyPAC. Used when ProxyServer is empty.
+	ProxyPAC string
+}
+
+// DefaultOptions are the options used unless Configure is called before the first tab is opened
+var DefaultOptions = Options{
+	UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/115.0.0.0 Safari/537.36",
+	Headless:  true,
+	Width:     1280,
+	Height:    1800,
+	MaxTabs:   4,
+	Timeout:   60 * time.Second,
+
+	CaptureImages: true,
+	ScrollSites:   []string{"orv", "stonescape"},
+}
+
+// ErrShutdown is returned by NewTab once the browser has been shut down
+var ErrShutdown = errors.New("browser has been shut down")
+
+var (
+	mu       sync.Mutex
+	options  = DefaultOptions
+	shutdown bool
+	startErr error
+
+	// the browser is started on the first NewTab
+	cancelAlloc   context.CancelFunc
+	browserCtx    context.Context
+	cancelBrowser context.CancelFunc
+	tabs          chan struct{}
+	// tabSetup runs in every new tab before it is handed out
+	tabSetup []chromedp.Action
+)
+
+// Configure sets the browser options. It only has an effect before the browser is started by the first NewTab.
+func Configure(opts Options) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if browserCtx != nil {
+		slog.Warn("browser already started, options ignored")
+		return
+	}
+	options = opts
+}
+
+// start launches the shared browser, mu must be held
+func start() error {
+	if shutdown {
+		return ErrShutdown
+	}
+	if browserCtx != nil {
+		return nil
+	}
+	// a browser that failed to start is not retried
+	if startErr != nil {
+		return startErr
+	}
+
+	var allocCtx context.Context
+	var allocCancel context.CancelFunc
+	if options.RemoteURL != "" {
+		if options.ProxyServer != "" || options.ProxyPAC != "" {
+			slog.Warn("the proxy settings do not apply to a running browser, start it with --proxy-server", "url", options.RemoteURL)
+		}
+		allocCtx, allocCancel = chromedp.NewRemoteAllocator(context.Background(), options.RemoteURL)
+	} else {
+		allocOpts := append(chromedp.DefaultExecAllocatorOptions[:],
+			chromedp.UserAgent(options.UserAgent),
+			chromedp.Flag("headless", options.Headless),
+			chromedp.Flag("disable-blink-features", "AutomationControlled"),
+			chromedp.Flag("disable-gpu", true),
+			chromedp.WindowSize(options.Width, options.Height),
+		)
+		if options.ExecPath != "" {
+			allocOpts = append(allocOpts, chromedp.ExecPath(options.ExecPath))
+		}
+		if options.ProxyServer != "" {
+			allocOpts = append(allocOpts, chromedp.ProxyServer(options.ProxyServer))
+		} else if options.ProxyPAC != "" {
+			allocOpts = append(allocOpts, chromedp.Flag("proxy-pac-url", pacURL(options.ProxyPAC)))
+		}
+		allocCtx, allocCancel = chromedp.NewExecAllocator(context.Background(), allocOpts...)
+	}
+	ctx, cancel := chromedp.NewContext(allocCtx)
+
+	// an empty Run starts (or connects to) the browser with its first tab, every NewTab opens a new tab in it
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		allocCancel()
+		startErr = startError(err)
+		return startErr
+	}
+
+	// a remote browser has its own user agent and window size, apply the shared ones to every tab instead
+	if options.RemoteURL != "" {
+		tabSetup = []chromedp.Action{
+			emulation.SetUserAgentOverride(options.UserAgent),
+			emulation.SetDeviceMetricsOverride(int64(options.Width), int64(options.Height), 1, false),
+		}
+	}
+
+	maxTabs := options.MaxTabs
+	if maxTabs < 1 {
+		maxTabs = 1
+	}
+
+	cancelAlloc, browserCtx, cancelBrowser = allocCancel, ctx, cancel
+	tabs = make(chan struct{}, maxTabs)
+	if options.RemoteURL != "" {
+		slog.Info("connected to the browser", "url", options.RemoteURL, "tabs", maxTabs)
+	} else {
+		slog.Info("browser started", "tabs", maxTabs)
+	}
+	return nil
+}
+
+// startError explains how to make a browser available when it cannot be started
+func startError(err error) error {
+	if options.RemoteURL != "" {
+		return fmt.Errorf("cannot connect to the browser at %s: %w", options.RemoteURL, err)
+	}
+	if options.ExecPath != "" {
+		return fmt.Errorf("cannot start the browser %s: %w", options.ExecPath, err)
+	}
+	return fmt.Errorf("no Chrome/Chromium browser found (%w), install one, pass its location with --chrome-path "+
+		"or connect to a running browser with --chrome-url", err)
+}
+
+// CaptureEnabled reports whether page images should be captured from the browser's network traffic.
+func CaptureEnabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return options.CaptureImages
+}
+
+// Check is the capability probe for the sources that need a browser: it starts the shared browser (or connects to
+// the remote one) if that has not happened yet and reports why it is unavailable.
+func Check() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return start()
+}
+
+// NewTab opens a tab in the shared browser, starting the browser if needed. The returned context is bounded by
+// timeout (or the default Timeout when 0), the cancel func closes the tab and must always be called. Blocks while
+// MaxTabs tabs are open.
+func NewTab(timeout time.Duration) (context.Context, context.CancelFunc, error) {
+	mu.Lock()
+	err := start()
+	parent, slots, setup, jar := browserCtx, tabs, tabSetup, options.Cookies
+	if timeout <= 0 {
+		timeout = options.Timeout
+	}
+	mu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	select {
+	case slots <- struct{}{}:
+	case <-parent.Done():
+		return nil, nil, ErrShutdown
+	}
+
+	tabCtx, cancelTab := chromedp.NewContext(parent)
+	ctx, cancelTimeout := context.WithTimeout(tabCtx, timeout)
+
+	// with --debug-dir every tab is snapshotted before it is closed, in case its chapter fails
+	var recorder *tabRecorder
+	if debugbundle.Enabled() {
+		if recorder, err = recordTab(ctx); err != nil {
+			slog.Warn("browser debug recording unavailable", "error", err)
+		}
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			// the screenshot costs a round trip and its memory, it is only taken while the bundles are still enabled
+			if recorder != nil && debugbundle.Enabled() {
+				recorder.snapshot(tabCtx)
+			}
+			if jar != nil {
+				saveCookies(tabCtx, jar)
+			}
+			cancelTimeout()
+			cancelTab()
+			<-slots
+		})
+	}
+
+	if len(setup) > 0 {
+		if err := chromedp.Run(ctx, setup...); err != nil {
+			release()
+			return nil, nil, err
+		}
+	}
+	if jar != nil {
+		if err := setCookies(ctx, jar); err != nil {
+			release()
+			return nil, nil, err
+		}
+	}
+	return ctx, release, nil
+}
+
+// Run runs the actions in a new tab bounded by timeout (or the default Timeout when 0), closing the tab afterwards.
+func Run(timeout time.Duration, actions ...chromedp.Action) error {
+	ctx, cancel, err := NewTab(timeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	return chromedp.Run(ctx, actions...)
+}
+
+// Shutdown closes the shared browser gracefully, waiting up to 10 seconds for Chrome to exit. Safe to call when the
+// browser was never started and more than once.
+func Shutdown() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	shutdown = true
+	if browserCtx == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(browserCtx, 10*time.Second)
+	if err := chromedp.Cancel(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Warn("error closing the browser", "error", err)
+	}
+	cancel()
+	cancelBrowser()
+	cancelAlloc()
+
+	browserCtx = nil
+	slog.Info("browser shut down")
+}
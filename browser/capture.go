@@ -0,0 +1,92 @@
+package browser
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// buffer sizes for the response bodies Chrome keeps for GetResponseBody, large enough for a chapter of full size
+// page images
+const (
+	maxTotalBufferSize    = 512 << 20
+	maxResourceBufferSize = 32 << 20
+)
+
+// ImageCapture collects the image responses a tab loads, so the page images can be packaged from the bytes the
+// browser already downloaded (with the site's cookies and referer) instead of being downloaded again.
+type ImageCapture struct {
+	match func(url string) bool
+
+	mu       sync.Mutex
+	urls     map[network.RequestID]string
+	finished []network.RequestID
+}
+
+// CaptureImages enables the network domain in the tab and starts recording the image responses whose URL is
+// accepted by match (every image when match is nil). Call it before navigating.
+func CaptureImages(ctx context.Context, match func(url string) bool) (*ImageCapture, error) {
+	c := &ImageCapture{match: match, urls: make(map[network.RequestID]string)}
+
+	enable := network.Enable().
+		WithMaxTotalBufferSize(maxTotalBufferSize).
+		WithMaxResourceBufferSize(maxResourceBufferSize)
+	if err := chromedp.Run(ctx, enable); err != nil {
+		return nil, err
+	}
+
+	chromedp.ListenTarget(ctx, func(ev any) {
+		switch ev := ev.(type) {
+		case *network.EventResponseReceived:
+			if ev.Type != network.ResourceTypeImage || ev.Response.Status != 200 {
+				return
+			}
+			if c.match != nil && !c.match(ev.Response.URL) {
+				return
+			}
+			c.mu.Lock()
+			c.urls[ev.RequestID] = ev.Response.URL
+			c.mu.Unlock()
+		case *network.EventLoadingFinished:
+			c.mu.Lock()
+			if _, ok := c.urls[ev.RequestID]; ok {
+				c.finished = append(c.finished, ev.RequestID)
+			}
+			c.mu.Unlock()
+		}
+	})
+
+	return c, nil
+}
+
+// Images returns the bodies of the captured images that finished loading, by URL. Must be called before the tab is
+// closed. Images whose body is no longer available are left out, they can still be downloaded by URL.
+func (c *ImageCapture) Images(ctx context.Context) map[string][]byte {
+	c.mu.Lock()
+	finished := append([]network.RequestID(nil), c.finished...)
+	urls := make(map[network.RequestID]string, len(c.urls))
+	for id, url := range c.urls {
+		urls[id] = url
+	}
+	c.mu.Unlock()
+
+	images := make(map[string][]byte, len(finished))
+	for _, id := range finished {
+		var body []byte
+		err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			body, err = network.GetResponseBody(id).Do(ctx)
+			return err
+		}))
+		if err != nil {
+			slog.Warn("no captured body for image", "url", urls[id], "error", err)
+			continue
+		}
+		images[urls[id]] = body
+	}
+
+	return images
+}
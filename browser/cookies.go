@@ -0,0 +1,73 @@
+package browser
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/chromedp"
+)
+
+// CookieJar is the cookie jar the browser shares with the HTTP clients, eg: webClient.Jar
+type CookieJar interface {
+	// All returns every cookie with its attributes, a Domain with a leading dot is sent to subdomains too
+	All() []*http.Cookie
+	// Add stores cookies that carry their own attributes
+	Add(cookies ...*http.Cookie)
+}
+
+// setCookies copies the cookies of the jar into the browser, before a tab visits its first page
+func setCookies(ctx context.Context, jar CookieJar) error {
+	var params []*network.CookieParam
+	for _, c := range jar.All() {
+		param := &network.CookieParam{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HttpOnly,
+		}
+		if !c.Expires.IsZero() {
+			expires := cdp.TimeSinceEpoch(c.Expires)
+			param.Expires = &expires
+		}
+		params = append(params, param)
+	}
+	if len(params) == 0 {
+		return nil
+	}
+	return chromedp.Run(ctx, network.SetCookies(params))
+}
+
+// saveCookies copies the browser's cookies back into the jar when a tab is closed, so the cookies the sites set in
+// the browser (eg: a passed challenge) are used by the HTTP clients and kept for the next run
+func saveCookies(ctx context.Context, jar CookieJar) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var browserCookies []*network.Cookie
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		browserCookies, err = storage.GetCookies().Do(ctx)
+		return err
+	}))
+	if err != nil {
+		slog.Debug("failed to read the browser cookies", "error", err)
+		return
+	}
+
+	cookies := make([]*http.Cookie, 0, len(browserCookies))
+	for _, c := range browserCookies {
+		cookie := &http.Cookie{Name: c.Name, Value: c.Value, Domain: c.Domain, Path: c.Path, Secure: c.Secure, HttpOnly: c.HTTPOnly}
+		if !c.Session && c.Expires > 0 {
+			cookie.Expires = time.Unix(int64(c.Expires), 0)
+		}
+		cookies = append(cookies, cookie)
+	}
+	jar.Add(cookies...)
+}
@@ -0,0 +1,108 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"scrape/debugbundle"
+	"strings"
+	"sync"
+	"time"
+
+	cdplog "github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// tabRecorder collects the console output and request / response headers of a tab for the failure bundles
+type tabRecorder struct {
+	mu        sync.Mutex
+	console   []string
+	exchanges map[network.RequestID]*debugbundle.Exchange
+	order     []network.RequestID
+}
+
+// recordTab starts recording the console output and headers of a tab
+func recordTab(ctx context.Context) (*tabRecorder, error) {
+	r := &tabRecorder{exchanges: make(map[network.RequestID]*debugbundle.Exchange)}
+
+	if err := chromedp.Run(ctx, runtime.Enable(), cdplog.Enable(), network.Enable()); err != nil {
+		return nil, err
+	}
+
+	chromedp.ListenTarget(ctx, func(ev any) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		switch ev := ev.(type) {
+		case *runtime.EventConsoleAPICalled:
+			var args []string
+			for _, arg := range ev.Args {
+				if arg.Value != nil {
+					var value any
+					if json.Unmarshal(arg.Value, &value) == nil {
+						args = append(args, fmt.Sprint(value))
+						continue
+					}
+				}
+				args = append(args, arg.Description)
+			}
+			r.console = append(r.console, fmt.Sprintf("[console.%s] %s", ev.Type, strings.Join(args, " ")))
+		case *runtime.EventExceptionThrown:
+			r.console = append(r.console, "[exception] "+ev.ExceptionDetails.Error())
+		case *cdplog.EventEntryAdded:
+			r.console = append(r.console, fmt.Sprintf("[%s %s] %s %s", ev.Entry.Source, ev.Entry.Level, ev.Entry.Text, ev.Entry.URL))
+		case *network.EventRequestWillBeSent:
+			r.order = append(r.order, ev.RequestID)
+			r.exchanges[ev.RequestID] = &debugbundle.Exchange{
+				Method:         ev.Request.Method,
+				URL:            ev.Request.URL,
+				RequestHeaders: headerValues(ev.Request.Headers),
+			}
+		case *network.EventResponseReceived:
+			if e, ok := r.exchanges[ev.RequestID]; ok {
+				e.Status = int(ev.Response.Status)
+				e.ResponseHeaders = headerValues(ev.Response.Headers)
+			}
+		}
+	})
+
+	return r, nil
+}
+
+// snapshot records the tab's page, screenshot, console output and headers for the failure bundles. ctx must be the
+// tab context before its timeout is applied, so a tab that timed out can still be captured.
+func (r *tabRecorder) snapshot(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	page := &debugbundle.Page{}
+	err := chromedp.Run(ctx,
+		chromedp.Location(&page.URL),
+		chromedp.OuterHTML("html", &page.HTML, chromedp.ByQuery),
+		chromedp.FullScreenshot(&page.Screenshot, 80),
+	)
+	if err != nil {
+		slog.Warn("browser debug snapshot incomplete", "url", page.URL, "error", err)
+	}
+
+	r.mu.Lock()
+	page.Console = append(page.Console, r.console...)
+	for _, id := range r.order {
+		page.Exchanges = append(page.Exchanges, *r.exchanges[id])
+	}
+	r.mu.Unlock()
+
+	debugbundle.Record(page)
+}
+
+// headerValues converts DevTools headers to strings
+func headerValues(h network.Headers) map[string]string {
+	values := make(map[string]string, len(h))
+	for name, value := range h {
+		values[name] = fmt.Sprint(value)
+	}
+	return values
+}
@@ -0,0 +1,87 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"scrape/webClient"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// challengeTitles are the titles of the anti-bot interstitials, lower case
+var challengeTitles = []string{"just a moment...", "attention required! | cloudflare", "please wait...", "ddos-guard"}
+
+// Fetcher fetches pages in a browser tab, for the pages plain HTTP only gets an anti-bot challenge for. It waits for
+// the challenge to clear; the tab's cookies (eg: cf_clearance) are saved to the shared cookie jar when it closes, and
+// the host's plain HTTP requests send the browser's User-Agent from then on so the clearance is accepted.
+type Fetcher struct {
+	// Timeout bounds the page load and the challenge, the default Timeout when 0
+	Timeout time.Duration
+}
+
+func (f Fetcher) Fetch(pageURL string) (*webClient.Page, error) {
+	ctx, cancel, err := NewTab(f.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	var html, location, agent string
+	err = chromedp.Run(ctx,
+		chromedp.Navigate(pageURL),
+		waitChallenge(),
+		chromedp.Location(&location),
+		chromedp.Evaluate(`navigator.userAgent`, &agent),
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+	)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w at %s did not clear in the browser", webClient.ErrChallenge, pageURL)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s in the browser: %w", pageURL, err)
+	}
+
+	if u, err := url.Parse(location); err == nil && agent != "" {
+		webClient.UseBrowserAgent(u.Hostname(), agent)
+	}
+	return &webClient.Page{URL: location, Status: 200, Body: []byte(html)}, nil
+}
+
+// waitChallenge waits until the page has loaded and is no longer a challenge interstitial, the challenge scripts
+// reload the page once they pass
+func waitChallenge() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for {
+			var title, state string
+			if err := chromedp.Title(&title).Do(ctx); err != nil {
+				return err
+			}
+			if err := chromedp.Evaluate(`document.readyState`, &state).Do(ctx); err != nil {
+				return err
+			}
+			if state == "complete" && !isChallengeTitle(title) {
+				return nil
+			}
+
+			select {
+			case <-time.After(500 * time.Millisecond):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+}
+
+func isChallengeTitle(title string) bool {
+	title = strings.ToLower(strings.TrimSpace(title))
+	for _, t := range challengeTitles {
+		if strings.Contains(title, t) {
+			return true
+		}
+	}
+	return false
+}
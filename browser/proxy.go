@@ -0,0 +1,80 @@
+package browser
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ProxyServer returns the --proxy-server value of a proxy URL, Chrome does not take the credentials of the URL
+func ProxyServer(proxy string) (string, error) {
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid proxy URL %q", proxy)
+	}
+	if u.User != nil {
+		slog.Warn("the browser does not support proxy credentials, its requests go through the proxy without them", "proxy", u.Redacted())
+	}
+	scheme := u.Scheme
+	if scheme == "socks5h" {
+		scheme = "socks5"
+	}
+	return scheme + "://" + u.Host, nil
+}
+
+// ProxyPAC returns a proxy auto-config script sending the requests for each host (and its subdomains) through its
+// proxies, and the other requests through fallback or directly. A host's proxies are tried in order, Chrome moves on
+// to the next one when a proxy fails.
+func ProxyPAC(hostProxies map[string][]string, fallback []string) (string, error) {
+	var script strings.Builder
+	script.WriteString("function FindProxyForURL(url, host) {\n")
+	for _, host := range slices.Sorted(maps.Keys(hostProxies)) {
+		directive, err := pacDirective(hostProxies[host])
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&script, "  if (host == %q || dnsDomainIs(host, %q)) return %q;\n", host, "."+host, directive)
+	}
+	directive, err := pacDirective(fallback)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&script, "  return %q;\n}\n", directive)
+	return script.String(), nil
+}
+
+// pacDirective returns the PAC result of a proxy list, eg: "PROXY 10.0.0.1:8080; SOCKS5 10.0.0.2:1080"
+func pacDirective(proxies []string) (string, error) {
+	if len(proxies) == 0 {
+		return "DIRECT", nil
+	}
+
+	var directives []string
+	for _, proxy := range proxies {
+		server, err := ProxyServer(proxy)
+		if err != nil {
+			return "", err
+		}
+		scheme, host, _ := strings.Cut(server, "://")
+		switch scheme {
+		case "http":
+			directives = append(directives, "PROXY "+host)
+		case "https":
+			directives = append(directives, "HTTPS "+host)
+		case "socks5":
+			directives = append(directives, "SOCKS5 "+host)
+		default:
+			return "", fmt.Errorf("proxy %q: unsupported scheme %s", proxy, scheme)
+		}
+	}
+	return strings.Join(directives, "; "), nil
+}
+
+// pacURL is the data URL Chrome loads a PAC script from
+func pacURL(script string) string {
+	return "data:application/x-ns-proxy-autoconfig;base64," + base64.StdEncoding.EncodeToString([]byte(script))
+}
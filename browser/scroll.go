@@ -0,0 +1,111 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ScrollOptions tune ScrollUntilStable
+type ScrollOptions struct {
+	// Step is the fraction of the viewport height scrolled per step
+	Step float64
+	// Idle is how long the page must load no new resources to count as network idle
+	Idle time.Duration
+	// MaxWait bounds the wait for network idle after each step
+	MaxWait time.Duration
+	// MaxSteps bounds the number of scroll steps
+	MaxSteps int
+}
+
+// DefaultScrollOptions are used by ScrollFor
+var DefaultScrollOptions = ScrollOptions{
+	Step:     0.9,
+	Idle:     500 * time.Millisecond,
+	MaxWait:  5 * time.Second,
+	MaxSteps: 400,
+}
+
+// ScrollFor returns the scroll-until-stable action for a site that opted into it (Options.ScrollSites), and an
+// action that does nothing for the other sites.
+func ScrollFor(site, selector string) chromedp.Action {
+	mu.Lock()
+	enabled := slices.Contains(options.ScrollSites, site)
+	mu.Unlock()
+
+	if !enabled {
+		return chromedp.ActionFunc(func(ctx context.Context) error { return nil })
+	}
+	return ScrollUntilStable(selector, DefaultScrollOptions)
+}
+
+// ScrollUntilStable scrolls the page down step by step so lazy loaders replace their placeholders, waiting for the
+// network to go idle after each step. It stops once the bottom of the page is reached and the number of loaded images
+// matching selector no longer changes, then scrolls back to the top.
+func ScrollUntilStable(selector string, opts ScrollOptions) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		countJS := fmt.Sprintf(`[...document.querySelectorAll(%q)].filter(img => img.complete && img.naturalWidth > 0).length`, selector)
+		scrollJS := fmt.Sprintf(`window.scrollBy(0, Math.floor(window.innerHeight * %f)); `+
+			`window.innerHeight + window.scrollY >= document.documentElement.scrollHeight - 2`, opts.Step)
+
+		previous, stable := -1, 0
+		for step := 0; step < opts.MaxSteps; step++ {
+			var atBottom bool
+			if err := chromedp.Evaluate(scrollJS, &atBottom).Do(ctx); err != nil {
+				return err
+			}
+			if err := waitNetworkIdle(ctx, opts.Idle, opts.MaxWait); err != nil {
+				return err
+			}
+
+			var count int
+			if err := chromedp.Evaluate(countJS, &count).Do(ctx); err != nil {
+				return err
+			}
+
+			if atBottom && count == previous {
+				stable++
+			} else {
+				stable = 0
+			}
+			// two steps at the bottom without new images, the page is fully loaded
+			if stable >= 2 {
+				slog.Debug("lazy images loaded", "images", count, "steps", step+1)
+				break
+			}
+			previous = count
+		}
+
+		return chromedp.Evaluate(`window.scrollTo(0, 0)`, nil).Do(ctx)
+	})
+}
+
+// waitNetworkIdle waits until the page has started no new resource loads for idle, or maxWait has passed
+func waitNetworkIdle(ctx context.Context, idle, maxWait time.Duration) error {
+	const poll = 100 * time.Millisecond
+	deadline := time.Now().Add(maxWait)
+
+	last, quietSince := -1, time.Now()
+	for time.Now().Before(deadline) {
+		var resources int
+		if err := chromedp.Evaluate(`performance.getEntriesByType('resource').length`, &resources).Do(ctx); err != nil {
+			return err
+		}
+		if resources != last {
+			last, quietSince = resources, time.Now()
+		} else if time.Since(quietSince) >= idle {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+	return nil
+}
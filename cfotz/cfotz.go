@@ -3,8 +3,9 @@ package cfotz
 import (
 	"fmt"
 	"html"
-	"log"
 	"regexp"
+	"scrape/debugbundle"
+	"scrape/logging"
 	"scrape/parser"
 	"scrape/webClient"
 	"strings"
@@ -12,98 +13,95 @@ import (
 	"github.com/PuerkitoBio/goquery"
 )
 
-func DownloadChapters() {
-	// step zero call clean up func
-	var tempDirList []string
-	parser.CleanupTempDirs(&tempDirList)
+var logger = logging.Site("cfotz")
 
-	// Step 1: Get chapter map from website
-	chapterMap, err := ChapterUrls()
-	if err != nil {
-		log.Fatalf("Failed to download chapter list: %v", err)
-	}
+// SeriesURL is the Childhood Friend of the Zenith home page, the chapters are listed in its #chapters-list-holder
+const SeriesURL = "https://childhoodfriendofthezenith.org/"
+
+// DownloadChapter downloads the chapter images, converts them to JPG and creates the chapter cbz file
+func DownloadChapter(chapter parser.Chapter) error {
+	filename := chapter.Filename
+	chapterName := strings.Split(filename, ".cbz")
+
+	chapterURL := chapter.URL
+	fmt.Printf("Downloading %s\n", chapterName[0])
+	logger.Info("downloading chapter", "chapter", chapter.Number, "file", filename, "url", chapterURL)
 
-	// Step 2: Sort keys descending
-	keys, err := parser.SortKeys(chapterMap)
+	// Fetch chapter HTML and parse the image URLs
+	imgURLs, err := ChapterImages(chapterURL)
 	if err != nil {
-		log.Fatalf("Failed to sort chapters: %v", err)
+		return fmt.Errorf("failed to fetch chapter page %s: %w", chapterURL, err)
+	}
+	if len(imgURLs) == 0 {
+		return fmt.Errorf("%s: %w", chapterName[0], debugbundle.NoMatch(chapterURL, "figure.wp-block-image img", "images"))
 	}
 
-	// Step 3: Get existing CBZ files to skip already downloaded
-	existing, err := parser.GetDownloadedCBZ(".")
+	// pages downloaded before an interruption stay in the stage, only the rest are fetched
+	stage, err := parser.StageChapter(chapterURL)
 	if err != nil {
-		log.Fatalf("Failed to read current directory: %v", err)
+		return err
 	}
+	logger.Debug("staging chapter", "chapter", chapter.Number, "dir", stage.Dir)
 
-	// Step 4: Loop through each chapter
-	for _, filename := range keys {
-		if existing[filename] {
-			log.Printf("Skipping %s - already exists.", filename)
-			continue
+	// the figure images are saved as JPG under their URL file name
+	for _, image := range imgURLs {
+		err := stage.Page(parser.JPGName(image), image, func() error {
+			return parser.DownloadAndConvertToJPG(image, chapterURL, stage.Dir)
+		})
+		if err != nil {
+			logger.Error("failed to download image", "chapter", chapter.Number, "url", image, "error", err)
 		}
+	}
 
-		chapterName := strings.Split(filename, ".cbz")
-
-		chapterURL := chapterMap[filename]
-		fmt.Printf("Downloading %s\n", chapterName[0])
-		log.Printf("Downloading %s from %s", filename, chapterURL)
+	// create the cbz file
+	if err := stage.Archive(filename, len(imgURLs)); err != nil {
+		return err
+	}
+	fmt.Printf("Downloaded: %s\n", filename)
 
-		// Fetch chapter HTML using webClient.FetchChapterPage
-		pageHTML, err := webClient.FetchChapterPage(chapterURL)
-		if err != nil {
-			log.Printf("Failed to fetch chapter page %s: %v", chapterURL, err)
-			continue
-		}
+	return nil
+}
 
-		// Parse the chapter page HTML into a new GoQuery document
-		chapterPage, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
-		if err != nil {
-			log.Printf("Failed to parse chapter page HTML: %v", err)
-			continue
-		}
+// SeriesChapters converts the chapter map of ChapterUrls into chapters, seriesURL is unused since SeriesURL is fixed
+func SeriesChapters(seriesURL string) ([]parser.Chapter, error) {
+	chapterMap, err := ChapterUrls()
+	if err != nil {
+		return nil, err
+	}
+	return parser.ChaptersFromMap(chapterMap), nil
+}
 
-		// Parse image URLs from chapter page HTML
-		// Extract image URLs (specific: figure.wp-block-image)
-		var imgURLs []string
-		chapterPage.Find("figure.wp-block-image img").Each(func(i int, s *goquery.Selection) {
-			src := strings.TrimSpace(s.AttrOr("data-src", s.AttrOr("src", "")))
-			if src != "" {
-				imgURLs = append(imgURLs, src)
-				log.Printf("Found image %d: %s", i+1, src)
-			}
-		})
+// ChapterImages fetches the chapter page HTML using webClient.FetchChapterPage and returns the page image URLs
+func ChapterImages(chapterURL string) ([]string, error) {
+	pageHTML, err := webClient.FetchChapterPage(chapterURL)
+	if err != nil {
+		return nil, err
+	}
 
-		// create temp dir name
-		dirName := strings.Split(filename, ".cbz")
-		tempPrefix := dirName[0]
+	// Parse the chapter page HTML into a new GoQuery document
+	chapterPage, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse chapter page HTML: %w", err)
+	}
 
-		// create the temp directory (prefix and random string create teh temp dir name)
-		tempDir, err := parser.CreateTempDir(tempPrefix)
-		if err != nil {
-			log.Printf("could not create temp directory: %s", tempDir)
-		}
-		// append to the list of temp directories that need to be removed once the download func finishes or
-		// crashes/hard exits
-		tempDirList = append(tempDirList, tempDir)
-		log.Printf("Created tempdir: %s", tempDir)
-
-		// download chapter images to temp directory
-		for _, image := range imgURLs {
-			parser.DownloadAndConvertToJPG(image, tempDir)
+	// Parse image URLs from chapter page HTML
+	// Extract image URLs (specific: figure.wp-block-image)
+	var imgURLs []string
+	chapterPage.Find("figure.wp-block-image img").Each(func(i int, s *goquery.Selection) {
+		src := strings.TrimSpace(s.AttrOr("data-src", s.AttrOr("src", "")))
+		if src != "" {
+			imgURLs = append(imgURLs, src)
+			logger.Debug("found image", "page", i+1, "image", src)
 		}
+	})
 
-		// create the cbz file
-		parser.CreateCbzFromDir(tempDir, filename)
-		fmt.Printf("Downloaded: %s\n", filename)
-	}
+	return imgURLs, nil
 }
 
 // get the chapter URls, using backup func from webclient
 func ChapterUrls() (map[string]string, error) {
-	baseURL := "https://childhoodfriendofthezenith.org/"
-
 	// Reuse existing retry/backoff function to fetch the HTML
-	pageHTML, err := webClient.FetchChapterPage(baseURL)
+	pageHTML, err := webClient.FetchChapterPage(SeriesURL)
 	if err != nil {
 		return nil, err
 	}
@@ -123,7 +121,7 @@ func ChapterUrls() (map[string]string, error) {
 	doc.Find("#chapters-list-holder a.chapter-list-item").Each(func(_ int, element *goquery.Selection) {
 		href, ok := element.Attr("href")
 		if !ok {
-			log.Printf("Could not get href attribute %v", element)
+			logger.Warn("chapter link has no href", "series", SeriesURL, "text", strings.TrimSpace(element.Text()))
 			return
 		}
 
@@ -165,3 +163,13 @@ func ChapterFileName(chapterNumber string) string {
 	return fmt.Sprintf("ch%s.cbz", paddedNum)
 
 }
+
+// SeriesInfo returns the Childhood Friend of the Zenith series info from the meta tags of the home page
+func SeriesInfo(seriesURL string) (*parser.SeriesInfo, error) {
+	doc, err := webClient.FetchDocument(seriesURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.OpenGraphSeriesInfo(doc, seriesURL), nil
+}
@@ -0,0 +1,61 @@
+package cfotz
+
+import (
+	"path/filepath"
+	"scrape/parser"
+	"scrape/replay/replaytest"
+	"slices"
+	"testing"
+)
+
+func TestSeriesChapters(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	chapters, err := SeriesChapters(SeriesURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []parser.Chapter{
+		{Number: "1", Filename: "ch001.cbz", URL: SeriesURL + "comic/chapter-1/"},
+		{Number: "12", Filename: "ch012.cbz", URL: SeriesURL + "comic/chapter-12/"},
+	}
+	if !slices.EqualFunc(chapters, want, sameChapter) {
+		t.Errorf("SeriesChapters() = %+v, expected %+v", chapters, want)
+	}
+}
+
+func TestChapterImages(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	images, err := ChapterImages(SeriesURL + "comic/chapter-1/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the figure images, the lazy loaded data-src before the placeholder src
+	want := []string{
+		SeriesURL + "wp-content/uploads/2024/01/1.jpg",
+		SeriesURL + "wp-content/uploads/2024/01/2.png",
+	}
+	if !slices.Equal(images, want) {
+		t.Errorf("ChapterImages() = %v, expected %v", images, want)
+	}
+}
+
+func TestDownloadChapter(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+	t.Chdir(t.TempDir())
+
+	if err := DownloadChapter(parser.Chapter{Number: "1", Filename: "ch001.cbz", URL: SeriesURL + "comic/chapter-1/"}); err != nil {
+		t.Fatal(err)
+	}
+
+	names := replaytest.ArchiveNames(t, "ch001.cbz")
+	if want := []string{"001.jpg", "002.jpg"}; !slices.Equal(names, want) {
+		t.Errorf("ch001.cbz holds %v, expected %v", names, want)
+	}
+}
+
+func sameChapter(a, b parser.Chapter) bool {
+	return a.Number == b.Number && a.Filename == b.Filename && a.URL == b.URL
+}
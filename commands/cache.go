@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"fmt"
+	"scrape/webClient"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// configureCache applies the --cache-dir, --no-cache, --cache-ttl and --offline flags to the HTTP cache, exiting on
+// an invalid combination or TTL
+func configureCache(cmd *cobra.Command) {
+	opts := webClient.CacheOptions{}
+
+	noCache, _ := cmd.Flags().GetBool("no-cache")
+	if !noCache {
+		opts.Dir, _ = cmd.Flags().GetString("cache-dir")
+	}
+	opts.Offline, _ = cmd.Flags().GetBool("offline")
+	if opts.Offline && opts.Dir == "" {
+		fmt.Println("Error: --offline needs the cache, set --cache-dir and drop --no-cache")
+		exit(1)
+	}
+
+	ttls, _ := cmd.Flags().GetStringToString("cache-ttl")
+	opts.TTL = make(map[string]time.Duration)
+	for class, value := range ttls {
+		if _, ok := webClient.DefaultCacheTTL[class]; !ok {
+			fmt.Printf("Error: unknown --cache-ttl URL class %q, expected one of %s\n", class, strings.Join(cacheClasses(), ", "))
+			exit(1)
+		}
+		ttl, err := parseTTL(value)
+		if err != nil {
+			fmt.Printf("Error: invalid --cache-ttl for %s: %v\n", class, err)
+			exit(1)
+		}
+		opts.TTL[class] = ttl
+	}
+
+	webClient.ConfigureCache(opts)
+}
+
+// parseTTL parses a Go duration (eg: 30m, 12h) or a number of days (eg: 7d), 0 disables caching
+func parseTTL(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	return time.ParseDuration(value)
+}
+
+func cacheClasses() []string {
+	var classes []string
+	for class := range webClient.DefaultCacheTTL {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	return classes
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"scrape/sources"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Check sites command
+var checkSitesCmd = &cobra.Command{
+	Use:   "check-sites [site...]",
+	Short: "Check that the site selectors still match",
+	Long: `For each site (default: every registered site) fetch its canary series and check that:
+  - the chapter list is not empty
+  - every chapter number parses
+  - the newest chapter resolves to at least --min-images page images
+
+Each check is reported as PASS or FAIL with the selector it depends on, so a site redesign shows up before chapters
+stop appearing. Sites that need a browser are skipped when none is available. The command exits with status 1 when a
+check fails. The site tests run the same checks against the recorded fixtures.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		minImages, _ := cmd.Flags().GetInt("min-images")
+		canaries, _ := cmd.Flags().GetStringToString("canary")
+
+		srcs := sources.All()
+		if len(args) > 0 {
+			srcs = nil
+			for _, name := range args {
+				src, err := sources.Get(name)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					exit(1)
+				}
+				srcs = append(srcs, src)
+			}
+		}
+		for name := range canaries {
+			if _, err := sources.Get(name); err != nil {
+				fmt.Printf("Error: --canary: %v\n", err)
+				exit(1)
+			}
+		}
+
+		// the scrapers print their progress, keep stdout for the JSON report
+		stdout := os.Stdout
+		if asJSON {
+			os.Stdout = os.Stderr
+		}
+
+		var results []*sources.CheckResult
+		failed := false
+		for _, src := range srcs {
+			result := src.Check(canaries[src.Name], minImages)
+			results = append(results, result)
+			if result.Skipped == "" && !result.Passed {
+				failed = true
+			}
+		}
+		os.Stdout = stdout
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(results); err != nil {
+				fmt.Printf("Error encoding the check results: %v\n", err)
+				exit(1)
+			}
+		} else {
+			printCheckResults(results)
+		}
+
+		if failed {
+			exit(1)
+		}
+	},
+}
+
+func printCheckResults(results []*sources.CheckResult) {
+	fmt.Println()
+	for _, r := range results {
+		if r.Skipped != "" {
+			fmt.Printf("SKIP  %-11s %s\n", r.Source, r.Skipped)
+			continue
+		}
+		fmt.Printf("%-5s %-11s %s\n", status(r.Passed), r.Source, r.SeriesURL)
+		for _, c := range r.Checks {
+			fmt.Printf("  %-5s %-9s %s\n", status(c.Passed), c.Name, c.Detail)
+			if !c.Passed && c.Selector != "" {
+				fmt.Printf("        selector: %s\n", c.Selector)
+			}
+		}
+	}
+
+	var passed, failed, skipped []string
+	for _, r := range results {
+		switch {
+		case r.Skipped != "":
+			skipped = append(skipped, r.Source)
+		case r.Passed:
+			passed = append(passed, r.Source)
+		default:
+			failed = append(failed, r.Source)
+		}
+	}
+	fmt.Printf("\n%d passed, %d failed, %d skipped\n", len(passed), len(failed), len(skipped))
+	if len(failed) > 0 {
+		fmt.Printf("Failed: %s\n", strings.Join(failed, ", "))
+	}
+}
+
+func status(passed bool) string {
+	if passed {
+		return "PASS"
+	}
+	return "FAIL"
+}
+
+func init() {
+	checkSitesCmd.Flags().Bool("json", false, "Print the check results as JSON")
+	checkSitesCmd.Flags().Int("min-images", 1, "Minimum number of page images the newest chapter must resolve to")
+	checkSitesCmd.Flags().StringToString("canary", nil, "Series to check instead of the site's canary series, eg: kunmanga=https://kunmanga.com/manga/<name>/")
+}
@@ -0,0 +1,227 @@
+package commands
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"scrape/config"
+	"scrape/sources"
+	"scrape/webClient"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show the settings from the config file, environment and flags",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show [site...]",
+	Short: "Print the effective config",
+	Long: `Print the effective settings as YAML, resolved from the flags, the environment (SCRAPE_*), the config file
+(--config, default ~/.config/scrape/config.yaml) and the built-in defaults, in that order of precedence. The sites
+section lists every site with its own settings, with the site's effective settings. Given site names, only those
+sites are printed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, name := range args {
+			if _, err := sources.Get(name); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+		}
+
+		names := args
+		if len(names) == 0 {
+			names = config.SiteNames()
+		}
+		effective := config.Config{Settings: redact(config.Global()), Sites: make(map[string]config.Settings)}
+		for _, name := range names {
+			effective.Sites[name] = redact(config.Site(name))
+		}
+
+		file, _ := cmd.Flags().GetString("config")
+		if _, err := os.Stat(file); err != nil {
+			fmt.Printf("# config file: %s (not found)\n", file)
+		} else {
+			fmt.Printf("# config file: %s\n", file)
+		}
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(effective); err != nil {
+			fmt.Printf("Error encoding the config: %v\n", err)
+			exit(1)
+		}
+	},
+}
+
+// redact hides the account password and the proxy passwords of the printed settings
+func redact(s config.Settings) config.Settings {
+	if s.Login.Password != "" {
+		s.Login.Password = "********"
+	}
+	s.Proxy = redactURL(s.Proxy)
+	if s.Proxies != nil {
+		proxies := make([]string, len(s.Proxies))
+		for i, proxy := range s.Proxies {
+			proxies[i] = redactURL(proxy)
+		}
+		s.Proxies = proxies
+	}
+	return s
+}
+
+// redactURL hides the password of a URL
+func redactURL(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Redacted()
+	}
+	return rawURL
+}
+
+// configureSettings loads the config file and resolves it with the environment and the setting flags, then applies
+// the per site request settings to the HTTP clients. An invalid setting in any layer exits.
+func configureSettings(cmd *cobra.Command) {
+	file, _ := cmd.Flags().GetString("config")
+	cfg, err := config.Load(file, !cmd.Flags().Changed("config"))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+	for name := range cfg.Sites {
+		if _, err := sources.Get(name); err != nil {
+			fmt.Printf("Error: config file %s: sites: %v\n", file, err)
+			exit(1)
+		}
+	}
+
+	env, err := config.Env()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+
+	flags, err := settingsFromFlags(cmd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+
+	config.Configure(config.Layers{File: cfg, Env: env, Flags: flags})
+	webClient.ConfigureRequests(requestOptions)
+}
+
+// settingsFromFlags returns the settings given on the command line
+func settingsFromFlags(cmd *cobra.Command) (config.Settings, error) {
+	var s config.Settings
+
+	s.Output, _ = cmd.Flags().GetString("output")
+	s.NameTemplate, _ = cmd.Flags().GetString("name-template")
+	s.ImageFormat, _ = cmd.Flags().GetString("image-format")
+	s.ImageQuality, _ = cmd.Flags().GetInt("image-quality")
+	s.Concurrency, _ = cmd.Flags().GetInt("concurrency")
+	rateLimit, _ := cmd.Flags().GetDuration("rate-limit")
+	s.RateLimit = config.Duration(rateLimit)
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	s.Timeout = config.Duration(timeout)
+	s.UserAgent, _ = cmd.Flags().GetString("user-agent")
+	s.Headers, _ = cmd.Flags().GetStringToString("header")
+	s.Proxy, _ = cmd.Flags().GetString("proxy")
+	s.Proxies, _ = cmd.Flags().GetStringSlice("proxies")
+	s.TLS.CABundle, _ = cmd.Flags().GetString("ca-bundle")
+	s.TLS.MinVersion, _ = cmd.Flags().GetString("tls-min-version")
+	s.CookiesFile, _ = cmd.Flags().GetString("cookies-file")
+	s.Solver, _ = cmd.Flags().GetString("solver")
+
+	s.Browser.Path, _ = cmd.Flags().GetString("chrome-path")
+	s.Browser.URL, _ = cmd.Flags().GetString("chrome-url")
+	if cmd.Flags().Changed("capture-images") {
+		capture, _ := cmd.Flags().GetBool("capture-images")
+		s.Browser.CaptureImages = config.Bool(capture)
+	}
+	if cmd.Flags().Changed("browser-escalation") {
+		escalate, _ := cmd.Flags().GetBool("browser-escalation")
+		s.Browser.Escalate = config.Bool(escalate)
+	}
+
+	if err := s.Validate(); err != nil {
+		return s, fmt.Errorf("invalid flag: %w", err)
+	}
+	return s, nil
+}
+
+// requestOptions returns the request settings of the site serving host, the global settings for other hosts
+func requestOptions(host string) webClient.RequestOptions {
+	name := ""
+	var profile webClient.HeaderProfile
+	src, err := sources.ForURL("https://" + host + "/")
+	if err == nil {
+		name, profile = src.Name, src.Headers
+	}
+
+	s := config.Site(name)
+	minTLSVersion, _ := config.TLSVersion(s.TLS.MinVersion)
+	loginURL := ""
+	if src != nil && src.Login != nil {
+		if username, _ := s.Login.Credentials(); username != "" {
+			loginURL = src.Login.URL()
+		}
+	}
+	return webClient.RequestOptions{
+		Site:        name,
+		Profile:     profile,
+		UserAgent:   s.UserAgent,
+		Headers:     s.Headers,
+		Cookies:     s.Cookies,
+		Proxies:     s.ProxyList(),
+		CABundle:    config.ExpandHome(s.TLS.CABundle),
+		Solver:      s.Solver,
+		Timeout:     time.Duration(s.Timeout),
+		RateLimit:   time.Duration(s.RateLimit),
+		Concurrency: s.Concurrency,
+		LoginURL:    loginURL,
+
+		MinTLSVersion: minTLSVersion,
+	}
+}
+
+// enterSeriesDir changes to the directory the series is downloaded into when an output root is configured, creating
+// it if needed
+func enterSeriesDir(src *sources.Source, seriesURL string) error {
+	dir, err := config.Site(src.Name).SeriesDir(src.Name, seriesSlug(src, seriesURL))
+	if err != nil || dir == "." {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create the series directory: %w", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		return fmt.Errorf("failed to change to the series directory: %w", err)
+	}
+	fmt.Printf("Series directory: %s\n", dir)
+	return nil
+}
+
+// seriesSlug returns the last path segment of the series URL, eg: https://kunmanga.com/manga/<slug>/, or the site name
+// for the single series sites served from their home page
+func seriesSlug(src *sources.Source, seriesURL string) string {
+	u, err := url.Parse(seriesURL)
+	if err != nil {
+		return src.Name
+	}
+	slug := path.Base(strings.TrimSuffix(u.Path, "/"))
+	if slug == "." || slug == "/" || slug == "" {
+		return src.Name
+	}
+	return slug
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+}
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"scrape/config"
+	"scrape/sources"
+	"scrape/webClient"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// cookieJarFile is the file the shared cookie jar is saved to at the end of the run, see saveCookies
+var cookieJarFile string
+
+// configureCookies loads the cookie jar kept from the previous runs (--cookie-jar) and the cookies.txt exports of the
+// cookies_file settings. An export is only loaded when it is newer than the saved jar, so the cookies the sites
+// updated since are not replaced with the older exported ones. A jar or export that cannot be read exits.
+func configureCookies(cmd *cobra.Command) {
+	jar := webClient.Jar()
+
+	cookieJarFile, _ = cmd.Flags().GetString("cookie-jar")
+	cookieJarFile = config.ExpandHome(cookieJarFile)
+	var saved time.Time
+	if cookieJarFile != "" {
+		if info, err := os.Stat(cookieJarFile); err == nil {
+			if _, err := jar.Load(cookieJarFile, nil); err != nil {
+				fmt.Printf("Error: failed to load the cookie jar: %v\n", err)
+				exit(1)
+			}
+			jar.MarkSaved()
+			saved = info.ModTime()
+		}
+	}
+
+	global := config.Global().CookiesFile
+	if global != "" {
+		if err := importCookies(global, nil, saved); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+	}
+	for _, src := range sources.All() {
+		file := config.Site(src.Name).CookiesFile
+		if file == "" || file == global {
+			continue
+		}
+		if err := importCookies(file, webClient.HostCookieFilter(src.Hosts), saved); err != nil {
+			fmt.Printf("Error: %s: %v\n", src.Name, err)
+			exit(1)
+		}
+	}
+}
+
+// importCookies loads a cookies.txt export into the shared jar, unless it is older than the saved jar
+func importCookies(file string, keep func(domain string) bool, saved time.Time) error {
+	file = config.ExpandHome(file)
+	info, err := os.Stat(file)
+	if err != nil {
+		return fmt.Errorf("cookies file: %w", err)
+	}
+	if !saved.IsZero() && info.ModTime().Before(saved) {
+		slog.Debug("cookies file is older than the cookie jar, not loaded", "file", file)
+		return nil
+	}
+
+	n, err := webClient.Jar().Load(file, keep)
+	if err != nil {
+		return fmt.Errorf("cookies file: %w", err)
+	}
+	slog.Info("loaded cookies", "file", file, "cookies", n)
+	return nil
+}
+
+// saveCookies writes the shared cookie jar back to --cookie-jar when the run changed it
+func saveCookies() {
+	if cookieJarFile == "" {
+		return
+	}
+	if err := webClient.Jar().Save(cookieJarFile); err != nil {
+		slog.Warn("failed to save the cookie jar", "file", cookieJarFile, "error", err)
+	}
+}
+
+// siteCookies is the shared cookie jar as the browser sees it: the cookies the browser hands back are only kept for
+// the hosts of the registered sites
+type siteCookies struct {
+	*webClient.CookieJar
+}
+
+func (j siteCookies) Add(cookies ...*http.Cookie) {
+	var hosts []string
+	for _, src := range sources.All() {
+		hosts = append(hosts, src.Hosts...)
+	}
+	keep := webClient.HostCookieFilter(hosts)
+
+	var kept []*http.Cookie
+	for _, c := range cookies {
+		if keep(strings.TrimPrefix(strings.ToLower(c.Domain), ".")) {
+			kept = append(kept, c)
+		}
+	}
+	j.CookieJar.Add(kept...)
+}
@@ -0,0 +1,82 @@
+//go:build fakesite
+
+package commands
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"scrape/fakesite"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Fake site command, only built with the fakesite tag: go run -tags fakesite . fake-site
+var fakeSiteCmd = &cobra.Command{
+	Use:   "fake-site",
+	Short: "Serve a fake manga site for end to end testing",
+	Long: `Serve a fake manga site with the Madara (/madara/manga/<series>/), MangaThemesia (/themesia/series/<series>/)
+and generic WordPress (/wordpress/) page structures the scrapers parse. The chapter pages are generated JPEG, PNG and
+WebP images.
+
+Faults are injected with --fault kind:path[:times], the path being matched without the layout prefix:
+  503:/images/fake-series/1/        answer with an HTTP status, eg: 429, 500, 503
+  slow=5s:/manga/fake-series/       delay the response
+  html-image:/images/fake-series/2/ answer an image request with an HTML page
+  truncate:/images/fake-series/3/1  cut the body short of its Content-Length
+  missing:/manga/fake-series/chapter-2/
+                                    answer with a 404
+
+With --login user:password the chapter pages redirect to the login form at /<layout>/login/ until it is posted
+with that account.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("addr")
+		specs, _ := cmd.Flags().GetStringArray("fault")
+		chapters, _ := cmd.Flags().GetInt("chapters")
+		pages, _ := cmd.Flags().GetInt("pages")
+		login, _ := cmd.Flags().GetString("login")
+
+		series := fakesite.Series{Slug: "fake-series", Title: "Fake Series", Pages: pages}
+		for n := 1; n <= chapters; n++ {
+			series.Chapters = append(series.Chapters, strconv.Itoa(n))
+		}
+		server := fakesite.New(series)
+		if login != "" {
+			username, password, ok := strings.Cut(login, ":")
+			if !ok || username == "" {
+				fmt.Printf("Error: invalid --login %q, expected user:password\n", login)
+				exit(1)
+			}
+			server.RequireLogin(username, password)
+		}
+
+		for _, spec := range specs {
+			f, err := fakesite.ParseFault(spec)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			server.AddFault(f)
+		}
+
+		fmt.Printf("Serving the fake site on http://%s/\n", addr)
+		if err := http.ListenAndServe(addr, server); err != nil {
+			slog.Error("fake site stopped", "error", err)
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+	},
+}
+
+func init() {
+	fakeSiteCmd.Flags().String("addr", "127.0.0.1:8089", "Address to listen on")
+	fakeSiteCmd.Flags().StringArray("fault", nil, "Fault to inject, kind:path[:times] (repeatable)")
+	fakeSiteCmd.Flags().Int("chapters", 3, "Number of chapters of the fake series")
+	fakeSiteCmd.Flags().Int("pages", 3, "Number of pages of every chapter")
+	fakeSiteCmd.Flags().String("login", "", "Gate the chapter pages behind an account, user:password")
+
+	rootCmd.AddCommand(fakeSiteCmd)
+}
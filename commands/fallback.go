@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"fmt"
+	"scrape/library"
+	"scrape/sources"
+
+	"github.com/spf13/cobra"
+)
+
+// Fallback command
+var fallbackCmd = &cobra.Command{
+	Use:   "fallback",
+	Short: "Manage the fallback mirror sources of a series",
+	Long: `A series can list fallback mirrors on other sites, in order. When the source of the series lacks a chapter
+number, or downloading the chapter fails, the chapter is downloaded from the first fallback that lists it. The
+source each chapter came from is recorded in its ComicInfo.xml.`,
+}
+
+var fallbackAddCmd = &cobra.Command{
+	Use:   "add <series> <url>",
+	Short: "Add a fallback mirror to a series",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, url := args[0], args[1]
+
+		src, err := sources.ForURL(url)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		state := loadStateOrExit(dir)
+		if !state.AddFallback(src.Name, url) {
+			fmt.Printf("%s already uses %s\n", dir, url)
+			return
+		}
+		saveStateOrExit(dir, state)
+		printFallbacks(state)
+	},
+}
+
+var fallbackRemoveCmd = &cobra.Command{
+	Use:   "remove <series> <url>",
+	Short: "Remove a fallback mirror from a series",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, url := args[0], args[1]
+
+		state := loadStateOrExit(dir)
+		if !state.RemoveFallback(url) {
+			fmt.Printf("Error: %s is not a fallback of %s\n", url, dir)
+			exit(1)
+		}
+		saveStateOrExit(dir, state)
+		printFallbacks(state)
+	},
+}
+
+var fallbackListCmd = &cobra.Command{
+	Use:   "list [series]",
+	Short: "List the sources of a series in the order they are tried",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		printFallbacks(loadStateOrExit(dir))
+	},
+}
+
+// loadStateOrExit reads the library state of a series directory, exiting if it has none
+func loadStateOrExit(dir string) *library.State {
+	state, err := library.LoadState(dir)
+	if err != nil {
+		fmt.Printf("Error: %v, download the series with a site command first\n", err)
+		exit(1)
+	}
+	return state
+}
+
+// saveStateOrExit writes the library state of a series directory, exiting on failure
+func saveStateOrExit(dir string, state *library.State) {
+	if err := library.SaveState(dir, state); err != nil {
+		fmt.Printf("Error saving library state: %v\n", err)
+		exit(1)
+	}
+}
+
+// printFallbacks prints the source of a series followed by its fallbacks
+func printFallbacks(state *library.State) {
+	fmt.Printf("1. %s\t%s (primary)\n", state.Source, state.URL)
+	for i, ref := range state.Fallbacks {
+		fmt.Printf("%d. %s\t%s\n", i+2, ref.Source, ref.URL)
+	}
+}
+
+func init() {
+	fallbackCmd.AddCommand(fallbackAddCmd, fallbackRemoveCmd, fallbackListCmd)
+}
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"scrape/library"
+	"scrape/parser"
+	"scrape/sources"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Gaps command
+var gapsCmd = &cobra.Command{
+	Use:   "gaps [series]",
+	Short: "Report missing chapters of a series",
+	Long: `Compare the chapter archives of a series directory against the chapter list of its source and report:
+  - chapters the source lists that are missing locally (or only partially downloaded)
+  - local chapters the source no longer lists
+  - jumps in the chapter numbering that neither side has (eg: 41 -> 43)
+
+The series is either a series directory (default: the current directory), read from the library state written by
+the site commands, or a series URL for the directory given with --dir.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := cmd.Flags().GetString("dir")
+		fetch, _ := cmd.Flags().GetBool("fetch")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		var seriesURL string
+		if len(args) == 1 {
+			if strings.Contains(args[0], "://") {
+				seriesURL = args[0]
+			} else {
+				dir = args[0]
+			}
+		}
+
+		src, seriesURL, err := seriesSource(dir, seriesURL)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		// the site download functions write to the current directory
+		if err := os.Chdir(dir); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		if err := src.CheckBrowser(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		local, err := library.LocalChapters(".")
+		if err != nil {
+			fmt.Printf("Error reading local chapters: %v\n", err)
+			exit(1)
+		}
+
+		remote, err := src.Resolve(seriesURL, ".")
+		if err != nil {
+			fmt.Printf("%s\nError retrieving chapter list from %s\n", err, src.Name)
+			exit(1)
+		}
+
+		report := library.Gaps(local, remote)
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				fmt.Printf("Error encoding gap report: %v\n", err)
+				exit(1)
+			}
+		} else {
+			printGapReport(report)
+		}
+
+		if !fetch || len(report.MissingLocal) == 0 {
+			return
+		}
+
+		if err := src.DownloadChapters(report.MissingLocal); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+	},
+}
+
+// seriesSource finds the source of the series in dir, either from the series URL or from the library state of dir
+func seriesSource(dir, seriesURL string) (*sources.Source, string, error) {
+	if seriesURL != "" {
+		src, err := sources.ForURL(seriesURL)
+		return src, seriesURL, err
+	}
+
+	state, err := library.LoadState(dir)
+	if errors.Is(err, library.ErrNoState) {
+		return nil, "", fmt.Errorf("%w, pass the series URL or download the series with a site command first", err)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	src, err := sources.Get(state.Source)
+	return src, state.URL, err
+}
+
+// printGapReport prints the gap report as text
+func printGapReport(report *library.GapReport) {
+	if report.IsEmpty() {
+		fmt.Println("No gaps found")
+		return
+	}
+
+	if len(report.MissingLocal) > 0 {
+		fmt.Printf("Missing locally (%d):\n", len(report.MissingLocal))
+		for _, ch := range report.MissingLocal {
+			fmt.Printf("  %s\t%s\t%s\n", chapterLabel(ch), ch.Status, ch.URL)
+		}
+	}
+
+	if len(report.MissingRemote) > 0 {
+		fmt.Printf("Missing upstream (%d):\n", len(report.MissingRemote))
+		for _, ch := range report.MissingRemote {
+			fmt.Printf("  %s\t%s\n", chapterLabel(ch), ch.Filename)
+		}
+	}
+
+	if len(report.Jumps) > 0 {
+		fmt.Printf("Numbering jumps (%d):\n", len(report.Jumps))
+		for _, j := range report.Jumps {
+			fmt.Printf("  %s\n", j)
+		}
+	}
+}
+
+// chapterLabel is the chapter number, or the filename of chapters without one
+func chapterLabel(ch parser.Chapter) string {
+	if ch.Number == "" {
+		return ch.Filename
+	}
+	return ch.Number
+}
+
+func init() {
+	gapsCmd.Flags().String("dir", ".", "Series directory, when the series is given as a URL")
+	gapsCmd.Flags().Bool("fetch", false, "Download the chapters that are missing locally")
+	gapsCmd.Flags().Bool("json", false, "Print the gap report as JSON")
+}
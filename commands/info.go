@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"scrape/sources"
+
+	"github.com/spf13/cobra"
+)
+
+// Info command
+var infoCmd = &cobra.Command{
+	Use:   "info <url>",
+	Short: "Show series metadata",
+	Long: `Print the series metadata (title, alternative titles, authors, artists, genres, status, synopsis and cover
+image URL) for a series page on any supported site. Nothing is downloaded.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		src, err := sources.ForURL(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		info, err := src.Info(args[0])
+		if err != nil {
+			fmt.Printf("%s\nError retrieving series info from %s\n", err, src.Name)
+			exit(1)
+		}
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(info); err != nil {
+				fmt.Printf("Error encoding series info: %v\n", err)
+				exit(1)
+			}
+			return
+		}
+
+		fmt.Print(info)
+	},
+}
+
+func init() {
+	infoCmd.Flags().Bool("json", false, "Print the series info as JSON")
+}
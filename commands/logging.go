@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"scrape/logging"
+
+	"github.com/spf13/cobra"
+)
+
+// configureLogging applies the --log-file, --log-level and --log-format flags. When the default log file cannot be
+// opened the logs go to stderr, only a log file given with --log-file that cannot be opened exits.
+func configureLogging(cmd *cobra.Command) {
+	opts := logging.Options{}
+	opts.File, _ = cmd.Flags().GetString("log-file")
+	opts.Format, _ = cmd.Flags().GetString("log-format")
+
+	levelName, _ := cmd.Flags().GetString("log-level")
+	level, err := logging.ParseLevel(levelName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+	opts.Level = level
+
+	err = logging.Setup(opts)
+	if err != nil && !cmd.Flags().Changed("log-file") && opts.File != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %v, logging to stderr\n", err)
+		opts.File = "-"
+		err = logging.Setup(opts)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+}
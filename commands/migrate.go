@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"fmt"
+	"scrape/library"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Migrate names command
+var migrateNamesCmd = &cobra.Command{
+	Use:   "migrate-names [dir]",
+	Short: "Rename chapter archives to the current naming scheme",
+	Long: `Rename the chapter archives of a series directory (default: the current directory) from the older naming
+schemes (ch05.cbz, 005.cbz, vol01ch05.cbz, and the ch12.5.cbz decimal chapters of ravenscans) to the current one
+(ch005.cbz, ch012.5.cbz), so that already downloaded chapters are skipped by the site commands. With --pages the
+pages inside each archive are renamed to page-001.jpg, page-002.jpg... as well.
+
+Archives that would end up with the same name (eg: ch05.cbz and ch005.cbz) are reported and left untouched. Every
+migration is recorded in an undo journal in the directory, --undo reverts the last one.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		pages, _ := cmd.Flags().GetBool("pages")
+		undo, _ := cmd.Flags().GetBool("undo")
+
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+
+		if undo {
+			migration, err := library.UndoMigration(dir)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+			fmt.Printf("Undid the migration of %s: %d archives and %d archive page lists restored\n",
+				migration.Time.Format("2006-01-02 15:04:05"), len(migration.Archives), len(migration.Pages))
+			return
+		}
+
+		migration, err := library.PlanMigration(dir, pages)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		printMigration(migration)
+
+		if dryRun || migration.IsEmpty() {
+			return
+		}
+
+		if err := library.ApplyMigration(dir, migration); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		fmt.Printf("Renamed %d archives, undo with: scrape migrate-names --undo %s\n", len(migration.Archives), dir)
+	},
+}
+
+// printMigration prints the planned renames and the archives that will be left alone
+func printMigration(migration *library.Migration) {
+	if migration.IsEmpty() {
+		fmt.Println("Nothing to rename")
+	}
+
+	for _, r := range migration.Archives {
+		fmt.Printf("%s -> %s\n", r.From, r.To)
+	}
+	for _, p := range migration.Pages {
+		fmt.Printf("%s: %d pages renamed (%s -> %s ...)\n", p.Archive, len(p.Pages), p.Pages[0].From, p.Pages[0].To)
+	}
+
+	for _, c := range migration.Conflicts {
+		fmt.Printf("CONFLICT %s -> %s: left untouched, remove the duplicate first\n", strings.Join(c.Files, ", "), c.Target)
+	}
+	for _, name := range migration.Skipped {
+		fmt.Printf("SKIPPED %s: no chapter number in the filename\n", name)
+	}
+}
+
+func init() {
+	migrateNamesCmd.Flags().Bool("dry-run", false, "Show the renames without changing anything")
+	migrateNamesCmd.Flags().Bool("pages", false, "Rename the pages inside each archive as well")
+	migrateNamesCmd.Flags().Bool("undo", false, "Revert the last migration from the undo journal")
+}
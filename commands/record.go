@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"fmt"
+	"net/http"
+	"scrape/replay"
+
+	"github.com/spf13/cobra"
+)
+
+// configureRecord applies the --record flag: every HTTP response of the run is saved into the fixture directory, for
+// the site package tests. The HTTP cache is bypassed so the recorded responses come from the site.
+func configureRecord(cmd *cobra.Command) {
+	dir, _ := cmd.Flags().GetString("record")
+	if dir == "" {
+		return
+	}
+	if offline, _ := cmd.Flags().GetBool("offline"); offline {
+		fmt.Println("Error: --record cannot be used with --offline")
+		exit(1)
+	}
+
+	maxImages, _ := cmd.Flags().GetInt("record-images")
+	recorder, err := replay.NewRecorder(dir, http.DefaultTransport, maxImages)
+	if err != nil {
+		fmt.Printf("Error: failed to record into %s: %v\n", dir, err)
+		exit(1)
+	}
+	http.DefaultTransport = recorder
+	cmd.Flags().Set("no-cache", "true")
+}
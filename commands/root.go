@@ -2,7 +2,19 @@ package commands
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"scrape/browser"
+	"scrape/config"
+	"scrape/debugbundle"
+	"scrape/logging"
+	"scrape/parser"
+	"scrape/sources"
+	"scrape/webClient"
+	"slices"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -12,27 +24,173 @@ var rootCmd = &cobra.Command{
 	Short: "A manga scraping tool",
 	Long: `A command-line tool for scraping manga chapters from various websites.
 Supports multiple manga sites with different download options.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		configureLogging(cmd)
+		configureSettings(cmd)
+		configureCookies(cmd)
+		configureBrowser(cmd)
+		configureRecord(cmd)
+		configureCache(cmd)
+		configureEscalation()
+		debugDir, _ := cmd.Flags().GetString("debug-dir")
+		debugbundle.SetDir(debugDir)
+		stagingDir, _ := cmd.Flags().GetString("staging-dir")
+		parser.ConfigureStaging(stagingDir)
+	},
+}
+
+// configureBrowser applies the browser settings (--chrome-url, --chrome-path, --capture-images, SCRAPE_CHROME_URL,
+// SCRAPE_CHROME_PATH and the browser section of the config file) to the shared browser. The sites scrolled are the
+// ones given with --scroll-sites, or the sites with browser.scroll set. A proxy the browser cannot use exits.
+func configureBrowser(cmd *cobra.Command) {
+	opts := browser.DefaultOptions
+
+	settings := config.Global().Browser
+	opts.RemoteURL = settings.URL
+	opts.ExecPath = settings.Path
+	opts.CaptureImages = settings.CaptureImages == nil || *settings.CaptureImages
+	// tabs opened without a timeout of their own are bounded like the HTTP requests, by --timeout / SCRAPE_TIMEOUT
+	if timeout := config.Global().Timeout; timeout > 0 {
+		opts.Timeout = time.Duration(timeout)
+	}
+	opts.Cookies = siteCookies{webClient.Jar()}
+	if err := browserProxy(&opts); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+
+	if cmd.Flags().Changed("scroll-sites") {
+		opts.ScrollSites, _ = cmd.Flags().GetStringSlice("scroll-sites")
+	} else {
+		opts.ScrollSites = nil
+		for _, src := range sources.All() {
+			if scroll := config.Site(src.Name).Browser.Scroll; scroll != nil && *scroll {
+				opts.ScrollSites = append(opts.ScrollSites, src.Name)
+			}
+		}
+	}
+
+	browser.Configure(opts)
+}
+
+// browserProxy routes a launched browser through the same proxies as the HTTP requests: with --proxy-server when
+// every site uses the same single proxy, else with a PAC script choosing the proxies by host
+func browserProxy(opts *browser.Options) error {
+	global := config.Global().ProxyList()
+	hostProxies := make(map[string][]string)
+	single := len(global) <= 1
+	for _, src := range sources.All() {
+		proxies := config.Site(src.Name).ProxyList()
+		if slices.Equal(proxies, global) {
+			continue
+		}
+		single = false
+		for _, host := range src.Hosts {
+			hostProxies[host] = proxies
+		}
+	}
+
+	if single {
+		if len(global) == 1 {
+			server, err := browser.ProxyServer(global[0])
+			if err != nil {
+				return err
+			}
+			opts.ProxyServer = server
+		}
+		return nil
+	}
+	script, err := browser.ProxyPAC(hostProxies, global)
+	if err != nil {
+		return err
+	}
+	opts.ProxyPAC = script
+	return nil
+}
+
+// configureEscalation retries the pages that get an anti-bot challenge in the browser, unless browser.escalate is
+// off. Browser pages are not cached, so an --offline run does not escalate.
+func configureEscalation() {
+	escalate := config.Global().Browser.Escalate
+	if (escalate == nil || *escalate) && !webClient.Offline() {
+		webClient.ConfigureBrowserFetcher(browser.Fetcher{})
+	} else {
+		webClient.ConfigureBrowserFetcher(nil)
+	}
 }
 
 func Execute() {
+	// close the shared browser when interrupted, so no Chrome processes are left behind
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		slog.Info("interrupt received, shutting down the browser")
+		exit(1)
+	}()
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
+	browser.Shutdown()
+	webClient.CloseSolvers()
+	saveCookies()
+}
+
+// exit shuts down the shared browser and the solver sessions, saves the cookie jar and exits with code
+func exit(code int) {
+	browser.Shutdown()
+	webClient.CloseSolvers()
+	saveCookies()
+	os.Exit(code)
 }
 
 func init() {
+	rootCmd.PersistentFlags().String("config", config.DefaultFile(), "Config file with the global and per-site settings")
+	rootCmd.PersistentFlags().String("output", "", "Root directory series are downloaded into, under --name-template (default: the current directory) (env SCRAPE_OUTPUT)")
+	rootCmd.PersistentFlags().String("name-template", "", "Series directory under --output, with the fields {{.Site}} and {{.Series}} (default \"{{.Series}}\") (env SCRAPE_NAME_TEMPLATE)")
+	rootCmd.PersistentFlags().String("image-format", "", "Convert the chapter pages to jpg or png (default: as the site saves them) (env SCRAPE_IMAGE_FORMAT)")
+	rootCmd.PersistentFlags().Int("image-quality", 0, "JPEG quality of the converted pages, 1-100 (default 90) (env SCRAPE_IMAGE_QUALITY)")
+	rootCmd.PersistentFlags().Int("concurrency", 0, "Maximum number of requests in flight to a site (default: no limit) (env SCRAPE_CONCURRENCY)")
+	rootCmd.PersistentFlags().Duration("rate-limit", 0, "Minimum delay between two requests to a site, eg: 500ms (env SCRAPE_RATE_LIMIT)")
+	rootCmd.PersistentFlags().Duration("timeout", 0, "Timeout of every request (default 1m0s) (env SCRAPE_TIMEOUT)")
+	rootCmd.PersistentFlags().String("user-agent", "", "User-Agent sent instead of each site's own (env SCRAPE_USER_AGENT)")
+	rootCmd.PersistentFlags().StringToString("header", nil, "Header to send with every request, eg: Accept-Language=en (repeatable)")
+	rootCmd.PersistentFlags().String("proxy", "", "Proxy URL requests go through, eg: http://127.0.0.1:8080 or socks5://127.0.0.1:1080 (env SCRAPE_PROXY)")
+	rootCmd.PersistentFlags().StringSlice("proxies", nil, "Proxy URLs the requests rotate over, skipping the ones that keep failing (env SCRAPE_PROXIES)")
+	rootCmd.PersistentFlags().String("ca-bundle", "", "PEM file of certificate authorities to trust besides the system ones (env SCRAPE_CA_BUNDLE)")
+	rootCmd.PersistentFlags().String("tls-min-version", "", "Lowest TLS version accepted: 1.0, 1.1, 1.2 or 1.3 (env SCRAPE_TLS_MIN_VERSION)")
+	rootCmd.PersistentFlags().String("cookies-file", "", "Netscape cookies.txt export to load into the cookie jar, eg: for a logged in session (env SCRAPE_COOKIES_FILE)")
+	rootCmd.PersistentFlags().String("cookie-jar", webClient.DefaultCookieFile(), "File the cookies are kept in between runs, empty keeps them in memory only")
+	rootCmd.PersistentFlags().String("log-file", logging.DefaultFile(), "Log file, - logs to stderr")
+	rootCmd.PersistentFlags().String("log-level", "info", "Log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().String("log-format", logging.FormatText, "Log format: text or json")
+	rootCmd.PersistentFlags().String("chrome-url", "", "DevTools URL of a running Chrome to use instead of launching one, eg: ws://chrome:9222/ (env SCRAPE_CHROME_URL)")
+	rootCmd.PersistentFlags().Bool("capture-images", true, "Package page images from the browser's network traffic instead of downloading them again, where the site supports it")
+	rootCmd.PersistentFlags().String("solver", "", "API URL of a FlareSolverr compatible solver the anti-bot challenge pages are fetched through instead of the browser, eg: http://localhost:8191/v1 (env SCRAPE_SOLVER)")
+	rootCmd.PersistentFlags().Bool("browser-escalation", true, "Retry the pages that get an anti-bot challenge (eg: Cloudflare) over plain HTTP in the browser")
+	rootCmd.PersistentFlags().StringSlice("scroll-sites", browser.DefaultOptions.ScrollSites, "Sites whose chapter pages are scrolled to load lazy images before reading them")
+	rootCmd.PersistentFlags().String("debug-dir", "", "Write a debug bundle (page HTML, screenshot, console log and headers) for every chapter that fails into this directory")
+	rootCmd.PersistentFlags().String("cache-dir", webClient.DefaultCacheDir(), "Directory of the HTTP cache of series and chapter pages")
+	rootCmd.PersistentFlags().Bool("no-cache", false, "Fetch every page from the site, without the HTTP cache")
+	rootCmd.PersistentFlags().StringToString("cache-ttl", nil, "How long cached pages are used before asking the site again, per URL class, eg: series=30m,chapter=7d,image=0 (defaults: series=1h, chapter=7d, image=0 not cached)")
+	rootCmd.PersistentFlags().Bool("offline", false, "Serve every page from the HTTP cache and never contact the sites, to re-run parsing on cached pages")
+	rootCmd.PersistentFlags().String("staging-dir", parser.DefaultStagingDir(), "Directory the chapter pages are kept in until the chapter archive is written, an interrupted download resumes from them")
+	rootCmd.PersistentFlags().String("record", "", "Save every HTTP response of the run into this directory, as replay fixtures for the site tests")
+	rootCmd.PersistentFlags().Int("record-images", 3, "Number of sample images saved with --record")
+	rootCmd.PersistentFlags().String("chrome-path", "", "Chrome / Chromium binary to launch for the sites that need a browser (env SCRAPE_CHROME_PATH)")
+
 	// Add all site-specific commands
-	rootCmd.AddCommand(manhuausCmd)
-	rootCmd.AddCommand(kunmangaCmd)
-	rootCmd.AddCommand(xbatoCmd)
-	rootCmd.AddCommand(iluimCmd)
-	rootCmd.AddCommand(orvCmd)
-	rootCmd.AddCommand(rizzfablesCmd)
-	rootCmd.AddCommand(hlsCmd)
-	rootCmd.AddCommand(mgekoCmd)
-	rootCmd.AddCommand(cfotzCmd)
-	rootCmd.AddCommand(stonescapeCmd)
-	rootCmd.AddCommand(asuraCmd)
-	rootCmd.AddCommand(ravenscansCmd)
+	rootCmd.AddCommand(siteCommands()...)
+
+	// Add the commands that work across every site
+	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(gapsCmd)
+	rootCmd.AddCommand(migrateNamesCmd)
+	rootCmd.AddCommand(switchSourceCmd)
+	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(fallbackCmd)
+	rootCmd.AddCommand(checkSitesCmd)
+	rootCmd.AddCommand(configCmd)
 }
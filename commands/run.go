@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"scrape/library"
+	"scrape/parser"
+	"scrape/selection"
+	"scrape/sources"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// addSiteFlags adds the listing and chapter selection flags shared by every site command
+func addSiteFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("list", false, "List the remote chapters and their local status, then exit")
+	cmd.Flags().Bool("dry-run", false, "Resolve the chapters and their page URLs without downloading anything")
+	cmd.Flags().Bool("json", false, "Print --list / --dry-run output as JSON")
+
+	cmd.Flags().String("chapters", "", "Chapters to select, eg: 10-20,25,30.5,72-season-1-end")
+	cmd.Flags().Int("latest", 0, "Select only the newest N chapters")
+	cmd.Flags().String("from", "", "First chapter to select, a number (eg: 10.5) or a special chapter name")
+	cmd.Flags().String("to", "", "Last chapter to select, a number (eg: 20) or a special chapter name")
+	cmd.Flags().String("since", "", "Select chapters released on or after a date (eg: 2025-01-31 or 7d), where the site lists release dates")
+}
+
+// selectionFromFlags builds the chapter selection from the command flags
+func selectionFromFlags(cmd *cobra.Command) (selection.Selection, error) {
+	var sel selection.Selection
+
+	sel.Chapters, _ = cmd.Flags().GetString("chapters")
+	sel.Latest, _ = cmd.Flags().GetInt("latest")
+	sel.From, _ = cmd.Flags().GetString("from")
+	sel.To, _ = cmd.Flags().GetString("to")
+
+	// kunmanga's original --start / --end integer flags map onto --from / --to
+	if start, err := cmd.Flags().GetInt("start"); err == nil && start != 0 && sel.From == "" {
+		sel.From = strconv.Itoa(start)
+	}
+	if end, err := cmd.Flags().GetInt("end"); err == nil && end != 0 && sel.To == "" {
+		sel.To = strconv.Itoa(end)
+	}
+
+	if since, _ := cmd.Flags().GetString("since"); since != "" {
+		t, err := parseSince(since, time.Now())
+		if err != nil {
+			return sel, err
+		}
+		sel.Since = t
+	}
+
+	return sel, nil
+}
+
+// parseSince parses the --since value, either a date or a number of days back from now (eg: 7d)
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+
+	if t, ok := parser.ParseReleaseDate(value, now); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since date %q", value)
+}
+
+// runSite resolves the chapter list of a series, applies the chapter selection flags and then either prints the
+// chapters (--list / --dry-run) or downloads the selected chapters that are not already downloaded.
+func runSite(cmd *cobra.Command, sourceName, seriesURL string) {
+	list, _ := cmd.Flags().GetBool("list")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	sel, err := selectionFromFlags(cmd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+
+	src, err := sources.Get(sourceName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+
+	if err := src.CheckBrowser(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+
+	if err := enterSeriesDir(src, seriesURL); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+
+	mirrors := seriesMirrors(src, seriesURL)
+
+	chapters, err := mirrors.Resolve(".")
+	if err != nil {
+		fmt.Printf("%s\nError retrieving chapter list from %s\n", err, src.Name)
+		exit(1)
+	}
+
+	chapters, err = sel.Apply(chapters)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exit(1)
+	}
+
+	if !list && !dryRun {
+		// remember where this directory is downloaded from for the library commands (gaps, switch-source)
+		if err := library.RecordSource(".", src.Name, seriesURL); err != nil {
+			slog.Warn("failed to save library state", "site", src.Name, "error", err)
+		}
+
+		if err := mirrors.DownloadChapters(chapters); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		return
+	}
+
+	if dryRun {
+		mirrors.DiscoverPages(chapters)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(chapters); err != nil {
+			fmt.Printf("Error encoding chapter list: %v\n", err)
+			exit(1)
+		}
+		return
+	}
+
+	printChapterTable(chapters, dryRun)
+}
+
+// seriesMirrors returns the sources to download the series in the current directory from: the given source, followed
+// by the fallbacks in the library state when the state is for the same series.
+func seriesMirrors(src *sources.Source, seriesURL string) *sources.MirrorSet {
+	single := &sources.MirrorSet{Mirrors: []sources.Mirror{{Source: src, URL: seriesURL}}}
+
+	state, err := library.LoadState(".")
+	if err != nil || state.Source != src.Name || state.URL != seriesURL || len(state.Fallbacks) == 0 {
+		return single
+	}
+
+	mirrors, err := sources.MirrorsFromState(state)
+	if err != nil {
+		slog.Warn("ignoring the fallback sources", "site", src.Name, "error", err)
+		return single
+	}
+	return mirrors
+}
+
+// printChapterTable prints the resolved chapters as an aligned table, with the page counts for a dry run
+func printChapterTable(chapters []parser.Chapter, dryRun bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	if dryRun {
+		fmt.Fprintln(w, "CHAPTER\tTITLE\tSOURCE\tSTATUS\tPAGES\tURL")
+	} else {
+		fmt.Fprintln(w, "CHAPTER\tTITLE\tSOURCE\tSTATUS\tURL")
+	}
+
+	toDownload := 0
+	for _, ch := range chapters {
+		title := ch.Title
+		if title == "" {
+			title = "-"
+		}
+		if ch.Status != parser.StatusDownloaded {
+			toDownload++
+		}
+
+		if !dryRun {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ch.Number, title, ch.Source, ch.Status, ch.URL)
+			continue
+		}
+
+		pages := "-"
+		if ch.Error != "" {
+			pages = "error: " + ch.Error
+		} else if ch.Status != parser.StatusDownloaded {
+			pages = fmt.Sprint(len(ch.Pages))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", ch.Number, title, ch.Source, ch.Status, pages, ch.URL)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d chapters, %d to download\n", len(chapters), toDownload)
+}
@@ -2,23 +2,14 @@ package commands
 
 import (
 	"fmt"
-	"log"
-	"os"
-	"scrape/asura"
+	"log/slog"
 	"scrape/cfotz"
 	"scrape/hls"
 	"scrape/iluim"
 	"scrape/kunmanga"
-	"scrape/manhuaus"
-	"scrape/mgeko"
 	"scrape/orv"
 	"scrape/parser"
-	"scrape/ravenscans"
-	"scrape/rizzfables"
-	"scrape/stonescape"
 	"scrape/xbato"
-	"sort"
-	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -33,32 +24,10 @@ var manhuausCmd = &cobra.Command{
 		if url == "" {
 			fmt.Println("Error: --url flag is required")
 			cmd.Usage()
-			os.Exit(1)
+			exit(1)
 		}
 
-		chapterList, err := manhuaus.ChapterURLs(url)
-		if err != nil {
-			fmt.Printf("%s\nError retrieving chapter list from manhuaus\n", err)
-			os.Exit(1)
-		}
-
-		toDownload := parser.FilterUndownloadedChapters(chapterList)
-		totalChapters := len(toDownload)
-		fmt.Println("Downloading", totalChapters, "chapters")
-
-		for _, chapter := range toDownload {
-			chNumber, err := manhuaus.ExtractChapterNumber(chapter)
-			if err != nil {
-				fmt.Printf("%s\nError extracting chapter number from URL\n", err)
-				continue
-			}
-			fmt.Println("Downloading chapter #", chNumber)
-			err = manhuaus.DownloadChaper(chapter, chNumber)
-			if err != nil {
-				fmt.Printf("%s\nError downloading chapter number: %s\n", err, chapter)
-				os.Exit(1)
-			}
-		}
+		runSite(cmd, "manhuaus", url)
 	},
 }
 
@@ -69,64 +38,13 @@ var kunmangaCmd = &cobra.Command{
 	Long:  `Download manga chapters from KunManga website using shortname`,
 	Run: func(cmd *cobra.Command, args []string) {
 		shortName, _ := cmd.Flags().GetString("shortname")
-		start, _ := cmd.Flags().GetInt("start")
-		end, _ := cmd.Flags().GetInt("end")
-
 		if shortName == "" {
 			fmt.Println("Error: --shortname flag is required")
 			cmd.Usage()
-			os.Exit(1)
-		}
-
-		type Chapter struct {
-			URL    string
-			Slug   string
-			Number int
-		}
-
-		chapterURLs := kunmanga.KunMangaChapterUrls(shortName)
-		var filtered []Chapter
-
-		for _, chapterUrl := range chapterURLs {
-			parts := strings.Split(strings.Trim(chapterUrl, "/"), "/")
-			chapterSlug := parts[len(parts)-1]
-			chNum := kunmanga.ParseChapterNumber(chapterSlug)
-
-			if start != 0 && end != 0 {
-				if chNum < start || chNum > end {
-					log.Printf("[MAIN] Skipping chapter %s (number %d): outside range %d-%d", chapterSlug, chNum, start, end)
-					continue
-				}
-			}
-
-			exists, err := parser.CBZExists(chNum)
-			if err != nil {
-				log.Printf("[MAIN] Kunmanga error checking CBZ for chapter %d: %v", chNum, err)
-				continue
-			}
-			if exists {
-				log.Printf("[MAIN] Kunmanga skipping chapter %d: CBZ already exists", chNum)
-				continue
-			}
-
-			filtered = append(filtered, Chapter{
-				URL:    chapterUrl,
-				Slug:   chapterSlug,
-				Number: chNum,
-			})
+			exit(1)
 		}
 
-		sort.Slice(filtered, func(i, j int) bool {
-			return filtered[i].Number < filtered[j].Number
-		})
-
-		for _, ch := range filtered {
-			fmt.Printf("Downloading chapter %d (%s)...\n", ch.Number, ch.Slug)
-			err := kunmanga.DownloadKunMangaChapters(ch.URL, ch.Number)
-			if err != nil {
-				log.Printf("[MAIN] Error downloading chapter %s: %v", ch.Slug, err)
-			}
-		}
+		runSite(cmd, "kunmanga", kunmanga.MangaURL(shortName))
 	},
 }
 
@@ -137,29 +55,13 @@ var xbatoCmd = &cobra.Command{
 	Long:  `Download manga chapters from Xbato website using shortname`,
 	Run: func(cmd *cobra.Command, args []string) {
 		shortName, _ := cmd.Flags().GetString("shortname")
-
 		if shortName == "" {
 			fmt.Println("Error: --shortname flag is required")
 			cmd.Usage()
-			os.Exit(1)
-		}
-
-		parser.CheckBrowser("xbato")
-
-		chapterUrls, err := xbato.XbatoChapterUrls(shortName)
-		if err != nil {
-			fmt.Printf("%s\nError retrieving chapter list from xbato\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
-		chapterMap, err := xbato.ChapterOptions(chapterUrls[0])
-		if err != nil {
-			fmt.Println("error retrieving chapterMap from url: ", err)
-			os.Exit(1)
-		}
-
-		formattedChapterMap := xbato.FormatChapterMap(chapterMap)
-		xbato.DownloadAndCreateCBZ(chapterUrls, formattedChapterMap)
+		runSite(cmd, "xbato", xbato.MangaURL(shortName))
 	},
 }
 
@@ -169,12 +71,8 @@ var iluimCmd = &cobra.Command{
 	Short: "Scrape chapters from Infinite Level Up",
 	Long:  `Download manga chapters from Infinite Level Up website`,
 	Run: func(cmd *cobra.Command, args []string) {
-		log.Println("Starting iluim scraper...")
-		chapterUrls, err := iluim.ChapterURLs("https://infinitelevelup.com/")
-		if err != nil {
-			log.Fatalf("Get Chapter URLs failed: %v", err)
-		}
-		iluim.DownloadChapters(chapterUrls)
+		slog.Info("starting the scraper", "site", "iluim")
+		runSite(cmd, "iluim", iluim.SeriesURL)
 	},
 }
 
@@ -184,8 +82,8 @@ var orvCmd = &cobra.Command{
 	Short: "Scrape ORV chapters",
 	Long:  `Download missing ORV manga chapters`,
 	Run: func(cmd *cobra.Command, args []string) {
-		log.Println("ORV starting download of missing ORV chapters...")
-		orv.DownloadMangaChapters()
+		slog.Info("starting the download of missing chapters", "site", "orv")
+		runSite(cmd, "orv", orv.SeriesURL)
 	},
 }
 
@@ -199,11 +97,10 @@ var rizzfablesCmd = &cobra.Command{
 		if url == "" {
 			fmt.Println("Error: --url flag is required")
 			cmd.Usage()
-			os.Exit(1)
+			exit(1)
 		}
 
-		fmt.Println("Rizzfables starting chapter download...")
-		rizzfables.DownloadMangaChapters(url)
+		runSite(cmd, "rizzfables", url)
 	},
 }
 
@@ -213,8 +110,7 @@ var hlsCmd = &cobra.Command{
 	Short: "Scrape Honey Lemon Soda chapters",
 	Long:  `Download Honey Lemon Soda manga chapters`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Honey Lemon Soda, starting chapter download...")
-		hls.DownloadChapters()
+		runSite(cmd, "hls", hls.SeriesURL)
 	},
 }
 
@@ -228,12 +124,11 @@ var mgekoCmd = &cobra.Command{
 		if url == "" {
 			fmt.Println("Error: --url flag is required")
 			cmd.Usage()
-			os.Exit(1)
+			exit(1)
 		}
 
-		targetName := parser.MgekoUrlToName(url)
-		fmt.Printf("%s, starting chapter download...\n", targetName)
-		mgeko.DownloadChapters(url)
+		fmt.Printf("%s, starting chapter download...\n", parser.MgekoUrlToName(url))
+		runSite(cmd, "mgeko", url)
 	},
 }
 
@@ -243,8 +138,7 @@ var cfotzCmd = &cobra.Command{
 	Short: "Scrape Childhood Friend of the Zenith chapters",
 	Long:  `Download Childhood Friend of the Zenith manga chapters`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("Starting download childhood friend of the zenith\n")
-		cfotz.DownloadChapters()
+		runSite(cmd, "cfotz", cfotz.SeriesURL)
 	},
 }
 
@@ -258,11 +152,10 @@ var stonescapeCmd = &cobra.Command{
 		if url == "" {
 			fmt.Println("Error: --url flag is required")
 			cmd.Usage()
-			os.Exit(1)
+			exit(1)
 		}
 
-		fmt.Printf("Starting download from stonescape for: %s\n", url)
-		stonescape.DownloadChapters(url)
+		runSite(cmd, "stonescape", url)
 	},
 }
 
@@ -276,11 +169,10 @@ var asuraCmd = &cobra.Command{
 		if url == "" {
 			fmt.Println("Error: --url flag is required")
 			cmd.Usage()
-			os.Exit(1)
+			exit(1)
 		}
 
-		fmt.Printf("Starting download from asuracomics for: %s\n", url)
-		asura.DownloadChapters(url)
+		runSite(cmd, "asura", url)
 	},
 }
 
@@ -294,11 +186,10 @@ var ravenscansCmd = &cobra.Command{
 		if url == "" {
 			fmt.Println("Error: --url flag is required")
 			cmd.Usage()
-			os.Exit(1)
+			exit(1)
 		}
 
-		fmt.Printf("Starting download from ravenscans for: %s\n", url)
-		ravenscans.DownloadMangaChapters(url)
+		runSite(cmd, "ravenscans", url)
 	},
 }
 
@@ -314,6 +205,31 @@ func init() {
 	kunmangaCmd.Flags().String("shortname", "", "Shortname for the manga (required)")
 	kunmangaCmd.Flags().Int("start", 0, "Start chapter number (optional)")
 	kunmangaCmd.Flags().Int("end", 0, "End chapter number (optional)")
+	kunmangaCmd.Flags().MarkDeprecated("start", "use --from instead")
+	kunmangaCmd.Flags().MarkDeprecated("end", "use --to instead")
 
 	xbatoCmd.Flags().String("shortname", "", "Shortname for the manga (required)")
+
+	// --list / --dry-run and the chapter selection flags are available on every site command
+	for _, cmd := range siteCommands() {
+		addSiteFlags(cmd)
+	}
+}
+
+// siteCommands returns every site specific command
+func siteCommands() []*cobra.Command {
+	return []*cobra.Command{
+		manhuausCmd,
+		kunmangaCmd,
+		xbatoCmd,
+		iluimCmd,
+		orvCmd,
+		rizzfablesCmd,
+		hlsCmd,
+		mgekoCmd,
+		cfotzCmd,
+		stonescapeCmd,
+		asuraCmd,
+		ravenscansCmd,
+	}
 }
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"scrape/library"
+	"scrape/parser"
+	"scrape/sources"
+
+	"github.com/spf13/cobra"
+)
+
+// Switch source command
+var switchSourceCmd = &cobra.Command{
+	Use:   "switch-source <series> <new-url>",
+	Short: "Follow a series on another site",
+	Long: `Switch the series directory <series> to a new source site. The chapter list of the new site is matched
+against the already downloaded archives by chapter number, existing archives are kept and only the chapters the
+local copy lacks are downloaded. The new source is recorded in the library state of the directory, the previous
+one is kept in its history.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		dir, newURL := args[0], args[1]
+
+		src, err := sources.ForURL(newURL)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		previous, err := library.LoadState(dir)
+		if err != nil && !errors.Is(err, library.ErrNoState) {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		// the site download functions write to the current directory
+		if err := os.Chdir(dir); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		if err := src.CheckBrowser(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		chapters, err := src.Resolve(newURL, ".")
+		if err != nil {
+			fmt.Printf("%s\nError retrieving chapter list from %s\n", err, src.Name)
+			exit(1)
+		}
+
+		kept := 0
+		for _, ch := range chapters {
+			if ch.Status == parser.StatusDownloaded {
+				kept++
+			}
+		}
+		if previous != nil {
+			fmt.Printf("Switching %s from %s (%s) to %s (%s)\n", dir, previous.Source, previous.URL, src.Name, newURL)
+		} else {
+			fmt.Printf("Switching %s to %s (%s)\n", dir, src.Name, newURL)
+		}
+		fmt.Printf("%d of %d chapters on %s are already downloaded\n", kept, len(chapters), src.Name)
+
+		if dryRun {
+			printChapterTable(chapters, false)
+			return
+		}
+
+		if err := library.RecordSource(".", src.Name, newURL); err != nil {
+			fmt.Printf("Error saving library state: %v\n", err)
+			exit(1)
+		}
+
+		if err := src.DownloadChapters(chapters); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+	},
+}
+
+func init() {
+	switchSourceCmd.Flags().Bool("dry-run", false, "Show how the new chapter list maps onto the local archives without switching")
+}
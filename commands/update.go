@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// Update command
+var updateCmd = &cobra.Command{
+	Use:   "update [series]",
+	Short: "Download the new chapters of a followed series",
+	Long: `Download the chapters a series directory (default: the current directory) is missing, from the source
+recorded in its library state and then its fallback mirrors. Takes the same chapter selection flags as the site
+commands.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+
+		state := loadStateOrExit(dir)
+
+		// the site download functions write to the current directory
+		if err := os.Chdir(dir); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		runSite(cmd, state.Source, state.URL)
+	},
+}
+
+func init() {
+	addSiteFlags(updateCmd)
+}
@@ -0,0 +1,531 @@
+// global and per-site settings read from the config file (~/.config/scrape/config.yaml). A setting resolves, from
+// highest to lowest precedence, from its command line flag, its environment variable, the site's section of the
+// config file, the global section of the config file and the built-in defaults.
+package config
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Image formats the chapter pages can be converted to
+const (
+	FormatJPG = "jpg"
+	FormatPNG = "png"
+)
+
+// Settings are the options that can be set globally and per site. A zero field is not set and resolves from the next
+// layer down.
+type Settings struct {
+	// Output is the root directory series are downloaded into, the current directory when empty
+	Output string `yaml:"output,omitempty"`
+	// NameTemplate names the series directory under Output, eg: "{{.Site}}/{{.Series}}"
+	NameTemplate string `yaml:"name_template,omitempty"`
+	// ImageFormat converts the pages of every downloaded chapter to jpg or png, pages are kept as the site saves them
+	// when empty
+	ImageFormat string `yaml:"image_format,omitempty"`
+	// ImageQuality is the JPEG quality (1-100) pages are encoded with
+	ImageQuality int `yaml:"image_quality,omitempty"`
+	// Concurrency is the maximum number of requests in flight to a site, 0 is no limit
+	Concurrency int `yaml:"concurrency,omitempty"`
+	// RateLimit is the minimum delay between two requests to a site
+	RateLimit Duration `yaml:"rate_limit,omitempty"`
+	// Timeout bounds every request, including reading the response body
+	Timeout Duration `yaml:"timeout,omitempty"`
+	// UserAgent replaces the User-Agent header the scrapers send
+	UserAgent string `yaml:"user_agent,omitempty"`
+	// Headers are added to every request, replacing the headers of the same name
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Cookies are sent with every request, by name
+	Cookies map[string]string `yaml:"cookies,omitempty"`
+	// CookiesFile is a Netscape cookies.txt export loaded into the cookie jar, a site's file only adds the cookies of
+	// the site's hosts
+	CookiesFile string `yaml:"cookies_file,omitempty"`
+	// Proxy is the URL of the proxy requests go through, eg: http://127.0.0.1:8080, https://proxy:8443 or
+	// socks5://127.0.0.1:1080
+	Proxy string `yaml:"proxy,omitempty"`
+	// Proxies are rotated over request by request instead of Proxy, a proxy that keeps failing is skipped for a while
+	Proxies []string `yaml:"proxies,omitempty"`
+	// TLS configures the verification of the HTTPS connections
+	TLS TLS `yaml:"tls,omitempty"`
+	// Solver is the API URL of a FlareSolverr compatible challenge solver, eg: http://localhost:8191/v1. The pages that
+	// get an anti-bot challenge are fetched through it instead of the browser.
+	Solver string `yaml:"solver,omitempty"`
+	// Browser configures the headless browser used by the sites that need one
+	Browser Browser `yaml:"browser,omitempty"`
+	// Login is the site account, for the sites that gate chapters behind one
+	Login Login `yaml:"login,omitempty"`
+}
+
+// Login are the credentials of a site account
+type Login struct {
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// PasswordEnv names the environment variable the password is read from, to keep it out of the config file
+	PasswordEnv string `yaml:"password_env,omitempty"`
+}
+
+// Credentials returns the username and password, the password read from PasswordEnv when it is not set. Both are
+// empty when no account is configured.
+func (l Login) Credentials() (username, password string) {
+	password = l.Password
+	if password == "" && l.PasswordEnv != "" {
+		password = os.Getenv(l.PasswordEnv)
+	}
+	return l.Username, password
+}
+
+// TLS are the TLS settings of the HTTPS connections
+type TLS struct {
+	// CABundle is a PEM file of certificate authorities trusted besides the system ones, eg: an intercepting proxy's
+	CABundle string `yaml:"ca_bundle,omitempty"`
+	// MinVersion is the lowest TLS version accepted: 1.0, 1.1, 1.2 or 1.3
+	MinVersion string `yaml:"min_version,omitempty"`
+}
+
+// TLSVersion returns the crypto/tls constant of a min_version, 0 (the Go default) when empty
+func TLSVersion(version string) (uint16, error) {
+	switch version {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("tls.min_version %q, expected 1.0, 1.1, 1.2 or 1.3", version)
+}
+
+// ProxyList returns the proxies the requests rotate over: Proxies, or else Proxy
+func (s Settings) ProxyList() []string {
+	if len(s.Proxies) > 0 {
+		return s.Proxies
+	}
+	if s.Proxy != "" {
+		return []string{s.Proxy}
+	}
+	return nil
+}
+
+// Browser are the browser settings. The browser is shared by every site of a run, so only Scroll is read per site.
+type Browser struct {
+	// Path is the Chrome / Chromium binary to launch
+	Path string `yaml:"path,omitempty"`
+	// URL is the DevTools URL of a running Chrome to use instead of launching one
+	URL string `yaml:"url,omitempty"`
+	// CaptureImages packages the page images from the browser's network traffic instead of downloading them again
+	CaptureImages *bool `yaml:"capture_images,omitempty"`
+	// Escalate retries the pages that get an anti-bot challenge over plain HTTP in the browser
+	Escalate *bool `yaml:"escalate,omitempty"`
+	// Scroll scrolls the chapter pages to load lazy images before reading them
+	Scroll *bool `yaml:"scroll,omitempty"`
+}
+
+// Config is the content of the config file: the global settings and the per-site overrides by site name
+type Config struct {
+	Settings `yaml:",inline"`
+	Sites    map[string]Settings `yaml:"sites,omitempty"`
+}
+
+// Defaults are the built-in settings, the lowest precedence layer
+var Defaults = Config{
+	Settings: Settings{
+		NameTemplate: "{{.Series}}",
+		ImageQuality: 90,
+		Timeout:      Duration(60 * time.Second),
+		Browser:      Browser{CaptureImages: Bool(true), Escalate: Bool(true)},
+	},
+	Sites: map[string]Settings{
+		"rizzfables": {ImageQuality: 75},
+		"ravenscans": {ImageQuality: 75},
+		"orv":        {Browser: Browser{Scroll: Bool(true)}},
+		"stonescape": {Browser: Browser{Scroll: Bool(true)}},
+	},
+}
+
+// Bool returns a pointer to b, for the optional boolean settings
+func Bool(b bool) *bool {
+	return &b
+}
+
+// DefaultFile returns the config file in the XDG config directory, eg: ~/.config/scrape/config.yaml
+func DefaultFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "scrape", "config.yaml")
+}
+
+// Load reads the config file at path. A missing file is an empty config when optional is set, the default file does
+// not have to exist but a file given with --config does. Unknown keys are an error, to catch misspelt settings.
+func Load(path string, optional bool) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && optional {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the config file: %w", err)
+	}
+
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Validate checks the global and per-site settings
+func (c *Config) Validate() error {
+	if err := c.Settings.Validate(); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Sites)) {
+		site := c.Sites[name]
+		if err := site.Validate(); err != nil {
+			return fmt.Errorf("sites.%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the values of the settings that are set
+func (s *Settings) Validate() error {
+	switch strings.ToLower(s.ImageFormat) {
+	case "", FormatJPG, "jpeg", FormatPNG:
+	default:
+		return fmt.Errorf("image_format %q, expected %s or %s", s.ImageFormat, FormatJPG, FormatPNG)
+	}
+	if s.ImageQuality < 0 || s.ImageQuality > 100 {
+		return fmt.Errorf("image_quality %d, expected 1 to 100", s.ImageQuality)
+	}
+	if s.Concurrency < 0 {
+		return fmt.Errorf("concurrency %d, expected 0 (no limit) or more", s.Concurrency)
+	}
+	for _, proxy := range s.ProxyList() {
+		if err := ValidateProxy(proxy); err != nil {
+			return err
+		}
+	}
+	if _, err := TLSVersion(s.TLS.MinVersion); err != nil {
+		return err
+	}
+	if s.Solver != "" {
+		if u, err := url.Parse(s.Solver); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("solver %q, expected the http(s) URL of the solver API", s.Solver)
+		}
+	}
+	if s.NameTemplate != "" {
+		if _, err := template.New("name").Parse(s.NameTemplate); err != nil {
+			return fmt.Errorf("name_template: %w", err)
+		}
+	}
+	return nil
+}
+
+// ValidateProxy checks a proxy URL: http, https, socks5 or socks5h with a host
+func ValidateProxy(proxy string) error {
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("proxy %q, expected a URL such as http://127.0.0.1:8080 or socks5://127.0.0.1:1080", proxy)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return nil
+	}
+	return fmt.Errorf("proxy %q, expected an http, https, socks5 or socks5h URL", proxy)
+}
+
+// Merge returns s with the fields set in over replacing its own, headers and cookies are merged by name
+func (s Settings) Merge(over Settings) Settings {
+	if over.Output != "" {
+		s.Output = over.Output
+	}
+	if over.NameTemplate != "" {
+		s.NameTemplate = over.NameTemplate
+	}
+	if over.ImageFormat != "" {
+		s.ImageFormat = over.ImageFormat
+	}
+	if over.ImageQuality != 0 {
+		s.ImageQuality = over.ImageQuality
+	}
+	if over.Concurrency != 0 {
+		s.Concurrency = over.Concurrency
+	}
+	if over.RateLimit != 0 {
+		s.RateLimit = over.RateLimit
+	}
+	if over.Timeout != 0 {
+		s.Timeout = over.Timeout
+	}
+	if over.UserAgent != "" {
+		s.UserAgent = over.UserAgent
+	}
+	s.Headers = mergeMap(s.Headers, over.Headers)
+	s.Cookies = mergeMap(s.Cookies, over.Cookies)
+	if over.CookiesFile != "" {
+		s.CookiesFile = over.CookiesFile
+	}
+	if over.Proxy != "" {
+		s.Proxy, s.Proxies = over.Proxy, nil
+	}
+	if len(over.Proxies) > 0 {
+		s.Proxy, s.Proxies = "", over.Proxies
+	}
+	if over.TLS.CABundle != "" {
+		s.TLS.CABundle = over.TLS.CABundle
+	}
+	if over.TLS.MinVersion != "" {
+		s.TLS.MinVersion = over.TLS.MinVersion
+	}
+	if over.Solver != "" {
+		s.Solver = over.Solver
+	}
+	if over.Browser.Path != "" {
+		s.Browser.Path = over.Browser.Path
+	}
+	if over.Browser.URL != "" {
+		s.Browser.URL = over.Browser.URL
+	}
+	if over.Browser.CaptureImages != nil {
+		s.Browser.CaptureImages = over.Browser.CaptureImages
+	}
+	if over.Browser.Escalate != nil {
+		s.Browser.Escalate = over.Browser.Escalate
+	}
+	if over.Browser.Scroll != nil {
+		s.Browser.Scroll = over.Browser.Scroll
+	}
+	if over.Login.Username != "" {
+		s.Login.Username = over.Login.Username
+	}
+	if over.Login.Password != "" {
+		s.Login.Password = over.Login.Password
+	}
+	if over.Login.PasswordEnv != "" {
+		s.Login.PasswordEnv = over.Login.PasswordEnv
+	}
+	return s
+}
+
+func mergeMap(base, over map[string]string) map[string]string {
+	if len(over) == 0 {
+		return base
+	}
+	merged := maps.Clone(base)
+	if merged == nil {
+		merged = make(map[string]string)
+	}
+	maps.Copy(merged, over)
+	return merged
+}
+
+// Env reads the settings set in the environment: SCRAPE_OUTPUT, SCRAPE_NAME_TEMPLATE, SCRAPE_IMAGE_FORMAT,
+// SCRAPE_IMAGE_QUALITY, SCRAPE_CONCURRENCY, SCRAPE_RATE_LIMIT, SCRAPE_TIMEOUT, SCRAPE_USER_AGENT, SCRAPE_PROXY,
+// SCRAPE_PROXIES (comma separated), SCRAPE_CA_BUNDLE, SCRAPE_TLS_MIN_VERSION, SCRAPE_COOKIES_FILE, SCRAPE_SOLVER,
+// SCRAPE_CHROME_PATH and SCRAPE_CHROME_URL
+func Env() (Settings, error) {
+	s := Settings{
+		Output:       os.Getenv("SCRAPE_OUTPUT"),
+		NameTemplate: os.Getenv("SCRAPE_NAME_TEMPLATE"),
+		ImageFormat:  os.Getenv("SCRAPE_IMAGE_FORMAT"),
+		UserAgent:    os.Getenv("SCRAPE_USER_AGENT"),
+		Proxy:        os.Getenv("SCRAPE_PROXY"),
+		Solver:       os.Getenv("SCRAPE_SOLVER"),
+		CookiesFile:  os.Getenv("SCRAPE_COOKIES_FILE"),
+		TLS: TLS{
+			CABundle:   os.Getenv("SCRAPE_CA_BUNDLE"),
+			MinVersion: os.Getenv("SCRAPE_TLS_MIN_VERSION"),
+		},
+		Browser: Browser{
+			Path: os.Getenv("SCRAPE_CHROME_PATH"),
+			URL:  os.Getenv("SCRAPE_CHROME_URL"),
+		},
+	}
+
+	if proxies := os.Getenv("SCRAPE_PROXIES"); proxies != "" {
+		for _, proxy := range strings.Split(proxies, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				s.Proxies = append(s.Proxies, proxy)
+			}
+		}
+	}
+
+	for name, field := range map[string]*int{"SCRAPE_IMAGE_QUALITY": &s.ImageQuality, "SCRAPE_CONCURRENCY": &s.Concurrency} {
+		if value := os.Getenv(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return s, fmt.Errorf("invalid %s %q: %w", name, value, err)
+			}
+			*field = n
+		}
+	}
+	for name, field := range map[string]*Duration{"SCRAPE_RATE_LIMIT": &s.RateLimit, "SCRAPE_TIMEOUT": &s.Timeout} {
+		if value := os.Getenv(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return s, fmt.Errorf("invalid %s %q: %w", name, value, err)
+			}
+			*field = Duration(d)
+		}
+	}
+
+	if err := s.Validate(); err != nil {
+		return s, fmt.Errorf("environment: %w", err)
+	}
+	return s, nil
+}
+
+// Layers are the sources of the effective settings, see Configure
+type Layers struct {
+	// File is the loaded config file, nil when there is none
+	File *Config
+	// Env are the settings from the environment variables
+	Env Settings
+	// Flags are the settings from the command line flags that were given
+	Flags Settings
+}
+
+var (
+	mu     sync.Mutex
+	layers Layers
+)
+
+// Configure sets the layers the effective settings of Global and Site resolve from
+func Configure(l Layers) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	layers = l
+}
+
+// Global returns the effective settings of a run, without any per-site override
+func Global() Settings {
+	return Site("")
+}
+
+// Site returns the effective settings of the site: flag > env > per-site > global, the config file layers above the
+// built-in ones within the per-site and the global layers, so a global image_quality in the config file does not
+// override the built-in quality of a site. The site's account is also read from SCRAPE_<SITE>_USERNAME and
+// SCRAPE_<SITE>_PASSWORD, eg: SCRAPE_KUNMANGA_PASSWORD.
+func Site(name string) Settings {
+	mu.Lock()
+	defer mu.Unlock()
+
+	s := Defaults.Settings
+	if layers.File != nil {
+		s = s.Merge(layers.File.Settings)
+	}
+	if name != "" {
+		s = s.Merge(Defaults.Sites[name])
+		if layers.File != nil {
+			s = s.Merge(layers.File.Sites[name])
+		}
+	}
+	s = s.Merge(layers.Env).Merge(layers.Flags)
+	if name != "" {
+		prefix := "SCRAPE_" + strings.ToUpper(name) + "_"
+		s = s.Merge(Settings{Login: Login{
+			Username: os.Getenv(prefix + "USERNAME"),
+			Password: os.Getenv(prefix + "PASSWORD"),
+		}})
+	}
+	return s
+}
+
+// SiteNames returns the sites with their own settings, in the config file or the built-in defaults, sorted
+func SiteNames() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := slices.Collect(maps.Keys(Defaults.Sites))
+	if layers.File != nil {
+		for name := range layers.File.Sites {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// SeriesDir returns the directory a series is downloaded into: the name template under the output root, or the
+// current directory when no output root is set
+func (s Settings) SeriesDir(site, series string) (string, error) {
+	if s.Output == "" {
+		return ".", nil
+	}
+
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(s.NameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid name_template: %w", err)
+	}
+	var name strings.Builder
+	if err := tmpl.Execute(&name, struct{ Site, Series string }{site, series}); err != nil {
+		return "", fmt.Errorf("invalid name_template: %w", err)
+	}
+	if strings.TrimSpace(name.String()) == "" {
+		return "", fmt.Errorf("name_template %q gives an empty directory name", s.NameTemplate)
+	}
+
+	return filepath.Join(ExpandHome(s.Output), filepath.Clean(name.String())), nil
+}
+
+// ExpandHome replaces a leading ~ of a path setting with the home directory
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// Duration is a time.Duration written as a Go duration string in the config file, eg: 1500ms, 30s
+type Duration time.Duration
+
+func (d Duration) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q, eg: 1500ms, 30s, 2m", node.Line, node.Value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
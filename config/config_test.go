@@ -0,0 +1,237 @@
+package config
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+output: /manga
+rate_limit: 1500ms
+headers:
+  Accept-Language: en
+browser:
+  path: /usr/bin/chromium
+sites:
+  kunmanga:
+    image_quality: 70
+    headers:
+      Referer: https://kunmanga.com/
+    browser:
+      scroll: true
+`)
+	cfg, err := Load(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Output != "/manga" || cfg.RateLimit != Duration(1500*time.Millisecond) || cfg.Browser.Path != "/usr/bin/chromium" {
+		t.Errorf("global settings = %+v", cfg.Settings)
+	}
+	site := cfg.Sites["kunmanga"]
+	if site.ImageQuality != 70 || site.Headers["Referer"] != "https://kunmanga.com/" || site.Browser.Scroll == nil || !*site.Browser.Scroll {
+		t.Errorf("kunmanga settings = %+v", site)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":      "sites:\n  kunmanga:\n    qualty: 70\n",
+		"bad duration":     "rate_limit: fast\n",
+		"bad format":       "image_format: tiff\n",
+		"bad quality":      "sites:\n  kunmanga:\n    image_quality: 101\n",
+		"bad template":     "name_template: '{{.Series'\n",
+		"negative workers": "concurrency: -1\n",
+	}
+	for name, content := range tests {
+		if _, err := Load(writeConfig(t, content), false); err == nil {
+			t.Errorf("%s: Load succeeded, want an error", name)
+		}
+	}
+
+	missing := filepath.Join(t.TempDir(), "config.yaml")
+	if cfg, err := Load(missing, true); err != nil || !reflect.DeepEqual(cfg, &Config{}) {
+		t.Errorf("Load(missing, optional) = %+v, %v; want an empty config", cfg, err)
+	}
+	if _, err := Load(missing, false); err == nil {
+		t.Error("Load(missing) succeeded, want an error")
+	}
+}
+
+func TestSitePrecedence(t *testing.T) {
+	defer Configure(Layers{})
+
+	cfg, err := Load(writeConfig(t, `
+image_quality: 85
+timeout: 20s
+user_agent: global-agent
+headers:
+  Accept-Language: en
+sites:
+  kunmanga:
+    image_quality: 70
+    timeout: 10s
+    headers:
+      X-Site: kunmanga
+`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Configure(Layers{
+		File:  cfg,
+		Env:   Settings{Timeout: Duration(5 * time.Second), UserAgent: "env-agent"},
+		Flags: Settings{UserAgent: "flag-agent"},
+	})
+
+	kunmanga := Site("kunmanga")
+	if kunmanga.ImageQuality != 70 {
+		t.Errorf("kunmanga image_quality = %d, want the per-site 70", kunmanga.ImageQuality)
+	}
+	if kunmanga.Timeout != Duration(5*time.Second) {
+		t.Errorf("kunmanga timeout = %v, want the environment 5s", kunmanga.Timeout)
+	}
+	if kunmanga.UserAgent != "flag-agent" {
+		t.Errorf("kunmanga user_agent = %q, want the flag", kunmanga.UserAgent)
+	}
+	if want := map[string]string{"Accept-Language": "en", "X-Site": "kunmanga"}; !reflect.DeepEqual(kunmanga.Headers, want) {
+		t.Errorf("kunmanga headers = %v, want %v", kunmanga.Headers, want)
+	}
+
+	// the built-in per-site defaults are above the global config file layer
+	if q := Site("rizzfables").ImageQuality; q != 75 {
+		t.Errorf("rizzfables image_quality = %d, want the built-in per-site 75", q)
+	}
+	if q := Site("mgeko").ImageQuality; q != 85 {
+		t.Errorf("mgeko image_quality = %d, want the global 85", q)
+	}
+	if q := Global().ImageQuality; q != 85 {
+		t.Errorf("global image_quality = %d, want 85", q)
+	}
+	if h := Global().Headers; len(h) != 1 {
+		t.Errorf("global headers = %v, the per-site headers leaked", h)
+	}
+
+	// without a config file the built-in defaults apply
+	Configure(Layers{})
+	if q := Site("rizzfables").ImageQuality; q != 75 {
+		t.Errorf("default rizzfables image_quality = %d, want 75", q)
+	}
+	if scroll := Site("orv").Browser.Scroll; scroll == nil || !*scroll {
+		t.Error("orv is not scrolled by default")
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("SCRAPE_IMAGE_QUALITY", "60")
+	t.Setenv("SCRAPE_RATE_LIMIT", "2s")
+	t.Setenv("SCRAPE_CHROME_URL", "ws://chrome:9222/")
+
+	s, err := Env()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ImageQuality != 60 || s.RateLimit != Duration(2*time.Second) || s.Browser.URL != "ws://chrome:9222/" {
+		t.Errorf("Env() = %+v", s)
+	}
+
+	t.Setenv("SCRAPE_CONCURRENCY", "many")
+	if _, err := Env(); err == nil || !strings.Contains(err.Error(), "SCRAPE_CONCURRENCY") {
+		t.Errorf("Env() error = %v, want an invalid SCRAPE_CONCURRENCY error", err)
+	}
+}
+
+func TestProxySettings(t *testing.T) {
+	t.Setenv("SCRAPE_PROXIES", "http://a:8080, socks5://b:1080")
+	env, err := Env()
+	if err != nil {
+		t.Fatal(err)
+	}
+	Configure(Layers{File: &Config{
+		Settings: Settings{Proxy: "http://file:8080", TLS: TLS{MinVersion: "1.2"}},
+		Sites:    map[string]Settings{"kunmanga": {Proxy: "https://site:8443", TLS: TLS{CABundle: "ca.pem"}}},
+	}, Env: env})
+	defer Configure(Layers{})
+
+	if got := Global().ProxyList(); !slices.Equal(got, []string{"http://a:8080", "socks5://b:1080"}) {
+		t.Errorf("global proxies = %v, want the SCRAPE_PROXIES list over the file's proxy", got)
+	}
+	// the environment is applied over the site's own settings
+	s := Site("kunmanga")
+	if got := s.ProxyList(); len(got) != 2 {
+		t.Errorf("kunmanga proxies = %v, want the SCRAPE_PROXIES list", got)
+	}
+	if s.TLS.CABundle != "ca.pem" || s.TLS.MinVersion != "1.2" {
+		t.Errorf("kunmanga TLS = %+v, want its CA bundle with the global min version", s.TLS)
+	}
+
+	for _, proxy := range []string{"ftp://a:21", "127.0.0.1:8080", "http://"} {
+		if err := ValidateProxy(proxy); err == nil {
+			t.Errorf("ValidateProxy(%q) = nil, want an error", proxy)
+		}
+	}
+	if v, err := TLSVersion("1.3"); err != nil || v != tls.VersionTLS13 {
+		t.Errorf("TLSVersion(1.3) = %v, %v", v, err)
+	}
+	if _, err := TLSVersion("1.4"); err == nil {
+		t.Error("TLSVersion(1.4) = nil error, want an error")
+	}
+}
+
+func TestLoginCredentials(t *testing.T) {
+	Configure(Layers{File: &Config{Sites: map[string]Settings{
+		"kunmanga": {Login: Login{Username: "reader", PasswordEnv: "KUNMANGA_PASS"}},
+		"manhuaus": {Login: Login{Username: "reader", Password: "from-file"}},
+	}}})
+	defer Configure(Layers{})
+	t.Setenv("KUNMANGA_PASS", "from-env")
+
+	if username, password := Site("kunmanga").Login.Credentials(); username != "reader" || password != "from-env" {
+		t.Errorf("kunmanga credentials = %q, %q; want the password from KUNMANGA_PASS", username, password)
+	}
+
+	// the site's environment variables are above the config file
+	t.Setenv("SCRAPE_MANHUAUS_PASSWORD", "from-site-env")
+	if _, password := Site("manhuaus").Login.Credentials(); password != "from-site-env" {
+		t.Errorf("manhuaus password = %q, want SCRAPE_MANHUAUS_PASSWORD", password)
+	}
+	if username, _ := Global().Login.Credentials(); username != "" {
+		t.Errorf("global username = %q, the per-site account leaked", username)
+	}
+}
+
+func TestSeriesDir(t *testing.T) {
+	tests := []struct {
+		settings Settings
+		want     string
+	}{
+		{Settings{NameTemplate: "{{.Series}}"}, "."},
+		{Settings{Output: "/manga", NameTemplate: "{{.Series}}"}, filepath.Join("/manga", "ugly-complex")},
+		{Settings{Output: "/manga", NameTemplate: "{{.Site}}/{{.Series}}"}, filepath.Join("/manga", "kunmanga", "ugly-complex")},
+	}
+	for _, tt := range tests {
+		got, err := tt.settings.SeriesDir("kunmanga", "ugly-complex")
+		if err != nil || got != tt.want {
+			t.Errorf("SeriesDir(%+v) = %q, %v; want %q", tt.settings, got, err, tt.want)
+		}
+	}
+
+	if _, err := (Settings{Output: "/manga", NameTemplate: "{{.Title}}"}).SeriesDir("kunmanga", "ugly-complex"); err == nil {
+		t.Error("SeriesDir with an unknown template field succeeded, want an error")
+	}
+}
@@ -0,0 +1,173 @@
+// per-chapter failure bundles (page HTML, screenshot, console output, headers and the selector that matched nothing)
+// written to the --debug-dir so a broken scraper can be reported with everything needed to fix it
+package debugbundle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxPages is the number of recent page snapshots kept for the next bundle
+const maxPages = 5
+
+// Exchange is the request and response headers of one request made while loading a page
+type Exchange struct {
+	Method          string            `json:"method,omitempty"`
+	URL             string            `json:"url"`
+	Status          int               `json:"status,omitempty"`
+	RequestHeaders  map[string]string `json:"request_headers,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+}
+
+// Page is a snapshot of a page as fetched by a scraper, over HTTP or in a browser tab
+type Page struct {
+	URL        string
+	Time       time.Time
+	HTML       string
+	Screenshot []byte
+	Console    []string
+	Exchanges  []Exchange
+}
+
+// NoMatchError is returned by the scrapers when a selector matched nothing on a page, the selector is written to the
+// failure bundle.
+type NoMatchError struct {
+	URL      string
+	Selector string
+	What     string
+}
+
+func (e *NoMatchError) Error() string {
+	return fmt.Sprintf("no %s found at %s (selector %q)", e.What, e.URL, e.Selector)
+}
+
+// NoMatch returns a NoMatchError for what (eg: "images") not found with selector on the page at url.
+func NoMatch(url, selector, what string) error {
+	return &NoMatchError{URL: url, Selector: selector, What: what}
+}
+
+var (
+	mu    sync.Mutex
+	dir   string
+	pages []*Page
+)
+
+// SetDir enables the failure bundles, written to subdirectories of d. An empty d disables them.
+func SetDir(d string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	dir = d
+}
+
+// Enabled reports whether failure bundles are written, the scrapers only take page snapshots when they are.
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return dir != ""
+}
+
+// Record keeps a page snapshot for the next failure bundle, only the most recent snapshots are kept.
+func Record(p *Page) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if dir == "" {
+		return
+	}
+	if p.Time.IsZero() {
+		p.Time = time.Now()
+	}
+	pages = append(pages, p)
+	if len(pages) > maxPages {
+		pages = pages[len(pages)-maxPages:]
+	}
+}
+
+// Reset drops the page snapshots, called before each chapter so a bundle only holds the pages of its chapter.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	pages = nil
+}
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// Write saves a failure bundle for a chapter of source into its own directory under the debug dir: the error, the
+// chapter and any selector that matched nothing in error.txt, and for every recorded page its HTML, screenshot,
+// console output and headers. Returns the bundle directory, or "" when bundles are disabled.
+func Write(source, chapter, chapterURL string, failure error) (string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if dir == "" {
+		return "", nil
+	}
+
+	name := fmt.Sprintf("%s-%s-%s", source, chapter, time.Now().Format("20060102-150405"))
+	bundle := filepath.Join(dir, unsafeChars.ReplaceAllString(name, "_"))
+	if err := os.MkdirAll(bundle, 0755); err != nil {
+		return "", err
+	}
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "source:  %s\nchapter: %s\nurl:     %s\ntime:    %s\nerror:   %v\n", source, chapter, chapterURL,
+		time.Now().Format(time.RFC3339), failure)
+	var noMatch *NoMatchError
+	if errors.As(failure, &noMatch) {
+		fmt.Fprintf(&summary, "selector: %s (matched nothing at %s)\n", noMatch.Selector, noMatch.URL)
+	}
+	fmt.Fprintf(&summary, "pages:   %d\n", len(pages))
+	for i, p := range pages {
+		fmt.Fprintf(&summary, "  page-%d: %s (%s)\n", i+1, p.URL, p.Time.Format(time.RFC3339))
+	}
+
+	files := map[string][]byte{"error.txt": []byte(summary.String())}
+	for i, p := range pages {
+		prefix := fmt.Sprintf("page-%d", i+1)
+		if p.HTML != "" {
+			files[prefix+".html"] = []byte(p.HTML)
+		}
+		if len(p.Screenshot) > 0 {
+			files[prefix+".png"] = p.Screenshot
+		}
+		if len(p.Console) > 0 {
+			files[prefix+"-console.log"] = []byte(strings.Join(p.Console, "\n") + "\n")
+		}
+		if len(p.Exchanges) > 0 {
+			data, err := json.MarshalIndent(p.Exchanges, "", "  ")
+			if err == nil {
+				files[prefix+"-headers.json"] = data
+			}
+		}
+	}
+
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(bundle, name), data, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	pages = nil
+	slog.Info("debug bundle written", "site", source, "chapter", chapter, "dir", bundle)
+	return bundle, nil
+}
+
+// Headers flattens HTTP style headers into a single value per name
+func Headers(h map[string][]string) map[string]string {
+	flat := make(map[string]string, len(h))
+	for name, values := range h {
+		flat[name] = strings.Join(values, ", ")
+	}
+	return flat
+}
@@ -0,0 +1,59 @@
+package debugbundle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	SetDir(dir)
+	defer SetDir("")
+
+	Record(&Page{
+		URL:       "https://example.com/chapter-1",
+		HTML:      "<html></html>",
+		Console:   []string{"log: hello"},
+		Exchanges: []Exchange{{Method: "GET", URL: "https://example.com/chapter-1", Status: 200}},
+	})
+
+	failure := fmt.Errorf("chapter 1: %w", NoMatch("https://example.com/chapter-1", "div.reading-content img", "images"))
+	bundle, err := Write("example", "1", "https://example.com/chapter-1", failure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(bundle) != dir {
+		t.Fatalf("bundle %s not written under %s", bundle, dir)
+	}
+
+	for _, name := range []string{"error.txt", "page-1.html", "page-1-console.log", "page-1-headers.json"} {
+		if _, err := os.Stat(filepath.Join(bundle, name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+	summary, err := os.ReadFile(filepath.Join(bundle, "error.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(summary), "selector: div.reading-content img") {
+		t.Errorf("error.txt does not name the selector:\n%s", summary)
+	}
+
+	// the snapshots are used up by a bundle
+	if bundle, _ := Write("example", "2", "", failure); bundle == "" {
+		t.Fatal("expected a bundle")
+	} else if _, err := os.Stat(filepath.Join(bundle, "page-1.html")); !os.IsNotExist(err) {
+		t.Errorf("second bundle holds the pages of the first")
+	}
+}
+
+func TestWriteDisabled(t *testing.T) {
+	SetDir("")
+	Record(&Page{URL: "https://example.com"})
+	if bundle, err := Write("example", "1", "", fmt.Errorf("failed")); bundle != "" || err != nil {
+		t.Fatalf("Write() = %q, %v with bundles disabled", bundle, err)
+	}
+}
@@ -0,0 +1,301 @@
+package fakesite_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"scrape/auth"
+	"scrape/fakesite"
+	"scrape/parser"
+	"scrape/replay/replaytest"
+	"scrape/rizzfables"
+	"scrape/sources"
+	"scrape/webClient"
+	"slices"
+	"testing"
+	"time"
+)
+
+// serve starts the fake site and routes the requests for the sites' hosts to it for the rest of the test, the
+// downloads are written to a temp directory
+func serve(t *testing.T, faults ...fakesite.Fault) *fakesite.Server {
+	t.Helper()
+
+	site := fakesite.New()
+	for _, f := range faults {
+		site.AddFault(f)
+	}
+	server := httptest.NewServer(site)
+	t.Cleanup(server.Close)
+
+	previous := http.DefaultTransport
+	http.DefaultTransport = fakesite.Transport(server.URL, map[string]string{
+		"kunmanga.com":                   fakesite.Madara,
+		"manhuaus.com":                   fakesite.Madara,
+		"rizzfables.com":                 fakesite.Themesia,
+		"childhoodfriendofthezenith.org": fakesite.WordPress,
+		"honeylemonsoda.xyz":             fakesite.WordPress,
+	})
+	t.Cleanup(func() { http.DefaultTransport = previous })
+
+	t.Setenv("TMPDIR", t.TempDir())
+	parser.ConfigureStaging(t.TempDir())
+	t.Cleanup(func() { parser.ConfigureStaging("") })
+	t.Chdir(t.TempDir())
+	return site
+}
+
+// download resolves and downloads every chapter of the fake series from the source name
+func download(t *testing.T, name, seriesURL string) ([]parser.Chapter, error) {
+	t.Helper()
+
+	src, err := sources.Get(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chapters, err := src.Resolve(seriesURL, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chapters, src.DownloadChapters(chapters)
+}
+
+const kunmangaSeries = "https://kunmanga.com/manga/fake-series/"
+
+func TestKunmangaDownload(t *testing.T) {
+	site := serve(t)
+
+	chapters, err := download(t, "kunmanga", kunmangaSeries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chapters) != 3 {
+		t.Fatalf("resolved %d chapters, expected 3: %+v", len(chapters), chapters)
+	}
+
+	for _, name := range []string{"ch001.cbz", "ch002.cbz", "ch003.cbz"} {
+		want := []string{"page-001.jpg", "page-002.png", "page-003.webp", "ComicInfo.xml"}
+		if names := replaytest.ArchiveNames(t, name); !slices.Equal(names, want) {
+			t.Errorf("%s holds %v, expected %v", name, names, want)
+		}
+		info, err := parser.ReadComicInfo(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Web == "" || info.Number == "" {
+			t.Errorf("%s has no provenance: %+v", name, info)
+		}
+	}
+
+	// a second run skips the downloaded chapters without visiting them
+	chapters, err = download(t, "kunmanga", kunmangaSeries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range chapters {
+		if ch.Status != parser.StatusDownloaded {
+			t.Errorf("chapter %s is %s on the second run, expected downloaded", ch.Number, ch.Status)
+		}
+	}
+	if n := site.Requests("/manga/fake-series/chapter-1/"); n != 1 {
+		t.Errorf("chapter 1 page requested %d times, expected 1", n)
+	}
+}
+
+func TestKunmangaRetries(t *testing.T) {
+	site := serve(t,
+		fakesite.Fault{Kind: fakesite.FaultStatus, Path: "/images/fake-series/1/2.png", Status: http.StatusServiceUnavailable, Times: 1},
+		fakesite.Fault{Kind: fakesite.FaultTruncate, Path: "/images/fake-series/2/1.jpg", Times: 1},
+		fakesite.Fault{Kind: fakesite.FaultSlow, Path: "/images/fake-series/3/", Delay: 100 * time.Millisecond},
+	)
+
+	if _, err := download(t, "kunmanga", kunmangaSeries); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"ch001.cbz", "ch002.cbz", "ch003.cbz"} {
+		if names := replaytest.ArchiveNames(t, name); len(names) != 4 {
+			t.Errorf("%s holds %v, expected 3 pages and ComicInfo.xml", name, names)
+		}
+	}
+	for _, path := range []string{"/images/fake-series/1/2.png", "/images/fake-series/2/1.jpg"} {
+		if n := site.Requests(path); n != 2 {
+			t.Errorf("%s requested %d times, expected 2", path, n)
+		}
+	}
+}
+
+func TestMissingChapter(t *testing.T) {
+	serve(t, fakesite.Fault{Kind: fakesite.FaultMissing, Path: "/manga/fake-series/chapter-2/"})
+
+	if _, err := download(t, "kunmanga", kunmangaSeries); err == nil {
+		t.Error("expected an error for the missing chapter")
+	}
+
+	// the failed chapter leaves no archive behind, the others still download
+	if _, err := os.Stat("ch002.cbz"); !os.IsNotExist(err) {
+		t.Errorf("ch002.cbz exists for the missing chapter: %v", err)
+	}
+	for _, name := range []string{"ch001.cbz", "ch003.cbz"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// loginSource is kunmanga logging in with the fake site's login form, as the account configured in the environment
+func loginSource(t *testing.T, username, password string) *sources.Source {
+	t.Helper()
+
+	t.Setenv("SCRAPE_KUNMANGA_USERNAME", username)
+	t.Setenv("SCRAPE_KUNMANGA_PASSWORD", password)
+	webClient.ConfigureRequests(func(host string) webClient.RequestOptions {
+		return webClient.RequestOptions{Site: "kunmanga", LoginURL: "https://kunmanga.com" + fakesite.LoginPath}
+	})
+	t.Cleanup(func() { webClient.ConfigureRequests(nil) })
+
+	registered, err := sources.Get("kunmanga")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := *registered
+	src.Login = &auth.FormLogin{
+		LoginURL:      "https://kunmanga.com" + fakesite.LoginPath,
+		Form:          "form#loginform",
+		UsernameField: "log",
+		PasswordField: "pwd",
+	}
+	return &src
+}
+
+func TestLoginExpiredSession(t *testing.T) {
+	site := serve(t)
+	site.RequireLogin("reader", "secret")
+	src := loginSource(t, "reader", "secret")
+
+	chapters, err := src.Resolve(kunmangaSeries, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.DownloadChapters(chapters[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if n := site.Logins(); n != 1 {
+		t.Errorf("logged in %d times, expected once before the first chapter", n)
+	}
+
+	// the next chapter redirects to the login page, the source logs in again and downloads it
+	site.ExpireSessions()
+	if err := src.DownloadChapters(chapters[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if n := site.Logins(); n != 2 {
+		t.Errorf("logged in %d times, expected again after the session expired", n)
+	}
+	for _, name := range []string{"ch001.cbz", "ch002.cbz", "ch003.cbz"} {
+		if names := replaytest.ArchiveNames(t, name); len(names) != 4 {
+			t.Errorf("%s holds %v, expected 3 pages and ComicInfo.xml", name, names)
+		}
+	}
+}
+
+func TestLoginRejected(t *testing.T) {
+	site := serve(t)
+	site.RequireLogin("reader", "secret")
+	src := loginSource(t, "reader", "wrong")
+
+	_, err := src.Resolve(kunmangaSeries, ".")
+	if !errors.Is(err, auth.ErrRejected) {
+		t.Fatalf("Resolve() error = %v, expected the login to be rejected", err)
+	}
+	// the rejected login is not retried
+	if _, err := src.Resolve(kunmangaSeries, "."); !errors.Is(err, auth.ErrRejected) {
+		t.Errorf("second Resolve() error = %v, expected the same login failure", err)
+	}
+	if n := site.Requests(fakesite.LoginPath); n != 2 {
+		t.Errorf("login page requested %d times, expected 2: the form and one POST", n)
+	}
+}
+
+func TestCfotzConvertsToJPG(t *testing.T) {
+	serve(t, fakesite.Fault{Kind: fakesite.FaultHTMLImage, Path: "/images/fake-series/2/2.png"})
+
+	if _, err := download(t, "cfotz", ""); err == nil {
+		t.Error("expected an error for the chapter with a page answered with HTML")
+	}
+
+	want := []string{"001.jpg", "002.jpg", "003.jpg", "ComicInfo.xml"}
+	if names := replaytest.ArchiveNames(t, "ch001.cbz"); !slices.Equal(names, want) {
+		t.Errorf("ch001.cbz holds %v, expected %v", names, want)
+	}
+
+	// a page answered with HTML fails its chapter, no archive is written with the page missing
+	if _, err := os.Stat("ch002.cbz"); !os.IsNotExist(err) {
+		t.Errorf("ch002.cbz exists with a page missing: %v", err)
+	}
+}
+
+func TestHLSChapters(t *testing.T) {
+	serve(t)
+
+	chapters, err := download(t, "hls", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chapters) != 3 {
+		t.Fatalf("resolved %d chapters, expected 3: %+v", len(chapters), chapters)
+	}
+	// every page is converted to PNG
+	want := []string{"page-001.png", "page-002.png", "page-003.png", "ComicInfo.xml"}
+	if names := replaytest.ArchiveNames(t, "ch003.cbz"); !slices.Equal(names, want) {
+		t.Errorf("ch003.cbz holds %v, expected %v", names, want)
+	}
+}
+
+func TestThemesiaChapters(t *testing.T) {
+	serve(t, fakesite.Fault{Kind: fakesite.FaultSlow, Path: "/series/", Delay: 100 * time.Millisecond})
+
+	chapters, err := rizzfables.SeriesChapters("https://rizzfables.com/series/fake-series/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var numbers []string
+	for _, ch := range chapters {
+		numbers = append(numbers, ch.Number)
+		if ch.Title != "Chapter "+ch.Number || ch.Released.IsZero() {
+			t.Errorf("chapter %+v, expected a title and a release date", ch)
+		}
+	}
+	if want := []string{"1", "2", "3"}; !slices.Equal(numbers, want) {
+		t.Errorf("SeriesChapters() returned chapters %v, expected %v", numbers, want)
+	}
+}
+
+func TestParseFault(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    fakesite.Fault
+		wantErr bool
+	}{
+		{spec: "503:/images/:2", want: fakesite.Fault{Kind: fakesite.FaultStatus, Path: "/images/", Status: 503, Times: 2}},
+		{spec: "slow=2s:/manga/", want: fakesite.Fault{Kind: fakesite.FaultSlow, Path: "/manga/", Delay: 2 * time.Second}},
+		{spec: "truncate:/images/fake-series/1/1.jpg", want: fakesite.Fault{Kind: fakesite.FaultTruncate, Path: "/images/fake-series/1/1.jpg"}},
+		{spec: "missing", wantErr: true},
+		{spec: "200:/manga/", wantErr: true},
+		{spec: "slow=soon:/manga/", wantErr: true},
+		{spec: "missing:/manga/:-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := fakesite.ParseFault(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFault(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseFault(%q) = %+v, expected %+v", tt.spec, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+// fake manga site for end to end tests: serves the Madara, MangaThemesia and generic WordPress page structures the
+// scrapers parse, with generated page images and injectable faults
+package fakesite
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chai2010/webp"
+)
+
+// The site layouts, each served under its own path prefix
+const (
+	Madara    = "/madara"
+	Themesia  = "/themesia"
+	WordPress = "/wordpress"
+)
+
+// BaseHeader is the scheme and host the scrapers asked for, set by Transport. The links in the pages are built on it
+// so the scrapers see their own site.
+const BaseHeader = "X-Fake-Site-Base"
+
+// Series is a series served in every layout
+type Series struct {
+	Slug     string
+	Title    string
+	Chapters []string
+	// Pages is the number of pages of every chapter, the page images cycle through jpg, png and webp
+	Pages int
+}
+
+// DefaultSeries is served when New is given no series
+var DefaultSeries = []Series{{Slug: "fake-series", Title: "Fake Series", Chapters: []string{"1", "2", "3"}, Pages: 3}}
+
+// Server is the fake site, an http.Handler
+type Server struct {
+	series []Series
+
+	mu       sync.Mutex
+	faults   []*fault
+	requests map[string]int
+	images   map[string][]byte
+
+	// the account of RequireLogin, its sessions and the number of logins
+	username string
+	password string
+	sessions map[string]bool
+	logins   int
+}
+
+// New returns a fake site serving series, or DefaultSeries when there are none
+func New(series ...Series) *Server {
+	if len(series) == 0 {
+		series = DefaultSeries
+	}
+	return &Server{series: series, requests: make(map[string]int), images: make(map[string][]byte)}
+}
+
+// Requests returns the number of requests made for path (without the layout prefix), eg: /manga/fake-series/chapter-1/
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[path]
+}
+
+var (
+	madaraChapter   = regexp.MustCompile(`^/manga/([^/]+)/chapter-([\d.]+)/$`)
+	madaraSeries    = regexp.MustCompile(`^/manga/([^/]+)/$`)
+	themesiaSeries  = regexp.MustCompile(`^/series/([^/]+)/$`)
+	chapterPage     = regexp.MustCompile(`^/([^/]+)-chapter-([\d-]+)/$`)
+	imagePath       = regexp.MustCompile(`^/images/([^/]+)/([\d.]+)/(\d+)\.(jpg|png|webp)$`)
+	layoutPrefixes  = []string{Madara, Themesia, WordPress}
+	imageExtensions = []string{"jpg", "png", "webp"}
+)
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	layout, path := "", r.URL.Path
+	for _, prefix := range layoutPrefixes {
+		if rest, ok := strings.CutPrefix(r.URL.Path, prefix); ok && (rest == "" || rest[0] == '/') {
+			layout, path = prefix, rest
+			break
+		}
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	s.mu.Lock()
+	s.requests[path]++
+	f := s.fault(path)
+	s.mu.Unlock()
+
+	base := r.Header.Get(BaseHeader)
+	if base == "" {
+		base = "http://" + r.Host + layout
+	}
+	slog.Debug("fakesite request", "method", r.Method, "path", layout+path)
+
+	if f != nil && f.apply(w, r) {
+		return
+	}
+
+	if layout != "" && path == LoginPath {
+		s.login(w, r, base)
+		return
+	}
+	if s.gated(r, layout, path) {
+		http.Redirect(w, r, base+LoginPath, http.StatusFound)
+		return
+	}
+
+	switch {
+	case layout == "":
+		s.index(w)
+	case imagePath.MatchString(path):
+		m := imagePath.FindStringSubmatch(path)
+		page, _ := strconv.Atoi(m[3])
+		s.image(w, m[1], m[2], page, m[4])
+	case layout == Madara && madaraChapter.MatchString(path):
+		m := madaraChapter.FindStringSubmatch(path)
+		s.chapter(w, layout, base, m[1], m[2])
+	case layout == Madara && madaraSeries.MatchString(path):
+		s.seriesPage(w, layout, base, madaraSeries.FindStringSubmatch(path)[1])
+	case layout == Themesia && themesiaSeries.MatchString(path):
+		s.seriesPage(w, layout, base, themesiaSeries.FindStringSubmatch(path)[1])
+	case layout == WordPress && path == "/":
+		s.seriesPage(w, layout, base, s.series[0].Slug)
+	case (layout == Themesia || layout == WordPress) && chapterPage.MatchString(path):
+		m := chapterPage.FindStringSubmatch(path)
+		s.chapter(w, layout, base, m[1], strings.ReplaceAll(m[2], "-", "."))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) find(slug string) (Series, bool) {
+	for _, series := range s.series {
+		if series.Slug == slug {
+			return series, true
+		}
+	}
+	return Series{}, false
+}
+
+type chapterLink struct {
+	Number string
+	URL    string
+	Date   string
+}
+
+// chapterURL is the chapter page of a layout, chapter numbers are written with a dash in the Themesia and WordPress
+// slugs (eg: fake-series-chapter-2-5)
+func chapterURL(layout, base, slug, number string) string {
+	if layout == Madara {
+		return fmt.Sprintf("%s/manga/%s/chapter-%s/", base, slug, number)
+	}
+	return fmt.Sprintf("%s/%s-chapter-%s/", base, slug, strings.ReplaceAll(number, ".", "-"))
+}
+
+// seriesPage lists the chapters newest first, like the sites do
+func (s *Server) seriesPage(w http.ResponseWriter, layout, base, slug string) {
+	series, ok := s.find(slug)
+	if !ok {
+		http.Error(w, "series not found", http.StatusNotFound)
+		return
+	}
+
+	var chapters []chapterLink
+	released := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i := len(series.Chapters) - 1; i >= 0; i-- {
+		number := series.Chapters[i]
+		chapters = append(chapters, chapterLink{
+			Number: number,
+			URL:    chapterURL(layout, base, slug, number),
+			Date:   released.AddDate(0, 0, 7*i).Format("January 2, 2006"),
+		})
+	}
+
+	render(w, seriesTemplates[layout], map[string]any{"Series": series, "Chapters": chapters})
+}
+
+func (s *Server) chapter(w http.ResponseWriter, layout, base, slug, number string) {
+	series, ok := s.find(slug)
+	if !ok || !containsChapter(series, number) {
+		http.Error(w, "chapter not found", http.StatusNotFound)
+		return
+	}
+
+	var images []string
+	for page := 1; page <= series.Pages; page++ {
+		ext := imageExtensions[(page-1)%len(imageExtensions)]
+		images = append(images, fmt.Sprintf("%s/images/%s/%s/%d.%s", base, slug, number, page, ext))
+	}
+
+	render(w, chapterTemplates[layout], map[string]any{"Series": series, "Number": number, "Images": images})
+}
+
+func containsChapter(series Series, number string) bool {
+	for _, n := range series.Chapters {
+		if n == number {
+			return true
+		}
+	}
+	return false
+}
+
+// image serves a generated page image, the color is derived from the chapter and page so every page differs
+func (s *Server) image(w http.ResponseWriter, slug, number string, page int, ext string) {
+	key := fmt.Sprintf("%s/%s/%d.%s", slug, number, page, ext)
+
+	s.mu.Lock()
+	data, ok := s.images[key]
+	s.mu.Unlock()
+
+	if !ok {
+		var err error
+		if data, err = generateImage(number, page, ext); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.mu.Lock()
+		s.images[key] = data
+		s.mu.Unlock()
+	}
+
+	contentType := map[string]string{"jpg": "image/jpeg", "png": "image/png", "webp": "image/webp"}[ext]
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+func generateImage(number string, page int, ext string) ([]byte, error) {
+	chapter, _ := strconv.ParseFloat(number, 64)
+	fill := color.RGBA{uint8(int(chapter*40) % 256), uint8(page * 50 % 256), 160, 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, 80, 120))
+	for y := 0; y < 120; y++ {
+		for x := 0; x < 80; x++ {
+			img.Set(x, y, fill)
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch ext {
+	case "jpg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	case "png":
+		err = png.Encode(&buf, img)
+	case "webp":
+		err = webp.Encode(&buf, img, &webp.Options{Lossless: true})
+	}
+	return buf.Bytes(), err
+}
+
+// index links the series of every layout, for browsing the fake site by hand
+func (s *Server) index(w http.ResponseWriter) {
+	render(w, indexTemplate, map[string]any{"Series": s.series})
+}
+
+func render(w http.ResponseWriter, tmpl *template.Template, data any) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// Transport routes the requests for each host to the layout of the fake site at serverURL, eg:
+// {"kunmanga.com": Madara}. Requests for other hosts fail.
+func Transport(serverURL string, hosts map[string]string) http.RoundTripper {
+	return &transport{serverURL: serverURL, hosts: hosts, next: &http.Transport{}}
+}
+
+type transport struct {
+	serverURL string
+	hosts     map[string]string
+	next      http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	layout, ok := t.hosts[req.URL.Hostname()]
+	if !ok {
+		return nil, fmt.Errorf("fakesite: no layout for host %s", req.URL.Host)
+	}
+
+	out := req.Clone(req.Context())
+	target, err := out.URL.Parse(t.serverURL + layout + req.URL.EscapedPath())
+	if err != nil {
+		return nil, err
+	}
+	target.RawQuery = req.URL.RawQuery
+	out.URL = target
+	out.Host = target.Host
+	out.Header.Set(BaseHeader, req.URL.Scheme+"://"+req.URL.Host)
+
+	resp, err := t.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+	return resp, nil
+}
@@ -0,0 +1,133 @@
+package fakesite
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fault kinds
+const (
+	// FaultSlow delays the response by Delay
+	FaultSlow = "slow"
+	// FaultStatus answers with Status, eg: 429 or 503
+	FaultStatus = "status"
+	// FaultHTMLImage answers an image request with an HTML page, like a site showing a challenge page
+	FaultHTMLImage = "html-image"
+	// FaultTruncate sends half of the body with the full Content-Length
+	FaultTruncate = "truncate"
+	// FaultMissing answers with a 404, eg: a chapter removed from the site
+	FaultMissing = "missing"
+)
+
+// Fault is injected into the responses for the paths containing Path, the path is given without the layout prefix,
+// eg: /images/fake-series/1/2.png
+type Fault struct {
+	Kind   string
+	Path   string
+	Status int
+	Delay  time.Duration
+	// Times is how many matching requests get the fault, every one when 0
+	Times int
+}
+
+// fault is an added Fault and the number of times it was injected
+type fault struct {
+	Fault
+	hits int
+}
+
+// AddFault injects f into the matching responses, the first fault added wins when several match
+func (s *Server) AddFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &fault{Fault: f})
+}
+
+// fault returns the fault to inject for path and counts it, s.mu must be held
+func (s *Server) fault(path string) *fault {
+	for _, f := range s.faults {
+		if !strings.Contains(path, f.Path) || (f.Times > 0 && f.hits >= f.Times) {
+			continue
+		}
+		f.hits++
+		return f
+	}
+	return nil
+}
+
+// apply writes the faulty response, it returns false for a slow response which is then served normally
+func (f *fault) apply(w http.ResponseWriter, r *http.Request) bool {
+	switch f.Kind {
+	case FaultSlow:
+		select {
+		case <-time.After(f.Delay):
+		case <-r.Context().Done():
+		}
+		return false
+	case FaultStatus:
+		if f.Status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		http.Error(w, http.StatusText(f.Status), f.Status)
+	case FaultHTMLImage:
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		fmt.Fprint(w, "<!DOCTYPE html><html><head><title>Just a moment...</title></head><body>Checking your browser</body></html>")
+	case FaultTruncate:
+		// hijack the connection so the body can be cut short of its Content-Length
+		body := strings.Repeat("x", 1024)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, body[:len(body)/2])
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+			}
+		}
+	case FaultMissing:
+		http.NotFound(w, r)
+	}
+	return true
+}
+
+// ParseFault parses a fault given as kind:path[:times], the kind being one of the fault kinds, an HTTP status code
+// or slow=<duration>, eg: 503:/images/fake-series/1/:2, slow=3s:/manga/ or missing:/manga/fake-series/chapter-2/
+func ParseFault(spec string) (Fault, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] == "" {
+		return Fault{}, fmt.Errorf("invalid fault %q, expected kind:path[:times]", spec)
+	}
+
+	f := Fault{Kind: parts[0], Path: parts[1]}
+	if len(parts) == 3 {
+		times, err := strconv.Atoi(parts[2])
+		if err != nil || times < 0 {
+			return Fault{}, fmt.Errorf("invalid fault %q: times must be a positive number", spec)
+		}
+		f.Times = times
+	}
+
+	switch {
+	case strings.HasPrefix(f.Kind, FaultSlow+"="):
+		delay, err := time.ParseDuration(strings.TrimPrefix(f.Kind, FaultSlow+"="))
+		if err != nil {
+			return Fault{}, fmt.Errorf("invalid fault %q: %w", spec, err)
+		}
+		f.Kind, f.Delay = FaultSlow, delay
+	case f.Kind == FaultHTMLImage || f.Kind == FaultTruncate || f.Kind == FaultMissing:
+	default:
+		status, err := strconv.Atoi(f.Kind)
+		if err != nil || status < 400 || status > 599 {
+			return Fault{}, fmt.Errorf("invalid fault %q: unknown kind %s", spec, f.Kind)
+		}
+		f.Kind, f.Status = FaultStatus, status
+	}
+	return f, nil
+}
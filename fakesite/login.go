@@ -0,0 +1,98 @@
+package fakesite
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+)
+
+// LoginPath is the login page of every layout, the login form posts to it
+const LoginPath = "/login/"
+
+// SessionCookie holds the session of a logged in account
+const SessionCookie = "wordpress_logged_in"
+
+// loginNonce is the hidden input of the login form, a login without it is rejected like a stale CSRF token
+const loginNonce = "fake-nonce"
+
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html><head><title>Log In</title></head><body>
+{{if .Error}}<div id="login_error">{{.Error}}</div>{{end}}
+<form name="loginform" id="loginform" action="{{.Action}}" method="post">
+<input type="text" name="log" id="user_login" value="">
+<input type="password" name="pwd" id="user_pass" value="">
+<input type="checkbox" name="rememberme" value="forever">
+<input type="hidden" name="_nonce" value="{{.Nonce}}">
+<input type="submit" name="wp-submit" value="Log In">
+</form>
+</body></html>
+`))
+
+// RequireLogin gates the chapter pages behind the account: without a session they redirect to the login page
+func (s *Server) RequireLogin(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.username, s.password = username, password
+	s.sessions = make(map[string]bool)
+}
+
+// ExpireSessions logs every session out, like a site expiring its sessions while a download runs
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.sessions)
+}
+
+// Logins returns the number of successful logins
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.logins
+}
+
+// gated reports whether the request needs a session it does not have
+func (s *Server) gated(r *http.Request, layout, path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.username == "" || layout == "" {
+		return false
+	}
+	if !madaraChapter.MatchString(path) && !chapterPage.MatchString(path) {
+		return false
+	}
+	c, err := r.Cookie(SessionCookie)
+	return err != nil || !s.sessions[c.Value]
+}
+
+// login serves the login form, and on a POST starts a session for the right credentials or shows the form again
+func (s *Server) login(w http.ResponseWriter, r *http.Request, base string) {
+	data := map[string]any{"Action": base + LoginPath, "Nonce": loginNonce}
+	if r.Method != http.MethodPost {
+		render(w, loginTemplate, data)
+		return
+	}
+
+	r.ParseForm()
+	s.mu.Lock()
+	ok := s.username != "" && r.PostForm.Get("log") == s.username && r.PostForm.Get("pwd") == s.password &&
+		r.PostForm.Get("_nonce") == loginNonce
+	var session string
+	if ok {
+		s.logins++
+		session = fmt.Sprintf("session-%d", s.logins)
+		s.sessions[session] = true
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		data["Error"] = "The username or password you entered is incorrect."
+		render(w, loginTemplate, data)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: SessionCookie, Value: session, Path: "/", HttpOnly: true})
+	fmt.Fprint(w, "<!DOCTYPE html><html><head><title>Welcome</title></head><body>Logged in</body></html>")
+}
@@ -0,0 +1,83 @@
+package fakesite
+
+import "html/template"
+
+// the pages only hold the markup the scrapers select on, in the same structure as the real sites
+
+var seriesTemplates = map[string]*template.Template{
+	// Madara, eg: kunmanga and manhuaus
+	Madara: template.Must(template.New("madara-series").Parse(`<!DOCTYPE html>
+<html><head><title>{{.Series.Title}}</title>
+<meta property="og:title" content="{{.Series.Title}}">
+</head><body>
+<div class="post-title"><h1>{{.Series.Title}}</h1></div>
+<div class="listing-chapters_wrap">
+<ul class="main version-chap no-volumn">
+{{range .Chapters}}<li class="wp-manga-chapter"><a href="{{.URL}}">Chapter {{.Number}}</a>
+<span class="chapter-release-date"><i>{{.Date}}</i></span></li>
+{{end}}</ul>
+</div>
+</body></html>
+`)),
+	// MangaThemesia, eg: rizzfables and ravenscans
+	Themesia: template.Must(template.New("themesia-series").Parse(`<!DOCTYPE html>
+<html><head><title>{{.Series.Title}}</title>
+<meta property="og:title" content="{{.Series.Title}}">
+</head><body>
+<h1 class="entry-title">{{.Series.Title}}</h1>
+<div class="eplister" id="chapterlist"><ul class="clstyle">
+{{range .Chapters}}<li data-num="{{.Number}}"><div class="chbox"><div class="eph-num"><a href="{{.URL}}">
+<span class="chapternum">Chapter {{.Number}}</span><span class="chapterdate">{{.Date}}</span></a></div></div></li>
+{{end}}</ul></div>
+</body></html>
+`)),
+	// generic WordPress single series home page, in both the cfotz and hls structures
+	WordPress: template.Must(template.New("wordpress-series").Parse(`<!DOCTYPE html>
+<html><head><title>{{.Series.Title}}</title>
+<meta property="og:title" content="{{.Series.Title}}">
+<meta property="og:description" content="Read {{.Series.Title}} online">
+</head><body>
+<div id="chapters-list-holder">
+{{range .Chapters}}<a class="chapter-list-item" href="{{.URL}}"><span class="chapter-name">Chapter {{.Number}}</span></a>
+{{end}}</div>
+<ul class="chapters">
+{{range .Chapters}}<li class="item"><a href="{{.URL}}">Chapter {{.Number}}</a></li>
+{{end}}</ul>
+</body></html>
+`)),
+}
+
+var chapterTemplates = map[string]*template.Template{
+	Madara: template.Must(template.New("madara-chapter").Parse(`<!DOCTYPE html>
+<html><head><title>{{.Series.Title}} - Chapter {{.Number}}</title></head><body>
+<input type="hidden" id="wp-manga-current-chap" value="chapter-{{.Number}}">
+<div class="reading-content">
+{{range $i, $src := .Images}}<div class="page-break"><img id="image-{{$i}}" src="{{$src}}" data-src="{{$src}}" class="wp-manga-chapter-img"></div>
+{{end}}</div>
+</body></html>
+`)),
+	Themesia: template.Must(template.New("themesia-chapter").Parse(`<!DOCTYPE html>
+<html><head><title>{{.Series.Title}} Chapter {{.Number}}</title></head><body>
+<div id="readerarea">
+{{range .Images}}<img src="{{.}}" class="ts-main-image">
+{{end}}</div>
+</body></html>
+`)),
+	WordPress: template.Must(template.New("wordpress-chapter").Parse(`<!DOCTYPE html>
+<html><head><title>{{.Series.Title}} Chapter {{.Number}}</title></head><body>
+<div id="content">
+{{range .Images}}<figure class="wp-block-image size-full"><img src="{{.}}"></figure>
+{{end}}</div>
+</body></html>
+`)),
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html><head><title>Fake manga site</title></head><body>
+<h1>Fake manga site</h1>
+<ul>
+{{range .Series}}<li>{{.Title}}: <a href="/madara/manga/{{.Slug}}/">Madara</a>, <a href="/themesia/series/{{.Slug}}/">MangaThemesia</a></li>
+{{end}}<li><a href="/wordpress/">WordPress</a></li>
+</ul>
+</body></html>
+`))
@@ -3,142 +3,144 @@ package hls
 import (
 	"fmt"
 	"image/png"
-	"log"
 	"os"
+	"scrape/logging"
 	"strings"
-	"time"
 
-	"net/http"
 	"path/filepath"
+	"scrape/debugbundle"
 	"scrape/parser"
 	"scrape/webClient"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
-func DownloadChapters() {
-	// Step 1: Get chapter map from website
-	chapterMap, err := ChapterUrls()
+var logger = logging.Site("hls")
+
+// SeriesURL is the Honey Lemon Soda home page, every chapter link is one of its li.item entries
+const SeriesURL = "https://honeylemonsoda.xyz/"
+
+// DownloadChapter downloads the chapter images, converts them to PNG and creates the chapter cbz file
+func DownloadChapter(chapter parser.Chapter) error {
+	filename := chapter.Filename
+	chapterURL := chapter.URL
+	logger.Info("downloading chapter", "chapter", chapter.Number, "file", filename, "url", chapterURL)
+
+	// Fetch chapter HTML and extract the image URLs
+	imgURLs, err := ChapterImages(chapterURL)
 	if err != nil {
-		log.Fatalf("Failed to download chapter list: %v", err)
+		return fmt.Errorf("failed to fetch chapter page %s: %w", chapterURL, err)
 	}
 
-	// Step 2: Sort keys descending
-	keys, err := parser.SortKeys(chapterMap)
-	if err != nil {
-		log.Fatalf("Failed to sort chapters: %v", err)
+	if len(imgURLs) == 0 {
+		return fmt.Errorf("%s: %w", filename, debugbundle.NoMatch(chapterURL, "div#content img, div.reading-content img", "images"))
 	}
 
-	// Step 3: Get existing CBZ files to skip already downloaded
-	existing, err := parser.GetDownloadedCBZ(".")
+	// Stage the chapter, its staging directory keeps the images across runs until the CBZ is created
+	stage, err := parser.StageChapter(chapterURL)
 	if err != nil {
-		log.Fatalf("Failed to read current directory: %v", err)
+		return fmt.Errorf("failed to stage %s: %w", filename, err)
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := webClient.NewHTTPClient()
 
-	// Step 4: Loop through each chapter
-	for _, filename := range keys {
-		if existing[filename] {
-			log.Printf("Skipping %s - already exists.", filename)
+	// Download each image using webClient.FetchWithBackoff, skipping the ones already staged
+	fmt.Println("Downloading chapter images...")
+	for i, url := range imgURLs {
+		fileName := parser.PageFilename(i+1, ".png")
+		if stage.Has(fileName) {
+			logger.Debug("image already staged", "chapter", chapter.Number, "file", fileName)
 			continue
 		}
 
-		chapterURL := chapterMap[filename]
-		log.Printf("Downloading %s from %s", filename, chapterURL)
+		req, _ := webClient.NewImageRequest(url, chapterURL)
 
-		// Fetch chapter HTML using webClient.FetchChapterPage
-		pageHTML, err := webClient.FetchChapterPage(chapterURL)
+		bodyBytes, err := webClient.FetchWithBackoff(client, req)
 		if err != nil {
-			log.Printf("Failed to fetch chapter page %s: %v", chapterURL, err)
+			logger.Error("failed to fetch image", "chapter", chapter.Number, "url", url, "error", err)
 			continue
 		}
 
-		snippet := pageHTML
-		if len(pageHTML) > 512 {
-			snippet = pageHTML[:512] // log only first 512 chars
+		img, err := parser.DecodeImageToPng(bodyBytes, url)
+		if err != nil || img == nil {
+			logger.Error("failed to decode image", "chapter", chapter.Number, "url", url, "error", err)
+			continue
 		}
-		log.Printf("HTML snippet for %s:\n%s", filename, snippet)
 
-		// Parse HTML
-		doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
+		filePath := filepath.Join(stage.Dir, fileName)
+
+		outFile, err := os.Create(filePath)
 		if err != nil {
-			log.Printf("Failed to parse chapter HTML %s: %v", chapterURL, err)
+			logger.Error("failed to create file", "chapter", chapter.Number, "file", filePath, "error", err)
 			continue
 		}
 
-		// Extract image URLs (robust: div#content and div.reading-content)
-		var imgURLs []string
-		doc.Find("div#content img, div.reading-content img").Each(func(i int, s *goquery.Selection) {
-			src := strings.TrimSpace(s.AttrOr("data-src", s.AttrOr("src", "")))
-			if src != "" {
-				imgURLs = append(imgURLs, src)
-				log.Printf("Found image %d: %s", i+1, src)
-			}
-		})
-
-		if len(imgURLs) == 0 {
-			log.Printf("No images found for %s", filename)
-			continue
+		err = png.Encode(outFile, img)
+		outFile.Close()
+		if err == nil {
+			err = stage.Add(fileName, url)
 		}
-
-		// Create temporary directory for this chapter
-		tmpDir, err := os.MkdirTemp("", "manga_chapter")
 		if err != nil {
-			log.Printf("Failed to create temp dir for %s: %v", filename, err)
+			logger.Error("failed to save image", "chapter", chapter.Number, "url", url, "error", err)
 			continue
 		}
-		// Download each image using webClient.FetchWithBackoff
-		fmt.Println("Downloading chapter images...")
-		for i, url := range imgURLs {
-			req, _ := http.NewRequest("GET", url, nil)
-
-			bodyBytes, err := webClient.FetchWithBackoff(client, req)
-			if err != nil {
-				log.Printf("Failed to fetch image %s: %v", url, err)
-				continue
-			}
-
-			img, err := parser.DecodeImageToPng(bodyBytes, url)
-			if err != nil || img == nil {
-				log.Printf("Failed to decode image %s: %v", url, err)
-				continue
-			}
-
-			fileName := fmt.Sprintf("page-%03d.png", i+1)
-			filePath := filepath.Join(tmpDir, fileName)
-
-			outFile, err := os.Create(filePath)
-			if err != nil {
-				log.Printf("Failed to create file %s: %v", filePath, err)
-				continue
-			}
-
-			if err := png.Encode(outFile, img); err != nil {
-				log.Printf("Failed to save image %s: %v", url, err)
-			}
-			outFile.Close()
-			log.Printf("Saved image %s", fileName)
-		}
+		logger.Debug("saved image", "chapter", chapter.Number, "file", fileName)
+	}
 
-		// Create CBZ
-		if err := parser.CreateCbzFromDir(tmpDir, filename); err != nil {
-			log.Printf("Failed to create CBZ %s: %v", filename, err)
-		} else {
-			fmt.Printf("Downloaded: %s\n", filename)
-		}
+	// Create CBZ
+	if err := stage.Archive(filename, len(imgURLs)); err != nil {
+		return fmt.Errorf("failed to create CBZ %s: %w", filename, err)
+	}
+	fmt.Printf("Downloaded: %s\n", filename)
+
+	return nil
+}
+
+// SeriesChapters returns the chapters of ChapterUrls sorted by number, seriesURL is ignored as the site has one series
+func SeriesChapters(seriesURL string) ([]parser.Chapter, error) {
+	chapterMap, err := ChapterUrls()
+	if err != nil {
+		return nil, err
+	}
+	return parser.ChaptersFromMap(chapterMap), nil
+}
+
+// ChapterImages fetches the chapter page HTML using webClient.FetchChapterPage and returns the page image URLs
+func ChapterImages(chapterURL string) ([]string, error) {
+	pageHTML, err := webClient.FetchChapterPage(chapterURL)
+	if err != nil {
+		return nil, err
+	}
 
-		// Delete temp directory
-		os.RemoveAll(tmpDir)
+	snippet := pageHTML
+	if len(pageHTML) > 512 {
+		snippet = pageHTML[:512] // log only first 512 chars
 	}
+	logger.Debug("chapter page", "url", chapterURL, "html", snippet)
+
+	// Parse HTML
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse chapter HTML %s: %w", chapterURL, err)
+	}
+
+	// Extract image URLs (robust: div#content and div.reading-content)
+	var imgURLs []string
+	doc.Find("div#content img, div.reading-content img").Each(func(i int, s *goquery.Selection) {
+		src := strings.TrimSpace(s.AttrOr("data-src", s.AttrOr("src", "")))
+		if src != "" {
+			imgURLs = append(imgURLs, src)
+			logger.Debug("found image", "url", chapterURL, "page", i+1, "image", src)
+		}
+	})
+
+	return imgURLs, nil
 }
 
 // get teh chapter URls, using backup func from webclient
 func ChapterUrls() (map[string]string, error) {
-	baseURL := "https://honeylemonsoda.xyz/"
-
 	// Reuse your existing retry/backoff function to fetch the HTML
-	pageHTML, err := webClient.FetchChapterPage(baseURL)
+	pageHTML, err := webClient.FetchChapterPage(SeriesURL)
 	if err != nil {
 		return nil, err
 	}
@@ -193,3 +195,13 @@ func ChapterFileName(chapterNumber string) string {
 	return fmt.Sprintf("ch%s.cbz", paddedNum)
 
 }
+
+// SeriesInfo returns the Honey Lemon Soda series info from the home page, the title and cover come from its og: tags
+func SeriesInfo(seriesURL string) (*parser.SeriesInfo, error) {
+	doc, err := webClient.FetchDocument(seriesURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.OpenGraphSeriesInfo(doc, seriesURL), nil
+}
@@ -0,0 +1,61 @@
+package hls
+
+import (
+	"path/filepath"
+	"scrape/parser"
+	"scrape/replay/replaytest"
+	"slices"
+	"testing"
+)
+
+func TestSeriesChapters(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	chapters, err := SeriesChapters(SeriesURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []parser.Chapter{
+		{Number: "1", Filename: "ch001.cbz", URL: SeriesURL + "manga/honey-lemon-soda-chapter-1/"},
+		{Number: "25", Filename: "ch025.cbz", URL: SeriesURL + "manga/honey-lemon-soda-chapter-25/"},
+	}
+	if !slices.EqualFunc(chapters, want, sameChapter) {
+		t.Errorf("SeriesChapters() = %+v, expected %+v", chapters, want)
+	}
+}
+
+func TestChapterImages(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	images, err := ChapterImages(SeriesURL + "manga/honey-lemon-soda-chapter-1/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://cdn.honeylemonsoda.xyz/chapter-1/001.jpg",
+		"https://cdn.honeylemonsoda.xyz/chapter-1/002.png",
+	}
+	if !slices.Equal(images, want) {
+		t.Errorf("ChapterImages() = %v, expected %v", images, want)
+	}
+}
+
+func TestDownloadChapter(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+	t.Chdir(t.TempDir())
+
+	err := DownloadChapter(parser.Chapter{Number: "1", Filename: "ch001.cbz", URL: SeriesURL + "manga/honey-lemon-soda-chapter-1/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := replaytest.ArchiveNames(t, "ch001.cbz")
+	if want := []string{"page-001.png", "page-002.png"}; !slices.Equal(names, want) {
+		t.Errorf("ch001.cbz holds %v, expected %v", names, want)
+	}
+}
+
+func sameChapter(a, b parser.Chapter) bool {
+	return a.Number == b.Number && a.Filename == b.Filename && a.URL == b.URL
+}
@@ -3,8 +3,6 @@
 package iluim
 
 import (
-	"archive/zip"
-	"context"
 	"fmt"
 	"github.com/chromedp/chromedp"
 	"github.com/gocolly/colly"
@@ -13,20 +11,25 @@ import (
 	_ "image/gif"
 	"image/jpeg"
 	_ "image/png"
-	"io"
-	"log"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"scrape/browser"
+	"scrape/debugbundle"
+	"scrape/logging"
 	"scrape/parser"
-	"sort"
+	"scrape/webClient"
 	"strconv"
 	"strings"
 	"time"
 )
 
+var logger = logging.Site("iluim")
+
+// SeriesURL is the Infinite Level Up home page, its Chapters_List widget links every chapter
+const SeriesURL = "https://infinitelevelup.com/"
+
 func extractChapterNumber(href string) string {
 	// Extracts chapter numbers from paths like:
 	// "/chapter-3", "/chapter-45.5", "/chapter-76-5", etc.
@@ -58,206 +61,175 @@ func extractChapterNumber(href string) string {
 	return "ch" + padded
 }
 
-func DownloadChapters(chapterURLs []string) error {
-	chapterMap := make(map[string]string)
-
-	fmt.Println("Retrieving chapter list...")
-	for _, url := range chapterURLs {
-		chapterNum := extractChapterNumber(url)
-		if chapterNum == "" {
-			log.Printf("Warning: could not extract chapter number from URL: %s", url)
-			fmt.Printf("Warning: could not extract chapter number from URL: %s", url)
-			continue
-		}
-		chapterMap[chapterNum] = url
-	}
-
-	// get a list of the chapter in the current directory
-	downloadedChapters, err := parser.FileList(".")
+// SeriesChapters returns the chapters linked from the Infinite Level Up home page, named like the downloads
+func SeriesChapters(mangaURL string) ([]parser.Chapter, error) {
+	chapterURLs, err := ChapterURLs(mangaURL)
 	if err != nil {
-		log.Fatalf("error getting file list: %v", err)
+		return nil, err
 	}
 
-	for _, chName := range downloadedChapters {
-		// trim ext instead of split so it does not remove the in between chapters eg .5 .6 etc
-		baseName := strings.TrimSuffix(chName, ".cbz")
-
-		// remove chapter number from the chapter map if it is already downloaded
-		if _, ok := chapterMap[baseName]; ok {
-			delete(chapterMap, baseName)
-			log.Printf("%s already downloaded, removed from chapterMap", baseName)
-		}
+	var chapters []parser.Chapter
+	for chapterNum, url := range chapterMap(chapterURLs) {
+		chapters = append(chapters, parser.Chapter{
+			Number:   parser.ChapterNumber(chapterNum),
+			Filename: chapterNum + ".cbz",
+			URL:      url,
+		})
 	}
+	parser.SortChapters(chapters)
+
+	return chapters, nil
+}
 
-	// Sort keys for consistent ordering
-	keys := make([]string, 0, len(chapterMap))
-	for k := range chapterMap {
-		keys = append(keys, k)
+// ChapterImages loads the chapter page in the browser and returns the lazy loaded (data-src) page image URLs, minus
+// any icons or social media images
+func ChapterImages(chapterURL string) ([]string, error) {
+	var html string
+	if err := browser.Run(30*time.Second,
+		chromedp.Navigate(chapterURL),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		chromedp.Sleep(1*time.Second/2), // sleep 500ms?
+		chromedp.OuterHTML("html", &html),
+	); err != nil {
+		return nil, err
 	}
-	sort.Strings(keys)
 
-	for _, chapterNum := range keys {
-		chapterURL := chapterMap[chapterNum]
+	re := regexp.MustCompile(`data-src=["'](https?://[^"']+\.(?:jpg|jpeg|png|webp))["']`)
+	matches := re.FindAllStringSubmatch(html, -1)
 
-		fmt.Println("Starting download for chapter: ", chapterNum)
+	var imageURLs []string
+	for _, match := range matches {
+		imgURL := match[1]
+		lowerURL := strings.ToLower(imgURL)
 
-		tmpDir, err := os.MkdirTemp("", "chapter-"+chapterNum)
-		if err != nil {
-			log.Printf("Failed to create temp dir for %s: %v", chapterNum, err)
-			fmt.Printf("Failed to create temp dir for %s: %v", chapterNum, err)
-			continue
+		// Skip common icon or social media domains or filenames
+		skip := false
+		skipPatterns := []string{
+			"facebook", "twitter", "linkedin", "pinterest",
+			"icon", "favicon", "logo", "sprite", "social", "avatar",
+		}
+		for _, pattern := range skipPatterns {
+			if strings.Contains(lowerURL, pattern) {
+				logger.Debug("skipping unwanted image", "url", imgURL)
+				skip = true
+				break
+			}
 		}
-		defer os.RemoveAll(tmpDir)
-
-		ctx, cancel := chromedp.NewContext(context.Background())
-		defer cancel()
-
-		ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
-		defer cancel()
-
-		var html string
-		if err := chromedp.Run(ctx,
-			chromedp.Navigate(chapterURL),
-			chromedp.WaitReady("body", chromedp.ByQuery),
-			chromedp.Sleep(1*time.Second/2), // sleep 500ms?
-			chromedp.OuterHTML("html", &html),
-		); err != nil {
-			log.Printf("chromedp navigation failed for %s: %v", chapterURL, err)
-			fmt.Printf("chromedp navigation failed for %s: %v", chapterURL, err)
+		if skip {
 			continue
 		}
 
-		re := regexp.MustCompile(`data-src=["'](https?://[^"']+\.(?:jpg|jpeg|png|webp))["']`)
-		matches := re.FindAllStringSubmatch(html, -1)
-		if len(matches) == 0 {
-			log.Printf("no images found for chapter %s at %s", chapterNum, chapterURL)
-			fmt.Printf("no images found for chapter %s at %s", chapterNum, chapterURL)
-			continue
+		// remove erroneous chars  like \n
+		cleanedURL := strings.ReplaceAll(imgURL, "\n", "")
+		cleanedURL = strings.TrimSpace(cleanedURL)
+		// validate URL before trying to use it
+		_, err := url.ParseRequestURI(cleanedURL)
+		if err != nil {
+			logger.Warn("invalid image URL", "url", cleanedURL, "error", err)
+			return nil, err
 		}
 
-		fmt.Printf("Starting download of chapter %s images...\n", chapterNum)
-		var imgPaths []string
-		for i, match := range matches {
-			imgURL := match[1]
-			lowerURL := strings.ToLower(imgURL)
-
-			// Skip common icon or social media domains or filenames
-			skip := false
-			skipPatterns := []string{
-				"facebook", "twitter", "linkedin", "pinterest",
-				"icon", "favicon", "logo", "sprite", "social", "avatar",
-			}
-			for _, pattern := range skipPatterns {
-				if strings.Contains(lowerURL, pattern) {
-					log.Printf("Skipping unwanted image: %s", imgURL)
-					skip = true
-					break
-				}
-			}
-			if skip {
-				continue
-			}
+		imageURLs = append(imageURLs, cleanedURL)
+	}
 
-			// remove erroneous chars  like \n
-			cleanedURL := strings.ReplaceAll(imgURL, "\n", "")
-			cleanedURL = strings.TrimSpace(cleanedURL)
-			// validate URL before trying to use it
-			_, err := url.ParseRequestURI(cleanedURL)
-			if err != nil {
-				log.Printf("Invalid URL: %s → %v", cleanedURL, err)
-				return err
-			}
+	return imageURLs, nil
+}
 
-			resp, err := http.Get(cleanedURL)
-			if err != nil {
-				log.Printf("Failed to download image %s: %v", cleanedURL, err)
-				fmt.Printf("Failed to download image %s: %v", cleanedURL, err)
-				continue
-			}
+// builds the chapter map, key is the chapter name (eg: ch003, ch045.5) and value is the url
+func chapterMap(chapterURLs []string) map[string]string {
+	chapterMap := make(map[string]string)
 
-			img, _, err := image.Decode(resp.Body)
-			resp.Body.Close()
-			if err != nil {
-				log.Printf("Failed to decode image %s: %v", cleanedURL, err)
-				fmt.Printf("Failed to decode image %s: %v", cleanedURL, err)
-				continue
-			}
+	for _, url := range chapterURLs {
+		chapterNum := extractChapterNumber(url)
+		if chapterNum == "" {
+			logger.Warn("could not extract the chapter number", "url", url)
+			fmt.Printf("Warning: could not extract chapter number from URL: %s", url)
+			continue
+		}
+		chapterMap[chapterNum] = url
+	}
 
-			filename := fmt.Sprintf("img%03d.jpg", i+1)
-			fullPath := filepath.Join(tmpDir, filename)
+	return chapterMap
+}
 
-			out, err := os.Create(fullPath)
-			if err != nil {
-				log.Printf("Failed to create file %s: %v", fullPath, err)
-				fmt.Printf("Failed to create file %s: %v", fullPath, err)
-				continue
-			}
-			if err := jpeg.Encode(out, img, &jpeg.Options{Quality: 90}); err != nil {
-				log.Printf("Failed to encode image %s: %v", fullPath, err)
-				fmt.Printf("Failed to encode image %s: %v", fullPath, err)
-			}
-			out.Close()
-			imgPaths = append(imgPaths, fullPath)
-		}
+// DownloadChapter downloads the chapter images, converts them to JPG and creates the chapter cbz file
+func DownloadChapter(chapter parser.Chapter) error {
+	chapterNum := strings.TrimSuffix(chapter.Filename, ".cbz")
+	chapterURL := chapter.URL
 
-		if len(imgPaths) == 0 {
-			log.Printf("no images could be saved for chapter %s at %s", chapterNum, chapterURL)
-			fmt.Printf("no images could be saved for chapter %s at %s", chapterNum, chapterURL)
-			continue
-		}
+	fmt.Println("Starting download for chapter: ", chapterNum)
+
+	imageURLs, err := ChapterImages(chapterURL)
+	if err != nil {
+		return fmt.Errorf("chromedp navigation failed for %s: %w", chapterURL, err)
+	}
+	if len(imageURLs) == 0 {
+		return fmt.Errorf("chapter %s: %w", chapterNum, debugbundle.NoMatch(chapterURL, "img[data-src]", "images"))
+	}
+
+	// the pages are staged in the chapter's staging dir, kept across runs until the cbz is created
+	stage, err := parser.StageChapter(chapterURL)
+	if err != nil {
+		return fmt.Errorf("failed to stage chapter %s: %w", chapterNum, err)
+	}
 
-		cbzName := fmt.Sprintf("%s.cbz", chapterNum)
-		if err := createCbz(imgPaths, cbzName); err != nil {
-			log.Printf("failed to create cbz for chapter %s: %v", chapterNum, err)
+	fmt.Printf("Starting download of chapter %s images...\n", chapterNum)
+	saved := 0
+	for i, cleanedURL := range imageURLs {
+		filename := parser.PageFilename(i+1, ".jpg")
+		err := stage.Page(filename, cleanedURL, func() error {
+			return downloadPage(cleanedURL, chapterURL, filepath.Join(stage.Dir, filename))
+		})
+		if err != nil {
+			logger.Error("failed to download image", "chapter", chapterNum, "page", i+1, "url", cleanedURL, "error", err)
+			fmt.Printf("Failed to download image %s: %v", cleanedURL, err)
 			continue
 		}
+		saved++
+	}
+
+	if saved == 0 {
+		return fmt.Errorf("no images could be saved for chapter %s at %s", chapterNum, chapterURL)
+	}
 
-		log.Printf("Chapter %s downloaded and saved as %s", chapterNum, cbzName)
-		fmt.Printf("Chapter %s downloaded and saved as %s\n", chapterNum, cbzName)
+	cbzName := chapter.Filename
+	if err := stage.Archive(cbzName, len(imageURLs)); err != nil {
+		return fmt.Errorf("failed to create cbz for chapter %s: %w", chapterNum, err)
 	}
 
+	logger.Info("chapter downloaded", "chapter", chapterNum, "file", cbzName)
+	fmt.Printf("Chapter %s downloaded and saved as %s\n", chapterNum, cbzName)
 	return nil
 }
 
-func createCbz(imgPaths []string, zipName string) error {
-	zipFile, err := os.Create(zipName)
+// downloadPage downloads the image at imageURL shown on chapterURL and saves it as the JPEG file path
+func downloadPage(imageURL, chapterURL, path string) error {
+	resp, err := webClient.GetImage(imageURL, chapterURL)
 	if err != nil {
-		return fmt.Errorf("failed to create cbz file: %w", err)
+		return err
 	}
-	defer zipFile.Close()
-
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
-	for _, img := range imgPaths {
-		err := func() error {
-			f, err := os.Open(img)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-
-			w, err := zipWriter.Create(filepath.Base(img))
-			if err != nil {
-				return err
-			}
-
-			_, err = io.Copy(w, f)
-			return err
-		}()
-		if err != nil {
-			return fmt.Errorf("error adding %s to cbz: %w", img, err)
-		}
+	img, _, err := image.Decode(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
 	}
 
-	return nil
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	if err := jpeg.Encode(out, img, &jpeg.Options{Quality: parser.JPEGQuality()}); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+	return out.Close()
 }
 
 // Get the chatper URLs return string slice
 func ChapterURLs(mangaURL string) ([]string, error) {
 	var chapterURLs []string
 
-	c := colly.NewCollector()
+	c := webClient.NewCollector()
 
 	// Target the <a> tags inside the second-level <ul> within the 'ceo_latest_comics_widget' widget
 	// which is itself inside the div with id 'Chapters_List'
@@ -279,3 +251,14 @@ func ChapterURLs(mangaURL string) ([]string, error) {
 
 	return chapterURLs, nil
 }
+
+// SeriesInfo returns the Infinite Level Up series info, the home page has no info block so it is read from og:title,
+// og:description and og:image
+func SeriesInfo(mangaURL string) (*parser.SeriesInfo, error) {
+	doc, err := webClient.FetchDocument(mangaURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.OpenGraphSeriesInfo(doc, mangaURL), nil
+}
@@ -0,0 +1,30 @@
+package iluim
+
+import (
+	"path/filepath"
+	"scrape/parser"
+	"scrape/replay/replaytest"
+	"slices"
+	"testing"
+)
+
+func TestSeriesChapters(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	chapters, err := SeriesChapters(SeriesURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the link without a chapter number is skipped
+	want := []parser.Chapter{
+		{Number: "3", Filename: "ch003.cbz", URL: SeriesURL + "comic/infinite-level-up-in-murim-chapter-3/"},
+		{Number: "45", Filename: "ch045.cbz", URL: SeriesURL + "comic/infinite-level-up-in-murim-chapter-45/"},
+		{Number: "76.5", Filename: "ch076.5.cbz", URL: SeriesURL + "comic/infinite-level-up-in-murim-chapter-76-5/"},
+	}
+	if !slices.EqualFunc(chapters, want, func(a, b parser.Chapter) bool {
+		return a.Number == b.Number && a.Filename == b.Filename && a.URL == b.URL
+	}) {
+		t.Errorf("SeriesChapters() = %+v, expected %+v", chapters, want)
+	}
+}
@@ -1,38 +1,33 @@
 package kunmanga
 
 import (
-	"archive/zip"
-	"errors"
 	"fmt"
-	"github.com/gocolly/colly"
+	"github.com/PuerkitoBio/goquery"
 	"io"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
+	"scrape/debugbundle"
+	"scrape/logging"
+	"scrape/parser"
+	"scrape/webClient"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// Download image from URL and save to disk
-func downloadKunMangaImage(imgURL, outputPath string, referer string) error {
-	req, err := http.NewRequest("GET", imgURL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Required headers to avoid 403
-	req.Header.Set("User-Agent", "Mozilla/5.0")
-	req.Header.Set("Referer", referer)
+var logger = logging.Site("kunmanga")
 
-	resp, err := http.DefaultClient.Do(req)
+// Download image from URL and save to disk, the image host answers 403 without the chapter page as Referer (see the
+// site's header profile)
+func downloadKunMangaImage(imgURL, outputPath string, chapterURL string) error {
+	resp, err := webClient.GetImage(imgURL, chapterURL)
 	if err != nil {
 		return fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	log.Printf("GET %s => %d\n", imgURL, resp.StatusCode)
+	logger.Debug("image response", "url", imgURL, "status", resp.StatusCode)
 
 	if resp.StatusCode != 200 {
 		return fmt.Errorf("bad status: %s", resp.Status)
@@ -48,171 +43,168 @@ func downloadKunMangaImage(imgURL, outputPath string, referer string) error {
 	return err
 }
 
+// MangaURL builds the series page URL from the manga shortname
+func MangaURL(mangaName string) string {
+	return "https://kunmanga.com/manga/" + mangaName + "/"
+}
+
 // mangaName is the name of the manga from the url eg:
 // From: https://kunmanga.com/manga/ugly-complex/
 // the mangaName will be the string "ugly-complex"
 func KunMangaChapterUrls(mangaName string) []string {
-
-	baseUrl := "https://kunmanga.com/manga"
-	c := colly.NewCollector(
-		colly.AllowedDomains("kunmanga.com"),
-	)
+	chapters, err := chapterList(MangaURL(mangaName))
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	var chapterLinks []string
+	for _, ch := range chapters {
+		chapterLinks = append(chapterLinks, ch.URL)
+	}
 
-	// Select all <a> elements under the chapter list
-	c.OnHTML("ul.main.version-chap li.wp-manga-chapter > a", func(e *colly.HTMLElement) {
-		link := e.Attr("href")
-		chapterLinks = append(chapterLinks, link)
-	})
-
-	c.OnRequest(func(r *colly.Request) {
-		fmt.Println("\nVisiting:", r.URL.String())
-	})
+	return chapterLinks
+}
 
-	// build the url to visit
-	err := c.Visit(baseUrl + "/" + mangaName + "/")
+// SeriesChapters returns the chapters listed on the series page
+func SeriesChapters(mangaURL string) ([]parser.Chapter, error) {
+	chapters, err := chapterList(mangaURL)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	parser.SortChapters(chapters)
+	return chapters, nil
+}
 
-	return chapterLinks
+// ChapterFileName returns the archive name for a chapter number, see parser.ChapterFilename
+func ChapterFileName(chapterNumber int) string {
+	return parser.ChapterFilename(strconv.Itoa(chapterNumber))
 }
 
-// DownloadKunMangaChapters downloads chapter images to temp, zips to CBZ, cleans up.
-// Returns error on failure.
-// DownloadKunMangaChapters downloads chapter images to temp, zips to CBZ, cleans up.
-// Saves CBZ as ch<num>.cbz in current directory. Returns error on failure.
-func DownloadKunMangaChapters(url string, chapterNumber int) error {
-	tempDir := filepath.Join(os.TempDir(), "chapter-dl")
-	chapterSlug := filepath.Base(strings.Trim(url, "/"))
-	chapterTempDir := filepath.Join(tempDir, chapterSlug)
+// scrape the chapter list from the series page
+func chapterList(mangaURL string) ([]parser.Chapter, error) {
+	fmt.Println("\nVisiting:", mangaURL)
+	page, err := webClient.NewFetcher().Fetch(mangaURL)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := page.Document()
+	if err != nil {
+		return nil, err
+	}
 
-	log.Printf("[INFO] Starting download for chapter %s", chapterSlug)
+	var chapters []parser.Chapter
+
+	// Select all <a> elements under the chapter list
+	doc.Find("ul.main.version-chap li.wp-manga-chapter > a").Each(func(_ int, a *goquery.Selection) {
+		link := a.AttrOr("href", "")
+		chNum := ParseChapterNumber(filepath.Base(strings.Trim(link, "/")))
+		chapters = append(chapters, parser.Chapter{
+			Number:   strconv.Itoa(chNum),
+			Title:    parser.CleanText(a.Text()),
+			Filename: ChapterFileName(chNum),
+			URL:      link,
+			Released: parser.MadaraReleaseDate(a.Parent(), time.Now()),
+		})
+	})
+
+	return chapters, nil
+}
 
-	err := os.MkdirAll(chapterTempDir, 0755)
+// ChapterImages scrapes the page image URLs from the reading content of a chapter page
+func ChapterImages(url string) ([]string, error) {
+	logger.Info("visiting", "url", url)
+	page, err := webClient.NewFetcher().Fetch(url)
 	if err != nil {
-		return fmt.Errorf("[ERROR] Failed to create temp directory %s: %w", chapterTempDir, err)
+		return nil, fmt.Errorf("failed to visit page %s: %w", url, err)
+	}
+	doc, err := page.Document()
+	if err != nil {
+		return nil, err
 	}
-
-	c := colly.NewCollector(
-		colly.AllowedDomains("kunmanga.com"),
-	)
-	c.UserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64)"
 
 	var imageURLs []string
-
-	c.OnHTML("div.reading-content img", func(e *colly.HTMLElement) {
-		imgURL := strings.TrimSpace(e.Attr("src"))
+	doc.Find("div.reading-content img").Each(func(_ int, img *goquery.Selection) {
+		imgURL := strings.TrimSpace(img.AttrOr("src", ""))
 		if imgURL != "" {
 			imageURLs = append(imageURLs, imgURL)
 		}
 	})
 
-	c.OnRequest(func(r *colly.Request) {
-		log.Printf("[INFO] Visiting %s", r.URL.String())
-	})
+	return imageURLs, nil
+}
 
-	err = c.Visit(url)
+// DownloadChapter downloads a chapter from the chapter list as ch<num>.cbz
+func DownloadChapter(chapter parser.Chapter) error {
+	chapterNumber, err := strconv.Atoi(chapter.Number)
 	if err != nil {
-		return fmt.Errorf("[ERROR] Failed to visit page %s: %w", url, err)
+		return fmt.Errorf("invalid chapter number %q: %w", chapter.Number, err)
+	}
+	return DownloadKunMangaChapters(chapter.URL, chapterNumber)
+}
+
+// DownloadKunMangaChapters downloads chapter images to the chapter's staging directory, zips them to CBZ and cleans
+// up. Saves CBZ as ch<num>.cbz in current directory. Returns error on failure, the images already staged are kept for
+// the next run.
+func DownloadKunMangaChapters(url string, chapterNumber int) error {
+	logger.Info("starting chapter download", "chapter", chapterNumber, "url", url)
+
+	imageURLs, err := ChapterImages(url)
+	if err != nil {
+		return err
 	}
 
 	if len(imageURLs) == 0 {
-		log.Printf("[WARN] No images found for chapter %s", chapterSlug)
-		return errors.New("no images found on chapter page")
+		logger.Warn("no images found", "chapter", chapterNumber, "url", url)
+		return debugbundle.NoMatch(url, "div.reading-content img", "images")
+	}
+
+	stage, err := parser.StageChapter(url)
+	if err != nil {
+		return err
 	}
 
 	// Download images with retry logic
 	for i, imgURL := range imageURLs {
-		outputPath := filepath.Join(chapterTempDir, fmt.Sprintf("%03d%s", i+1, filepath.Ext(imgURL)))
+		pageName := parser.PageFilename(i+1, filepath.Ext(imgURL))
+		outputPath := filepath.Join(stage.Dir, pageName)
+		if stage.Has(pageName) {
+			logger.Debug("image already staged", "chapter", chapterNumber, "page", i+1, "url", imgURL)
+			continue
+		}
 		var lastErr error
 
 		for attempt := 1; attempt <= 3; attempt++ {
-			log.Printf("[INFO] Downloading image %d/%d: %s (attempt %d)", i+1, len(imageURLs), imgURL, attempt)
+			logger.Info("downloading image", "chapter", chapterNumber, "page", i+1, "pages", len(imageURLs), "url", imgURL, "attempt", attempt)
 			lastErr = downloadKunMangaImage(imgURL, outputPath, url)
 			if lastErr == nil {
-				log.Printf("[INFO] Successfully downloaded %s", imgURL)
+				lastErr = stage.Add(pageName, imgURL)
+			}
+			if lastErr == nil {
+				logger.Debug("downloaded image", "chapter", chapterNumber, "page", i+1, "url", imgURL)
 				break
 			}
-			log.Printf("[WARN] Failed to download %s on attempt %d: %v", imgURL, attempt, lastErr)
+			logger.Warn("failed to download image", "chapter", chapterNumber, "page", i+1, "url", imgURL, "attempt", attempt, "error", lastErr)
 			time.Sleep(time.Duration(attempt*2) * time.Second) // Exponential backoff
 		}
 
 		if lastErr != nil {
-			log.Printf("[ERROR] Giving up on image %s after 3 attempts: %v", imgURL, lastErr)
+			logger.Error("giving up on image after 3 attempts", "chapter", chapterNumber, "page", i+1, "url", imgURL, "error", lastErr)
 			return fmt.Errorf("failed to download image %s: %w", imgURL, lastErr)
 		}
 	}
 
 	// Create CBZ file as ch<num>.cbz in current directory
 	outputDir := "."
-	var cbzName string
-	if chapterNumber < 10 {
-		cbzName = fmt.Sprintf("ch%02d.cbz", chapterNumber)
-	} else {
-		cbzName = fmt.Sprintf("ch%d.cbz", chapterNumber)
-	}
-	cbzPath := filepath.Join(outputDir, cbzName)
+	cbzPath := filepath.Join(outputDir, ChapterFileName(chapterNumber))
 
-	err = createCBZFromDir(cbzPath, chapterTempDir)
+	// the staging directory is removed once the CBZ is written
+	err = stage.Archive(cbzPath, len(imageURLs))
 	if err != nil {
-		return fmt.Errorf("[ERROR] Failed to create CBZ %s: %w", cbzPath, err)
-	}
-	log.Printf("[INFO] Created CBZ archive %s", cbzPath)
-
-	// Cleanup temp files
-	err = os.RemoveAll(chapterTempDir)
-	if err != nil {
-		log.Printf("[WARN] Failed to remove temp directory %s: %v", chapterTempDir, err)
-	} else {
-		log.Printf("[INFO] Removed temp directory %s", chapterTempDir)
-	}
-
-	log.Printf("[INFO] Finished download for chapter %s", chapterSlug)
-	return nil
-}
-
-// createCBZFromDir zips all files from srcDir into a zip file at cbzPath
-func createCBZFromDir(cbzPath, srcDir string) error {
-	cbzFile, err := os.Create(cbzPath)
-	if err != nil {
-		return fmt.Errorf("failed to create CBZ file: %w", err)
-	}
-	defer cbzFile.Close()
-
-	zipWriter := zip.NewWriter(cbzFile)
-	defer zipWriter.Close()
-
-	entries, err := os.ReadDir(srcDir)
-	if err != nil {
-		return fmt.Errorf("failed to read temp dir: %w", err)
-	}
-
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue // skip subdirs if any
-		}
-
-		filePath := filepath.Join(srcDir, entry.Name())
-		file, err := os.Open(filePath)
-		if err != nil {
-			return fmt.Errorf("failed to open file for zipping: %w", err)
-		}
-
-		w, err := zipWriter.Create(entry.Name())
-		if err != nil {
-			file.Close()
-			return fmt.Errorf("failed to create zip entry: %w", err)
-		}
-
-		_, err = io.Copy(w, file)
-		file.Close()
-		if err != nil {
-			return fmt.Errorf("failed to write file to zip: %w", err)
-		}
+		return fmt.Errorf("failed to create CBZ %s: %w", cbzPath, err)
 	}
+	logger.Info("created CBZ", "chapter", chapterNumber, "file", cbzPath)
 
+	logger.Info("finished chapter download", "chapter", chapterNumber)
 	return nil
 }
 
@@ -246,3 +238,13 @@ func ParseChapterNumber(slug string) int {
 	}
 	return n
 }
+
+// SeriesInfo scrapes the series metadata from the Madara summary block of the kunmanga series page
+func SeriesInfo(mangaURL string) (*parser.SeriesInfo, error) {
+	doc, err := webClient.FetchDocument(mangaURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.MadaraSeriesInfo(doc, mangaURL), nil
+}
@@ -0,0 +1,67 @@
+package kunmanga
+
+import (
+	"path/filepath"
+	"scrape/parser"
+	"scrape/replay/replaytest"
+	"slices"
+	"testing"
+)
+
+const seriesURL = "https://kunmanga.com/manga/ugly-complex/"
+
+func TestSeriesChapters(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	chapters, err := SeriesChapters(seriesURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []parser.Chapter{
+		{Number: "1", Title: "Chapter 1", Filename: "ch001.cbz", URL: seriesURL + "chapter-1/"},
+		{Number: "2", Title: "Chapter 2", Filename: "ch002.cbz", URL: seriesURL + "chapter-2/"},
+	}
+	if len(chapters) != len(want) {
+		t.Fatalf("SeriesChapters() returned %d chapters, expected %d: %+v", len(chapters), len(want), chapters)
+	}
+	for i, ch := range chapters {
+		if ch.Number != want[i].Number || ch.Title != want[i].Title || ch.Filename != want[i].Filename || ch.URL != want[i].URL {
+			t.Errorf("chapter %d = %+v, expected %+v", i, ch, want[i])
+		}
+		if ch.Released.IsZero() {
+			t.Errorf("chapter %d has no release date", i)
+		}
+	}
+}
+
+func TestChapterImages(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	images, err := ChapterImages(seriesURL + "chapter-1/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://kunmanga.com/wp-content/uploads/WP-manga/data/ugly-complex/chapter-1/01.jpg",
+		"https://kunmanga.com/wp-content/uploads/WP-manga/data/ugly-complex/chapter-1/02.PNG",
+	}
+	if !slices.Equal(images, want) {
+		t.Errorf("ChapterImages() = %v, expected %v", images, want)
+	}
+}
+
+func TestDownloadChapter(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+	t.Chdir(t.TempDir())
+
+	err := DownloadChapter(parser.Chapter{Number: "1", Filename: "ch001.cbz", URL: seriesURL + "chapter-1/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := replaytest.ArchiveNames(t, "ch001.cbz")
+	if want := []string{"page-001.jpg", "page-002.png"}; !slices.Equal(names, want) {
+		t.Errorf("ch001.cbz holds %v, expected %v", names, want)
+	}
+}
@@ -0,0 +1,153 @@
+package library
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"scrape/parser"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Jump is a break in the chapter numbering, chapters From and To are known but nothing in between is
+type Jump struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// GapReport compares the chapter archives of a series directory with the chapter list of its source
+type GapReport struct {
+	// MissingLocal are remote chapters without a local archive
+	MissingLocal []parser.Chapter `json:"missing_local"`
+	// MissingRemote are local archives whose chapter is no longer listed by the source
+	MissingRemote []parser.Chapter `json:"missing_remote"`
+	// Jumps are holes in the numbering that neither the local copy nor the source has, eg: 41 -> 43
+	Jumps []Jump `json:"jumps"`
+}
+
+// IsEmpty reports whether no gaps were found.
+func (r *GapReport) IsEmpty() bool {
+	return len(r.MissingLocal) == 0 && len(r.MissingRemote) == 0 && len(r.Jumps) == 0
+}
+
+// LocalChapters lists the chapter archives in dir, with the chapter number parsed back out of each filename.
+func LocalChapters(dir string) ([]parser.Chapter, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var chapters []parser.Chapter
+	for _, ent := range entries {
+		if ent.IsDir() || !strings.EqualFold(filepath.Ext(ent.Name()), ".cbz") {
+			continue
+		}
+		chapters = append(chapters, parser.Chapter{
+			Number:   parser.ChapterNumber(ent.Name()),
+			Filename: ent.Name(),
+			Status:   parser.ChapterStatus(dir, ent.Name()),
+		})
+	}
+
+	parser.SortChapters(chapters)
+	return chapters, nil
+}
+
+// Gaps compares the local chapters against the remote chapter list. Chapters are matched by chapter number so
+// archives named under an older scheme (eg: ch05.cbz vs ch005.cbz) still count, chapters without a number are
+// matched by filename. Partial local archives count as missing.
+func Gaps(local, remote []parser.Chapter) *GapReport {
+	report := &GapReport{}
+
+	localKeys := make(map[string]bool, len(local))
+	for _, ch := range local {
+		if ch.Status != parser.StatusPartial {
+			localKeys[ChapterKey(ch)] = true
+		}
+	}
+	remoteKeys := make(map[string]bool, len(remote))
+	for _, ch := range remote {
+		remoteKeys[ChapterKey(ch)] = true
+	}
+
+	for _, ch := range remote {
+		if !localKeys[ChapterKey(ch)] {
+			report.MissingLocal = append(report.MissingLocal, ch)
+		}
+	}
+	for _, ch := range local {
+		if !remoteKeys[ChapterKey(ch)] {
+			report.MissingRemote = append(report.MissingRemote, ch)
+		}
+	}
+	parser.SortChapters(report.MissingLocal)
+	parser.SortChapters(report.MissingRemote)
+
+	report.Jumps = jumps(slices.Concat(local, remote))
+	return report
+}
+
+// MarkDownloaded marks the remote chapters that already have a local archive as downloaded, matching by chapter number
+// so archives from another source or naming scheme are kept instead of downloaded again.
+func MarkDownloaded(remote, local []parser.Chapter) {
+	localKeys := make(map[string]bool, len(local))
+	for _, ch := range local {
+		if ch.Status == parser.StatusDownloaded {
+			localKeys[ChapterKey(ch)] = true
+		}
+	}
+
+	for i := range remote {
+		if localKeys[ChapterKey(remote[i])] {
+			remote[i].Status = parser.StatusDownloaded
+		}
+	}
+}
+
+// ChapterKey identifies a chapter across archive naming schemes: its chapter number without leading zeros or trailing
+// decimal zeros (eg: "05.50" -> "5.5"), or the lower case filename for chapters without a number.
+func ChapterKey(ch parser.Chapter) string {
+	if ch.Number == "" {
+		return "file:" + strings.ToLower(ch.Filename)
+	}
+
+	whole, decimals, _ := strings.Cut(ch.Number, ".")
+	whole = strings.TrimLeft(whole, "0")
+	if whole == "" {
+		whole = "0"
+	}
+	if !strings.Contains(decimals, ".") {
+		decimals = strings.TrimRight(decimals, "0")
+	}
+	if decimals != "" {
+		return whole + "." + decimals
+	}
+	return whole
+}
+
+// jumps finds the whole chapter numbers skipped in the numbering of chapters. Decimal chapters (eg: 41.5) belong to
+// the whole chapter before them.
+func jumps(chapters []parser.Chapter) []Jump {
+	var numbers []int
+	for _, ch := range chapters {
+		if value, ok := parser.ChapterNumberValue(ch.Number); ok {
+			numbers = append(numbers, int(math.Floor(value)))
+		}
+	}
+	slices.Sort(numbers)
+	numbers = slices.Compact(numbers)
+
+	var found []Jump
+	for i := 1; i < len(numbers); i++ {
+		if numbers[i]-numbers[i-1] > 1 {
+			found = append(found, Jump{From: numbers[i-1], To: numbers[i]})
+		}
+	}
+	return found
+}
+
+// String formats the jump as "41 -> 43 (1 missing)"
+func (j Jump) String() string {
+	return strconv.Itoa(j.From) + " -> " + strconv.Itoa(j.To) + " (" + strconv.Itoa(j.To-j.From-1) + " missing)"
+}
@@ -0,0 +1,93 @@
+package library
+
+import (
+	"reflect"
+	"scrape/parser"
+	"testing"
+)
+
+func TestGaps(t *testing.T) {
+	local := []parser.Chapter{
+		{Number: "1", Filename: "ch01.cbz", Status: parser.StatusDownloaded},
+		{Number: "2", Filename: "ch002.cbz", Status: parser.StatusDownloaded},
+		{Number: "3", Filename: "ch003.cbz", Status: parser.StatusPartial},
+		{Number: "41", Filename: "041.cbz", Status: parser.StatusDownloaded},
+		{Number: "41.5", Filename: "ch041.5.cbz", Status: parser.StatusDownloaded},
+	}
+	remote := []parser.Chapter{
+		{Number: "1", Filename: "ch001.cbz"},
+		{Number: "2", Filename: "ch002.cbz"},
+		{Number: "3", Filename: "ch003.cbz"},
+		{Number: "4", Filename: "ch004.cbz"},
+		{Number: "43", Filename: "ch043.cbz"},
+		{Number: "", Filename: "extra.cbz"},
+	}
+
+	report := Gaps(local, remote)
+
+	if got, want := filenames(report.MissingLocal), []string{"ch003.cbz", "ch004.cbz", "ch043.cbz", "extra.cbz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MissingLocal = %v; want %v", got, want)
+	}
+	if got, want := filenames(report.MissingRemote), []string{"041.cbz", "ch041.5.cbz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MissingRemote = %v; want %v", got, want)
+	}
+	if want := []Jump{{From: 4, To: 41}, {From: 41, To: 43}}; !reflect.DeepEqual(report.Jumps, want) {
+		t.Errorf("Jumps = %v; want %v", report.Jumps, want)
+	}
+}
+
+func TestChapterKey(t *testing.T) {
+	tests := map[string]string{
+		"5":      "5",
+		"005":    "5",
+		"5.5":    "5.5",
+		"5.50":   "5.5",
+		"05.500": "5.5",
+		"5.0":    "5",
+		"0":      "0",
+		"12.2.1": "12.2.1",
+	}
+	for number, want := range tests {
+		if got := ChapterKey(parser.Chapter{Number: number}); got != want {
+			t.Errorf("ChapterKey(%q) = %q; want %q", number, got, want)
+		}
+	}
+
+	// the same chapter listed as 5.50 on the site and 5.5 locally is neither missing nor extra
+	report := Gaps([]parser.Chapter{{Number: "5.5", Filename: "ch005.5.cbz", Status: parser.StatusDownloaded}},
+		[]parser.Chapter{{Number: "5.50", Filename: "ch005.50.cbz"}})
+	if len(report.MissingLocal) != 0 || len(report.MissingRemote) != 0 {
+		t.Errorf("5.50 and 5.5 reported as gaps: missing local %v, missing remote %v",
+			filenames(report.MissingLocal), filenames(report.MissingRemote))
+	}
+}
+
+func filenames(chapters []parser.Chapter) []string {
+	var names []string
+	for _, ch := range chapters {
+		names = append(names, ch.Filename)
+	}
+	return names
+}
+
+func TestMarkDownloaded(t *testing.T) {
+	local := []parser.Chapter{
+		{Number: "5", Filename: "ch05.cbz", Status: parser.StatusDownloaded},
+		{Number: "6", Filename: "ch006.cbz", Status: parser.StatusPartial},
+	}
+	remote := []parser.Chapter{
+		{Number: "5", Filename: "ch005.cbz", Status: parser.StatusMissing},
+		{Number: "6", Filename: "ch006.cbz", Status: parser.StatusPartial},
+		{Number: "7", Filename: "ch007.cbz", Status: parser.StatusMissing},
+	}
+
+	MarkDownloaded(remote, local)
+
+	var got []string
+	for _, ch := range remote {
+		got = append(got, ch.Status)
+	}
+	if want := []string{parser.StatusDownloaded, parser.StatusPartial, parser.StatusMissing}; !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,265 @@
+package library
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"scrape/atomicfile"
+	"scrape/parser"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JournalFile is the undo journal of the naming migrations run in a series directory
+const JournalFile = ".scrape-migrate.json"
+
+// pageExtensions are the archive entries treated as pages, anything else (eg: ComicInfo.xml) is left alone
+var pageExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".gif": true, ".avif": true}
+
+// Rename is a single rename of an archive, or of a page inside an archive
+type Rename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// PageRenames are the page renames inside one archive, Archive is the archive name after the archive renames
+type PageRenames struct {
+	Archive string   `json:"archive"`
+	Pages   []Rename `json:"pages"`
+}
+
+// Conflict is a group of files that cannot be renamed because they would end up with the same name
+type Conflict struct {
+	Target string   `json:"target"`
+	Files  []string `json:"files"`
+}
+
+// Migration is the set of renames that bring a series directory to the current naming template
+type Migration struct {
+	Time     time.Time     `json:"time"`
+	Archives []Rename      `json:"archives,omitempty"`
+	Pages    []PageRenames `json:"pages,omitempty"`
+	// Conflicts and Skipped (archives without a chapter number) are only reported, they are never journaled
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+	Skipped   []string   `json:"skipped,omitempty"`
+}
+
+// IsEmpty reports whether the migration renames nothing.
+func (m *Migration) IsEmpty() bool {
+	return len(m.Archives) == 0 && len(m.Pages) == 0
+}
+
+// PlanMigration works out the renames needed to bring the chapter archives in dir to the naming template of
+// parser.TemplateFilename, and the pages inside them to parser.PageFilename when pages is set. Archives that would
+// collide with each other (eg: ch05.cbz and ch005.cbz) are reported as conflicts and left untouched.
+func PlanMigration(dir string, pages bool) (*Migration, error) {
+	local, err := LocalChapters(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migration := &Migration{}
+
+	// group the archives by their new name to find the collisions
+	targets := make(map[string][]string)
+	var order []string
+	for _, ch := range local {
+		target, ok := parser.TemplateFilename(ch.Filename)
+		if !ok {
+			migration.Skipped = append(migration.Skipped, ch.Filename)
+			continue
+		}
+		if _, seen := targets[target]; !seen {
+			order = append(order, target)
+		}
+		targets[target] = append(targets[target], ch.Filename)
+	}
+
+	var archives []string
+	for _, target := range order {
+		files := targets[target]
+		if len(files) > 1 {
+			migration.Conflicts = append(migration.Conflicts, Conflict{Target: target, Files: files})
+			continue
+		}
+		if files[0] != target {
+			migration.Archives = append(migration.Archives, Rename{From: files[0], To: target})
+		}
+		archives = append(archives, target)
+	}
+
+	if !pages {
+		return migration, nil
+	}
+
+	for _, archive := range archives {
+		// the pages are planned against the archive as it is now, under its old name
+		current := archive
+		for _, r := range migration.Archives {
+			if r.To == archive {
+				current = r.From
+			}
+		}
+
+		renames, err := planPageRenames(filepath.Join(dir, current))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", current, err)
+		}
+		if len(renames) > 0 {
+			migration.Pages = append(migration.Pages, PageRenames{Archive: archive, Pages: renames})
+		}
+	}
+
+	return migration, nil
+}
+
+// planPageRenames renames the pages of an archive to page-001.jpg, page-002.jpg... in their current order
+func planPageRenames(path string) ([]Rename, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	var pages []string
+	others := make(map[string]bool)
+	for _, f := range archive.File {
+		if pageExtensions[strings.ToLower(filepath.Ext(f.Name))] {
+			pages = append(pages, f.Name)
+		} else {
+			others[f.Name] = true
+		}
+	}
+	sort.Strings(pages)
+
+	var renames []Rename
+	for i, page := range pages {
+		target := parser.PageFilename(i+1, filepath.Ext(page))
+		if others[target] {
+			return nil, fmt.Errorf("page %s cannot be renamed to %s, the name is taken", page, target)
+		}
+		if page != target {
+			renames = append(renames, Rename{From: page, To: target})
+		}
+	}
+	return renames, nil
+}
+
+// ApplyMigration journals the migration and then performs its renames. The journal is written first so an
+// interrupted migration can still be undone.
+func ApplyMigration(dir string, migration *Migration) error {
+	if migration.IsEmpty() {
+		return nil
+	}
+
+	journal, err := loadJournal(dir)
+	if err != nil {
+		return err
+	}
+	migration.Time = time.Now()
+	journal = append(journal, Migration{Time: migration.Time, Archives: migration.Archives, Pages: migration.Pages})
+	if err := saveJournal(dir, journal); err != nil {
+		return err
+	}
+
+	for _, r := range migration.Archives {
+		if err := os.Rename(filepath.Join(dir, r.From), filepath.Join(dir, r.To)); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range migration.Pages {
+		if err := renamePages(filepath.Join(dir, p.Archive), p.Pages); err != nil {
+			return fmt.Errorf("%s: %w", p.Archive, err)
+		}
+	}
+	return nil
+}
+
+// UndoMigration reverts the last journaled migration in dir and removes it from the journal. Renames that were never
+// performed (an interrupted migration) are skipped. Returns the migration that was undone.
+func UndoMigration(dir string) (*Migration, error) {
+	journal, err := loadJournal(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(journal) == 0 {
+		return nil, errors.New("nothing to undo, the migration journal is empty")
+	}
+	last := journal[len(journal)-1]
+
+	for _, p := range last.Pages {
+		reverted := make([]Rename, len(p.Pages))
+		for i, r := range p.Pages {
+			reverted[i] = Rename{From: r.To, To: r.From}
+		}
+		if err := renamePages(filepath.Join(dir, p.Archive), reverted); err != nil {
+			return nil, fmt.Errorf("%s: %w", p.Archive, err)
+		}
+	}
+
+	for i := len(last.Archives) - 1; i >= 0; i-- {
+		r := last.Archives[i]
+		from, to := filepath.Join(dir, r.To), filepath.Join(dir, r.From)
+
+		if _, err := os.Stat(from); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if _, err := os.Stat(to); err == nil {
+			return nil, fmt.Errorf("cannot restore %s, the file already exists", r.From)
+		}
+		if err := os.Rename(from, to); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := saveJournal(dir, journal[:len(journal)-1]); err != nil {
+		return nil, err
+	}
+	return &last, nil
+}
+
+// renamePages rewrites an archive with its entries renamed, entries missing from renames keep their name
+func renamePages(path string, renames []Rename) error {
+	names := make(map[string]string, len(renames))
+	for _, r := range renames {
+		names[r.From] = r.To
+	}
+
+	return parser.RewriteArchive(path, func(f *zip.File) (string, bool) {
+		if to, ok := names[f.Name]; ok {
+			return to, true
+		}
+		return f.Name, true
+	}, nil)
+}
+
+// loadJournal reads the migration journal of dir, oldest migration first
+func loadJournal(dir string) ([]Migration, error) {
+	data, err := os.ReadFile(filepath.Join(dir, JournalFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var journal []Migration
+	if err := json.Unmarshal(data, &journal); err != nil {
+		return nil, fmt.Errorf("invalid migration journal %s: %w", filepath.Join(dir, JournalFile), err)
+	}
+	return journal, nil
+}
+
+// saveJournal replaces the migration journal of dir
+func saveJournal(dir string, journal []Migration) error {
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(filepath.Join(dir, JournalFile), append(data, '\n'), 0600)
+}
@@ -0,0 +1,124 @@
+package library
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func writeArchive(t *testing.T, path string, entries ...string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(name))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
+
+func archiveEntries(t *testing.T, path string) []string {
+	t.Helper()
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func dirArchives(t *testing.T, dir string) []string {
+	t.Helper()
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.cbz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range names {
+		names[i] = filepath.Base(names[i])
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestMigration(t *testing.T) {
+	dir := t.TempDir()
+	writeArchive(t, filepath.Join(dir, "ch05.cbz"), "img001.jpg", "img002.jpg", "ComicInfo.xml")
+	writeArchive(t, filepath.Join(dir, "vol01ch07.cbz"), "001.jpg")
+	writeArchive(t, filepath.Join(dir, "010.cbz"), "page-001.png")
+	writeArchive(t, filepath.Join(dir, "ch12.cbz"), "page-001.jpg")
+	writeArchive(t, filepath.Join(dir, "ch012.cbz"), "page-001.jpg")
+	// ravenscans named decimal chapters without padding
+	writeArchive(t, filepath.Join(dir, "ch12.5.cbz"), "page-001.jpg")
+	writeArchive(t, filepath.Join(dir, "extra.cbz"), "page-001.jpg")
+	before := dirArchives(t, dir)
+
+	migration, err := PlanMigration(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantArchives := []Rename{
+		{From: "ch05.cbz", To: "ch005.cbz"},
+		{From: "vol01ch07.cbz", To: "ch007.cbz"},
+		{From: "010.cbz", To: "ch010.cbz"},
+		{From: "ch12.5.cbz", To: "ch012.5.cbz"},
+	}
+	if !reflect.DeepEqual(migration.Archives, wantArchives) {
+		t.Errorf("Archives = %v; want %v", migration.Archives, wantArchives)
+	}
+	if want := []Conflict{{Target: "ch012.cbz", Files: []string{"ch012.cbz", "ch12.cbz"}}}; !reflect.DeepEqual(migration.Conflicts, want) {
+		t.Errorf("Conflicts = %v; want %v", migration.Conflicts, want)
+	}
+	if want := []string{"extra.cbz"}; !reflect.DeepEqual(migration.Skipped, want) {
+		t.Errorf("Skipped = %v; want %v", migration.Skipped, want)
+	}
+
+	if err := ApplyMigration(dir, migration); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"ch005.cbz", "ch007.cbz", "ch010.cbz", "ch012.5.cbz", "ch012.cbz", "ch12.cbz", "extra.cbz"}
+	if got := dirArchives(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("after migration = %v; want %v", got, want)
+	}
+	wantPages := []string{"page-001.jpg", "page-002.jpg", "ComicInfo.xml"}
+	if got := archiveEntries(t, filepath.Join(dir, "ch005.cbz")); !reflect.DeepEqual(got, wantPages) {
+		t.Errorf("ch005.cbz pages = %v; want %v", got, wantPages)
+	}
+
+	if _, err := UndoMigration(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := dirArchives(t, dir); !reflect.DeepEqual(got, before) {
+		t.Errorf("after undo = %v; want %v", got, before)
+	}
+	wantPages = []string{"img001.jpg", "img002.jpg", "ComicInfo.xml"}
+	if got := archiveEntries(t, filepath.Join(dir, "ch05.cbz")); !reflect.DeepEqual(got, wantPages) {
+		t.Errorf("ch05.cbz pages after undo = %v; want %v", got, wantPages)
+	}
+
+	if _, err := UndoMigration(dir); err == nil {
+		t.Error("second undo expected an error")
+	}
+}
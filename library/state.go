@@ -0,0 +1,118 @@
+// per-series library state and reports over the chapter archives in a series directory
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"scrape/atomicfile"
+	"slices"
+	"time"
+)
+
+// StateFile is the name of the library state file kept in each series directory
+const StateFile = ".scrape.json"
+
+// ErrNoState is returned by LoadState when a directory has no library state yet
+var ErrNoState = errors.New("no library state")
+
+// State records where a series directory is downloaded from, so later commands (eg: gaps) can run without the
+// series URL being passed again.
+type State struct {
+	Source  string    `json:"source"`
+	URL     string    `json:"url"`
+	Updated time.Time `json:"updated,omitzero"`
+	// Fallbacks are mirrors of the series on other sites, tried in order for chapters the source lacks or fails on
+	Fallbacks []SourceRef `json:"fallbacks,omitempty"`
+	// History lists the sources the series was downloaded from before, oldest first
+	History []PastSource `json:"history,omitempty"`
+}
+
+// SourceRef is a series on a source site
+type SourceRef struct {
+	Source string `json:"source"`
+	URL    string `json:"url"`
+}
+
+// PastSource is a source a series was followed on until it was switched to another
+type PastSource struct {
+	Source string    `json:"source"`
+	URL    string    `json:"url"`
+	Until  time.Time `json:"until"`
+}
+
+// SwitchSource makes source / url the current source of the series, moving the previous one to the history.
+func (s *State) SwitchSource(source, url string, now time.Time) {
+	if s.Source == source && s.URL == url {
+		return
+	}
+	if s.Source != "" {
+		s.History = append(s.History, PastSource{Source: s.Source, URL: s.URL, Until: now})
+	}
+	// a fallback promoted to the source is no longer a fallback
+	s.Fallbacks = slices.DeleteFunc(s.Fallbacks, func(ref SourceRef) bool {
+		return ref.Source == source && ref.URL == url
+	})
+	s.Source, s.URL, s.Updated = source, url, now
+}
+
+// LoadState reads the library state of the series directory dir. Returns ErrNoState if there is none.
+func LoadState(dir string) (*State, error) {
+	data, err := os.ReadFile(filepath.Join(dir, StateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w in %s", ErrNoState, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid library state %s: %w", filepath.Join(dir, StateFile), err)
+	}
+	return &state, nil
+}
+
+// AddFallback appends a fallback mirror, returns false if the series already uses it.
+func (s *State) AddFallback(source, url string) bool {
+	ref := SourceRef{Source: source, URL: url}
+	if (s.Source == source && s.URL == url) || slices.Contains(s.Fallbacks, ref) {
+		return false
+	}
+	s.Fallbacks = append(s.Fallbacks, ref)
+	return true
+}
+
+// RemoveFallback removes the fallback mirror with the given URL, returns false if there is none.
+func (s *State) RemoveFallback(url string) bool {
+	n := len(s.Fallbacks)
+	s.Fallbacks = slices.DeleteFunc(s.Fallbacks, func(ref SourceRef) bool { return ref.URL == url })
+	return len(s.Fallbacks) != n
+}
+
+// RecordSource records source / url as the current source of the series directory dir, see State.SwitchSource.
+func RecordSource(dir, source, url string) error {
+	state, err := LoadState(dir)
+	if errors.Is(err, ErrNoState) {
+		state = &State{}
+	} else if err != nil {
+		return err
+	}
+
+	state.SwitchSource(source, url, time.Now())
+	state.Updated = time.Now()
+	return SaveState(dir, state)
+}
+
+// SaveState writes the library state of the series directory dir. The file is replaced atomically so an interrupted
+// run never leaves a truncated state file behind.
+func SaveState(dir string, state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(filepath.Join(dir, StateFile), append(data, '\n'), 0600)
+}
@@ -0,0 +1,118 @@
+// structured logging for the scrapers, configured with the --log-file, --log-level and --log-format flags. Records
+// carry the fields site, series, chapter, page and url where they apply.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Options configure the default logger
+type Options struct {
+	// File is the log file, "-" logs to stderr
+	File   string
+	Level  slog.Level
+	Format string
+}
+
+// DefaultFile returns the log file in the XDG state directory, eg: ~/.local/state/scrape/scrape.log
+func DefaultFile() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "scrape", "scrape.log")
+}
+
+// ParseLevel parses a log level name: debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// Setup makes the logger described by opts the default slog logger, the standard log package writes to it too. The
+// log file stays open for the rest of the run.
+func Setup(opts Options) error {
+	format := strings.ToLower(opts.Format)
+	if format != "" && format != FormatText && format != FormatJSON {
+		return fmt.Errorf("unknown log format %q, expected %s or %s", opts.Format, FormatText, FormatJSON)
+	}
+
+	var w io.Writer = os.Stderr
+	if opts.File != "" && opts.File != "-" {
+		if err := os.MkdirAll(filepath.Dir(opts.File), 0755); err != nil {
+			return fmt.Errorf("failed to create the log directory: %w", err)
+		}
+		f, err := os.OpenFile(opts.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open the log file: %w", err)
+		}
+		w = f
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	if format == FormatJSON {
+		slog.SetDefault(slog.New(slog.NewJSONHandler(w, handlerOpts)))
+	} else {
+		slog.SetDefault(slog.New(slog.NewTextHandler(w, handlerOpts)))
+	}
+	return nil
+}
+
+// Site returns a logger adding the site field to its records. It logs through the default logger at the time of each
+// record, so it can be created before Setup is called, eg: in a package variable.
+func Site(name string) *slog.Logger {
+	return slog.New(defaultHandler{}).With("site", name)
+}
+
+// defaultHandler hands the records to the handler of the current default logger, with the attributes and groups added
+// to it applied in order
+type defaultHandler struct {
+	with []func(slog.Handler) slog.Handler
+}
+
+func (h defaultHandler) handler() slog.Handler {
+	handler := slog.Default().Handler()
+	for _, with := range h.with {
+		handler = with(handler)
+	}
+	return handler
+}
+
+func (h defaultHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, level)
+}
+
+func (h defaultHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h defaultHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.add(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h defaultHandler) WithGroup(name string) slog.Handler {
+	return h.add(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h defaultHandler) add(with func(slog.Handler) slog.Handler) defaultHandler {
+	return defaultHandler{with: append(h.with[:len(h.with):len(h.with)], with)}
+}
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// the site logger is created before Setup, like the package variables of the site packages
+var testLogger = Site("testsite")
+
+func TestSetupJSON(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	file := filepath.Join(t.TempDir(), "state", "scrape", "scrape.log")
+	if err := Setup(Options{File: file, Level: slog.LevelInfo, Format: "JSON"}); err != nil {
+		t.Fatal(err)
+	}
+
+	testLogger.Debug("not logged", "chapter", "1")
+	testLogger.Info("downloading image", "chapter", "12", "page", 3, "url", "https://example.com/3.jpg")
+	testLogger.With("series", "fake-series").Warn("retrying")
+
+	records := readRecords(t, file)
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2: %v", len(records), records)
+	}
+	want := map[string]any{"level": "INFO", "msg": "downloading image", "site": "testsite", "chapter": "12", "page": float64(3), "url": "https://example.com/3.jpg"}
+	for k, v := range want {
+		if records[0][k] != v {
+			t.Errorf("record 0 %s = %v, want %v", k, records[0][k], v)
+		}
+	}
+	if records[1]["site"] != "testsite" || records[1]["series"] != "fake-series" || records[1]["level"] != "WARN" {
+		t.Errorf("record 1 = %v, want site testsite, series fake-series at WARN", records[1])
+	}
+}
+
+func TestSetupErrors(t *testing.T) {
+	if err := Setup(Options{File: "-", Format: "xml"}); err == nil {
+		t.Error("Setup with format xml succeeded, want an error")
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel(verbose) succeeded, want an error")
+	}
+	if level, err := ParseLevel("warn"); err != nil || level != slog.LevelWarn {
+		t.Errorf("ParseLevel(warn) = %v, %v; want WARN", level, err)
+	}
+}
+
+func TestDefaultFile(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/state")
+	if got, want := DefaultFile(), filepath.Join("/state", "scrape", "scrape.log"); got != want {
+		t.Errorf("DefaultFile() = %q; want %q", got, want)
+	}
+
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("HOME", "/home/reader")
+	if got, want := DefaultFile(), filepath.Join("/home/reader", ".local", "state", "scrape", "scrape.log"); got != want {
+		t.Errorf("DefaultFile() = %q; want %q", got, want)
+	}
+}
+
+func readRecords(t *testing.T, file string) []map[string]any {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
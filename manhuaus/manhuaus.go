@@ -1,17 +1,14 @@
 package manhuaus
 
 import (
-	"archive/zip"
 	"fmt"
 	"image"
 	"image/jpeg"
 	"image/png"
-	"io"
-	"log"
-	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
+	"scrape/logging"
 	"strconv"
 	"strings"
 	"time"
@@ -20,109 +17,97 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly"
 	"golang.org/x/image/webp" // Add support for decoding webp
+	"scrape/debugbundle"
+	"scrape/parser"
 	"scrape/webClient"
 )
 
+var logger = logging.Site("manhuaus")
+
 // ChapterInfo holds chapter URL and number
 type ChapterInfo struct {
 	URL        string
 	ChapterNum int
 }
 
+// DownloadChapter downloads a chapter from the chapter list
+func DownloadChapter(chapter parser.Chapter) error {
+	return DownloadChaper(chapter.URL, chapter.Filename)
+}
+
 // Download chapter images and create cbz file
 // implements Fetch with backup utils code, to rery when hitting dealine exceeded issues
 func DownloadChaper(chapterURL, cbzFileName string) error {
-	tmpDir, err := os.MkdirTemp("", "manga_chapter")
+	chapterValue, imgURLs, err := chapterPage(chapterURL)
 	if err != nil {
 		return err
 	}
-	defer os.RemoveAll(tmpDir)
 
-	// Fetch chapter page HTML with retry/backoff
-	pageHTML, err := webClient.FetchChapterPage(chapterURL)
+	// the images are staged across runs until the CBZ is written, so an interrupted download resumes
+	stage, err := parser.StageChapter(chapterURL)
 	if err != nil {
-		log.Printf("❌ Error fetching chapter page %s: %v", chapterURL, err)
 		return err
 	}
 
-	// Parse HTML using goquery
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
-	if err != nil {
-		return fmt.Errorf("failed to parse chapter HTML: %v", err)
-	}
-
-	var imgURLs []string
-	//var chapterValue string
-
-	chapterValue := strings.TrimSpace(doc.Find("input#wp-manga-current-chap").AttrOr("value", ""))
-	if chapterValue == "" {
-		return fmt.Errorf("chapter value not found")
-	}
-
-	doc.Find("div.reading-content img").Each(func(i int, s *goquery.Selection) {
-		src := strings.TrimSpace(s.AttrOr("data-src", ""))
-		if src != "" {
-			imgURLs = append(imgURLs, src)
-		}
-	})
-
-	if len(imgURLs) == 0 {
-		return fmt.Errorf("no images found")
-	}
-
-	log.Printf("Found %d images in chapter %s", len(imgURLs), chapterValue)
-
 	ticker := time.NewTicker(1500 * time.Millisecond)
 	defer ticker.Stop()
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := webClient.NewHTTPClient()
 
 	for i, url := range imgURLs {
+		fileName := parser.PageFilename(i+1, ".jpg")
+		if stage.Has(fileName) {
+			logger.Debug("image already staged", "chapter", chapterValue, "page", i+1, "file", fileName)
+			continue
+		}
+
 		<-ticker.C
 
-		req, err := http.NewRequest("GET", url, nil)
+		req, err := webClient.NewImageRequest(url, chapterURL)
 		if err != nil {
-			log.Printf("⚠️ Failed to create request for %s: %v", url, err)
+			logger.Warn("failed to create image request", "chapter", chapterValue, "page", i+1, "url", url, "error", err)
 			continue
 		}
 
 		bodyBytes, err := webClient.FetchWithBackoff(client, req)
 		if err != nil {
-			log.Printf("⚠️ Chapter %s: failed to fetch image %s: %v", chapterValue, url, err)
+			logger.Warn("failed to fetch image", "chapter", chapterValue, "page", i+1, "url", url, "error", err)
 			continue
 		}
 
 		img, err := DecodeImage(bodyBytes, url)
 		if err != nil || img == nil {
-			log.Printf("⚠️ Chapter %s: failed to decode image %s: %v", chapterValue, url, err)
+			logger.Warn("failed to decode image", "chapter", chapterValue, "page", i+1, "url", url, "error", err)
 			continue
 		}
 
-		fileName := fmt.Sprintf("page-%03d.jpg", i+1)
-		filePath := filepath.Join(tmpDir, fileName)
+		filePath := filepath.Join(stage.Dir, fileName)
 
 		outFile, err := os.Create(filePath)
 		if err != nil {
-			log.Printf("⚠️ Failed to create file %s: %v", filePath, err)
+			logger.Warn("failed to create image file", "chapter", chapterValue, "page", i+1, "file", filePath, "error", err)
 			continue
 		}
 
-		err = jpeg.Encode(outFile, img, &jpeg.Options{Quality: 90})
+		err = jpeg.Encode(outFile, img, &jpeg.Options{Quality: parser.JPEGQuality()})
 		outFile.Close()
+		if err == nil {
+			err = stage.Add(fileName, url)
+		}
 		if err != nil {
-			log.Printf("⚠️ Failed to save image %s: %v", url, err)
+			logger.Warn("failed to save image", "chapter", chapterValue, "page", i+1, "url", url, "error", err)
 			continue
 		}
 
-		log.Printf("Saved: %s", fileName)
+		logger.Debug("saved image", "chapter", chapterValue, "page", i+1, "file", fileName)
 	}
 
-	log.Printf("Writing CBZ to: %s", cbzFileName)
-	if err := CreateCbzFile(tmpDir, cbzFileName); err != nil {
+	logger.Debug("writing CBZ", "chapter", chapterValue, "file", cbzFileName)
+	if err := stage.Archive(cbzFileName, len(imgURLs)); err != nil {
 		return fmt.Errorf("failed to create CBZ: %v", err)
 	}
 
-	log.Printf("CBZ file created: %s", cbzFileName)
+	logger.Info("created CBZ", "chapter", chapterValue, "file", cbzFileName)
 	return nil
 }
 
@@ -182,57 +167,104 @@ func min(a, b int) int {
 	return b
 }
 
-// CreateCbzFile zips all the files from the tmpDir into cbzFileName.
-func CreateCbzFile(tmpDir, cbzFileName string) error {
-	cbzFile, err := os.Create(cbzFileName)
+// ChapterImages returns the page image URLs of a chapter
+func ChapterImages(chapterURL string) ([]string, error) {
+	_, imgURLs, err := chapterPage(chapterURL)
+	return imgURLs, err
+}
+
+// fetches the chapter page and returns the current chapter value and the lazy loaded image URLs
+func chapterPage(chapterURL string) (string, []string, error) {
+	// Fetch chapter page HTML with retry/backoff
+	pageHTML, err := webClient.FetchChapterPage(chapterURL)
 	if err != nil {
-		return err
+		logger.Error("failed to fetch chapter page", "url", chapterURL, "error", err)
+		return "", nil, err
 	}
-	defer cbzFile.Close()
-
-	zipWriter := zip.NewWriter(cbzFile)
-	defer zipWriter.Close()
 
-	files, err := os.ReadDir(tmpDir)
+	// Parse HTML using goquery
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
 	if err != nil {
-		return err
+		return "", nil, fmt.Errorf("failed to parse chapter HTML: %v", err)
 	}
 
-	for _, file := range files {
-		filePath := filepath.Join(tmpDir, file.Name())
-		fileToZip, err := os.Open(filePath)
-		if err != nil {
-			return err
-		}
+	var imgURLs []string
 
-		wr, err := zipWriter.Create(file.Name())
-		if err != nil {
-			fileToZip.Close()
-			return err
-		}
+	chapterValue := strings.TrimSpace(doc.Find("input#wp-manga-current-chap").AttrOr("value", ""))
+	if chapterValue == "" {
+		return "", nil, fmt.Errorf("chapter value not found")
+	}
 
-		_, err = io.Copy(wr, fileToZip)
-		fileToZip.Close()
-		if err != nil {
-			return err
+	doc.Find("div.reading-content img").Each(func(i int, s *goquery.Selection) {
+		src := strings.TrimSpace(s.AttrOr("data-src", ""))
+		if src != "" {
+			imgURLs = append(imgURLs, src)
 		}
+	})
+
+	if len(imgURLs) == 0 {
+		return "", nil, debugbundle.NoMatch(chapterURL, "div.reading-content img[data-src]", "images")
 	}
 
-	return nil
+	logger.Info("images found", "chapter", chapterValue, "url", chapterURL, "pages", len(imgURLs))
+	return chapterValue, imgURLs, nil
 }
 
 // Retrieves list of chapter URLs
 func ChapterURLs(mangaURL string) ([]string, error) {
+	chapters, err := chapterList(mangaURL)
+	if err != nil {
+		return nil, err
+	}
+
 	var chapterURLs []string
+	for _, ch := range chapters {
+		chapterURLs = append(chapterURLs, ch.URL)
+	}
 
-	c := colly.NewCollector()
+	return chapterURLs, nil
+}
+
+// SeriesChapters returns the chapters listed on the series page, chapters without a number in the URL are skipped
+func SeriesChapters(mangaURL string) ([]parser.Chapter, error) {
+	chapters, err := chapterList(mangaURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var numbered []parser.Chapter
+	for _, ch := range chapters {
+		if ch.Filename != "" {
+			numbered = append(numbered, ch)
+		}
+	}
+	parser.SortChapters(numbered)
+
+	return numbered, nil
+}
+
+// scrapes the chapter list from the series page
+func chapterList(mangaURL string) ([]parser.Chapter, error) {
+	var chapters []parser.Chapter
+
+	c := webClient.NewCollector()
 
 	// The chapter links are inside: <li class="wp-manga-chapter"><a href="...">...</a></li>
 	c.OnHTML("li.wp-manga-chapter a", func(e *colly.HTMLElement) {
 		url := e.Attr("href")
-		if url != "" {
-			chapterURLs = append(chapterURLs, url)
+		if url == "" {
+			return
 		}
+
+		// the filename is left empty when the url does not contain a chapter number
+		filename, _ := ExtractChapterNumber(url)
+		chapters = append(chapters, parser.Chapter{
+			Number:   parser.ChapterNumber(filename),
+			Title:    parser.CleanText(e.Text),
+			Filename: filename,
+			URL:      url,
+			Released: parser.MadaraReleaseDate(e.DOM.Closest("li.wp-manga-chapter"), time.Now()),
+		})
 	})
 
 	err := c.Visit(mangaURL)
@@ -240,11 +272,11 @@ func ChapterURLs(mangaURL string) ([]string, error) {
 		return nil, err
 	}
 
-	if len(chapterURLs) == 0 {
+	if len(chapters) == 0 {
 		return nil, fmt.Errorf("no chapter URLs found at %s", mangaURL)
 	}
 
-	return chapterURLs, nil
+	return chapters, nil
 }
 
 // ExtractChapterNumber extracts and formats the chapter number from the URL.
@@ -272,3 +304,13 @@ func ExtractChapterNumber(url string) (string, error) {
 
 	return fmt.Sprintf("ch%03d.cbz", num), nil
 }
+
+// SeriesInfo scrapes the series metadata from the Madara summary block of the manhuaus series page
+func SeriesInfo(mangaURL string) (*parser.SeriesInfo, error) {
+	doc, err := webClient.FetchDocument(mangaURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.MadaraSeriesInfo(doc, mangaURL), nil
+}
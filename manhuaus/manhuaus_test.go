@@ -0,0 +1,85 @@
+package manhuaus
+
+import (
+	"path/filepath"
+	"scrape/parser"
+	"scrape/replay/replaytest"
+	"slices"
+	"testing"
+)
+
+const seriesURL = "https://manhuaus.com/manga/martial-peak/"
+
+func TestSeriesChapters(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	chapters, err := SeriesChapters(seriesURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the chapter without a number in its URL is skipped
+	want := []parser.Chapter{
+		{Number: "1", Title: "Chapter 1", Filename: "ch001.cbz", URL: seriesURL + "chapter-1/"},
+		{Number: "2.5", Title: "Chapter 2.5", Filename: "ch002.5.cbz", URL: seriesURL + "chapter-2.5/"},
+	}
+	if len(chapters) != len(want) {
+		t.Fatalf("SeriesChapters() returned %d chapters, expected %d: %+v", len(chapters), len(want), chapters)
+	}
+	for i, ch := range chapters {
+		if ch.Number != want[i].Number || ch.Title != want[i].Title || ch.Filename != want[i].Filename || ch.URL != want[i].URL {
+			t.Errorf("chapter %d = %+v, expected %+v", i, ch, want[i])
+		}
+		if ch.Released.IsZero() {
+			t.Errorf("chapter %d has no release date", i)
+		}
+	}
+}
+
+func TestExtractChapterNumber(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{seriesURL + "chapter-1/", "ch001.cbz"},
+		{seriesURL + "chapter-2.5/", "ch002.5.cbz"},
+		{seriesURL + "chapter-1024/", "ch1024.cbz"},
+		{seriesURL + "notice/", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := ExtractChapterNumber(tt.url); got != tt.want {
+			t.Errorf("ExtractChapterNumber(%q) = %q, expected %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestChapterImages(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	images, err := ChapterImages(seriesURL + "chapter-1/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://img.manhuaus.com/martial-peak/chapter-1/1.jpg",
+		"https://img.manhuaus.com/martial-peak/chapter-1/2.png",
+	}
+	if !slices.Equal(images, want) {
+		t.Errorf("ChapterImages() = %v, expected %v", images, want)
+	}
+}
+
+func TestDownloadChapter(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+	t.Chdir(t.TempDir())
+
+	err := DownloadChapter(parser.Chapter{Number: "1", Filename: "ch001.cbz", URL: seriesURL + "chapter-1/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := replaytest.ArchiveNames(t, "ch001.cbz")
+	if want := []string{"page-001.jpg", "page-002.jpg"}; !slices.Equal(names, want) {
+		t.Errorf("ch001.cbz holds %v, expected %v", names, want)
+	}
+}
@@ -2,143 +2,116 @@ package mgeko
 
 import (
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
 	"regexp"
+	"scrape/debugbundle"
+	"scrape/logging"
 	"scrape/parser"
+	"scrape/webClient"
 	"strings"
 
-	"github.com/gocolly/colly"
+	"github.com/PuerkitoBio/goquery"
 )
 
-func DownloadChapters(url string) {
-	// Step 1: Get all chapter URLs
-	chapterUrls, err := chapterUrls(url)
-	if err != nil {
-		log.Fatalf("Failed to fetch chapter URLs: %v", err)
-	}
+var logger = logging.Site("mgeko")
 
-	// Step 2: Build chapter map (key = "chXXX.cbz", value = URL)
-	chapterMap := chapterMap(chapterUrls)
+// DownloadChapter downloads the chapter images, converts them to JPG and creates the chapter cbz file
+func DownloadChapter(chapter parser.Chapter) error {
+	cbzName := chapter.Filename
+	chapterURL := chapter.URL
+	fmt.Printf("Downloading chapter %s -> %s\n", cbzName, chapterURL)
 
-	// Step 3: Get list of files in current dir
-	currentFiles, err := parser.FileList(".")
+	// scrape image URLs inside #chapter-reader
+	imgURLs, err := ChapterImages(chapterURL)
 	if err != nil {
-		log.Fatalf("Failed to list files: %v", err)
+		return fmt.Errorf("[%s] Failed to visit %s: %w", cbzName, chapterURL, err)
 	}
 
-	// Step 4: Filter all non-CBZ files
-	filteredCbzFiles := parser.FilterCBZFiles(currentFiles)
-
-	// Step 5: Remove already-downloaded chapters
-	for _, chapter := range filteredCbzFiles {
-		delete(chapterMap, chapter)
+	if len(imgURLs) == 0 {
+		return fmt.Errorf("[%s] %w", cbzName, debugbundle.NoMatch(chapterURL, "#chapter-reader img", "images"))
 	}
 
-	// Step 6: Sort chapter keys
-	sortedChapters, sortError := parser.SortKeys(chapterMap)
-	if sortError != nil {
-		log.Fatalf("Failed to sort chapter map keys: %v", sortError)
+	// Stage the chapter, its staging directory keeps the downloaded images across runs until the CBZ is written
+	stage, err := parser.StageChapter(chapterURL)
+	if err != nil {
+		return fmt.Errorf("[%s] Failed to create the staging directory: %w", cbzName, err)
 	}
 
-	// Step 7: Iterate over sorted chapter keys
-	for _, cbzName := range sortedChapters {
-		chapterURL := chapterMap[cbzName]
-		fmt.Printf("Downloading chapter %s -> %s\n", cbzName, chapterURL)
-
-		// Colly to scrape image URLs inside #chapter-reader
-		var imgURLs []string
-		c := colly.NewCollector(
-			colly.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36"),
-		)
-		c.OnHTML("#chapter-reader img", func(e *colly.HTMLElement) {
-			src := e.Attr("src")
-			if src != "" {
-				imgURLs = append(imgURLs, src)
-				log.Printf("[%s] Found image URL: %s", cbzName, src)
-			}
+	// Download and convert each image using DownloadAndConvertToJPG, skipping the ones already staged
+	for idx, imgURL := range imgURLs {
+		logger.Info("downloading image", "file", cbzName, "page", idx+1, "pages", len(imgURLs), "url", imgURL)
+		err := stage.Page(parser.JPGName(imgURL), imgURL, func() error {
+			return parser.DownloadAndConvertToJPG(imgURL, chapterURL, stage.Dir)
 		})
-		c.OnError(func(_ *colly.Response, err error) {
-			log.Printf("[%s] Failed to fetch chapter page %s: %v", cbzName, chapterURL, err)
-		})
-
-		err := c.Visit(chapterURL)
 		if err != nil {
-			log.Printf("[%s] Failed to visit %s: %v", cbzName, chapterURL, err)
-			continue
+			logger.Error("failed to download image", "file", cbzName, "page", idx+1, "url", imgURL, "error", err)
+		} else {
+			logger.Debug("downloaded image", "file", cbzName, "page", idx+1, "url", imgURL)
 		}
+	}
 
-		if len(imgURLs) == 0 {
-			log.Printf("[%s] No images found for chapter", cbzName)
-			continue
-		}
+	// Create CBZ from the JPGs
+	err = stage.Archive(cbzName, len(imgURLs))
+	if err != nil {
+		return fmt.Errorf("[%s] Failed to create CBZ %s: %w", cbzName, cbzName, err)
+	}
+	fmt.Printf("Created CBZ: %s\n", cbzName)
 
-		// Create temp directory for chapter
-		chapterDir := filepath.Join("/tmp", strings.TrimSuffix(cbzName, ".cbz"))
-		err = os.MkdirAll(chapterDir, 0755)
-		if err != nil {
-			log.Printf("[%s] Failed to create temporary directory %s: %v", cbzName, chapterDir, err)
-			continue
-		}
+	return nil
+}
 
-		// Download and convert each image using DownloadAndConvertToJPG
-		for idx, imgURL := range imgURLs {
-			log.Printf("[%s] Downloading image %d/%d: %s", cbzName, idx+1, len(imgURLs), imgURL)
-			err := parser.DownloadAndConvertToJPG(imgURL, chapterDir)
-			if err != nil {
-				log.Printf("[%s] Failed to download/convert image %s: %v", cbzName, imgURL, err)
-			} else {
-				log.Printf("[%s] Successfully downloaded and converted image: %s", cbzName, imgURL)
-			}
-		}
+// SeriesChapters returns the chapters listed on the series page, built from the same chapter map used for downloads
+func SeriesChapters(url string) ([]parser.Chapter, error) {
+	chapterMap, err := seriesChapterMap(url)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ChaptersFromMap(chapterMap), nil
+}
 
-		// Create CBZ from the JPGs
-		err = parser.CreateCbzFromDir(chapterDir, cbzName)
-		if err != nil {
-			log.Printf("[%s] Failed to create CBZ %s: %v", cbzName, cbzName, err)
-		} else {
-			fmt.Printf("Created CBZ: %s\n", cbzName)
-		}
+// ChapterImages scrapes the page image URLs inside #chapter-reader of a chapter page
+func ChapterImages(chapterURL string) ([]string, error) {
+	doc, err := webClient.FetchDocument(chapterURL)
+	if err != nil {
+		return nil, err
+	}
 
-		// Remove temp directory
-		err = os.RemoveAll(chapterDir)
-		if err != nil {
-			log.Printf("[%s] Failed to remove temp directory %s: %v", cbzName, chapterDir, err)
+	var imgURLs []string
+	doc.Find("#chapter-reader img").Each(func(_ int, img *goquery.Selection) {
+		src := img.AttrOr("src", "")
+		if src != "" {
+			imgURLs = append(imgURLs, src)
+			logger.Debug("found image", "url", chapterURL, "image", src)
 		}
+	})
+
+	return imgURLs, nil
+}
+
+// retrieve the mgeko chapter list and build the chapter map (key = "chXXX.cbz", value = URL)
+func seriesChapterMap(url string) (map[string]string, error) {
+	chapterUrls, err := chapterUrls(url)
+	if err != nil {
+		return nil, err
 	}
+	return chapterMap(chapterUrls), nil
 }
 
 // retrieve mgeko chapter list
 func chapterUrls(url string) ([]string, error) {
-	var chapters []string
-
-	c := colly.NewCollector(
-		colly.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36"),
-	)
+	doc, err := webClient.FetchDocument(url)
+	if err != nil {
+		return nil, err
+	}
 
-	c.OnHTML("ul.chapter-list li a", func(e *colly.HTMLElement) {
-		href := e.Attr("href")
+	var chapters []string
+	doc.Find("ul.chapter-list li a").Each(func(_ int, a *goquery.Selection) {
+		href := a.AttrOr("href", "")
 		if href != "" {
 			fullURL := "https://www.mgeko.cc" + href
 			chapters = append(chapters, fullURL)
 		}
 	})
 
-	var scrapeErr error
-	c.OnError(func(_ *colly.Response, err error) {
-		scrapeErr = err
-	})
-
-	err := c.Visit(url)
-	if err != nil {
-		return nil, err
-	}
-
-	if scrapeErr != nil {
-		return nil, scrapeErr
-	}
-
 	return chapters, nil
 }
 
@@ -176,7 +149,7 @@ func chapterMap(urls []string) map[string]string {
 		}
 	}
 
-	log.Printf("found chapters: %v", chapterMap)
+	logger.Debug("found chapters", "chapters", len(chapterMap))
 
 	return chapterMap
 }
@@ -193,3 +166,45 @@ func extractChapterImageUrls(html string) []string {
 	}
 	return urls
 }
+
+// SeriesInfo scrapes the series metadata from the mgeko manga page
+func SeriesInfo(url string) (*parser.SeriesInfo, error) {
+	doc, err := webClient.FetchDocument(url)
+	if err != nil {
+		return nil, err
+	}
+
+	info := parser.OpenGraphSeriesInfo(doc, url)
+
+	novel := doc.Find("div.novel-info")
+	if title := parser.CleanText(novel.Find("h1.novel-title").First().Text()); title != "" {
+		info.Title = title
+	}
+	if alt := parser.CleanText(novel.Find("h2.alternative-title").First().Text()); alt != "" {
+		info.AltTitles = parser.SplitList(alt)
+	}
+	novel.Find(`div.author [itemprop="author"]`).Each(func(_ int, s *goquery.Selection) {
+		info.Authors = append(info.Authors, parser.SplitList(s.Text())...)
+	})
+	doc.Find("div.categories ul li a").Each(func(_ int, s *goquery.Selection) {
+		if genre := parser.CleanText(s.Text()); genre != "" {
+			info.Genres = append(info.Genres, genre)
+		}
+	})
+
+	// header stats are <span><strong>Ongoing</strong><small>Status</small></span>
+	doc.Find("div.header-stats span").Each(func(_ int, s *goquery.Selection) {
+		if strings.EqualFold(parser.CleanText(s.Find("small").Text()), "status") {
+			info.Status = parser.CleanText(s.Find("strong").Text())
+		}
+	})
+
+	if synopsis := parser.CleanText(doc.Find("p.description").First().Text()); synopsis != "" {
+		info.Synopsis = synopsis
+	}
+	if cover := parser.ImageSource(doc.Find("figure.cover img").First()); cover != "" {
+		info.CoverURL = parser.ResolveURL(url, cover)
+	}
+
+	return info, nil
+}
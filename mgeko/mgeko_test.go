@@ -0,0 +1,73 @@
+package mgeko
+
+import (
+	"path/filepath"
+	"scrape/parser"
+	"scrape/replay/replaytest"
+	"slices"
+	"testing"
+)
+
+const seriesURL = "https://www.mgeko.cc/manga/monster-eater/all-chapters/"
+
+func TestSeriesChapters(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	chapters, err := SeriesChapters(seriesURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"ch001.cbz":   "https://www.mgeko.cc/reader/en/monster-eater-chapter-1-eng-li/",
+		"ch002.cbz":   "https://www.mgeko.cc/reader/en/monster-eater-chapter-2-eng-li/",
+		"ch010.5.cbz": "https://www.mgeko.cc/reader/en/monster-eater-chapter-10-5-eng-li/",
+	}
+	if got := parser.ChapterMap(chapters); len(got) != len(want) {
+		t.Fatalf("SeriesChapters() = %v, expected %v", got, want)
+	} else {
+		for filename, url := range want {
+			if got[filename] != url {
+				t.Errorf("chapter %s = %q, expected %q", filename, got[filename], url)
+			}
+		}
+	}
+	if chapters[0].Filename != "ch001.cbz" || chapters[2].Filename != "ch010.5.cbz" {
+		t.Errorf("chapters not sorted by number: %+v", chapters)
+	}
+}
+
+func TestChapterImages(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	images, err := ChapterImages("https://www.mgeko.cc/reader/en/monster-eater-chapter-1-eng-li/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://imgsrv4.com/mg1/fastcdn/monster-eater/chapter-1/1.jpg",
+		"https://imgsrv4.com/mg1/fastcdn/monster-eater/chapter-1/2.png",
+	}
+	if !slices.Equal(images, want) {
+		t.Errorf("ChapterImages() = %v, expected %v", images, want)
+	}
+}
+
+func TestDownloadChapter(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+	t.Chdir(t.TempDir())
+
+	err := DownloadChapter(parser.Chapter{
+		Number:   "1",
+		Filename: "ch001.cbz",
+		URL:      "https://www.mgeko.cc/reader/en/monster-eater-chapter-1-eng-li/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := replaytest.ArchiveNames(t, "ch001.cbz")
+	if want := []string{"001.jpg", "002.jpg"}; !slices.Equal(names, want) {
+		t.Errorf("ch001.cbz holds %v, expected %v", names, want)
+	}
+}
@@ -1,38 +1,57 @@
 package orv
 
 import (
-	"context"
 	"fmt"
 	"github.com/gocolly/colly"
-	"log"
-	"os"
+	"scrape/browser"
+	"scrape/debugbundle"
+	"scrape/logging"
 	"scrape/parser"
+	"scrape/webClient"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/chromedp"
 )
 
+var logger = logging.Site("orv")
+
+// SeriesURL is the Omniscient Reader's Viewpoint home page, the chapter list is its scroll-sm list
+const SeriesURL = "https://manhwa.omniscientsreadersmanga.com/"
+
+// SeriesChapters returns the ORV chapters sorted by number, from the chapter map of the home page
+func SeriesChapters(mangaUrl string) ([]parser.Chapter, error) {
+	chapterMap, err := chapterURLs(mangaUrl)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ChaptersFromMap(chapterMap), nil
+}
+
+// ChapterImages returns all the image URLs for the chapter
+func ChapterImages(chapterUrl string) ([]string, error) {
+	return chapterImageUrls(chapterUrl)
+}
+
 // Get the chatper URLs return string slice
-func chapterURLs() (map[string]string, error) {
+func chapterURLs(mangaUrl string) (map[string]string, error) {
 	// resulting chapter map
 	var chapterMap = make(map[string]string)
 	var chName = ""
 
-	mangaUrl := "https://manhwa.omniscientsreadersmanga.com/"
-
-	c := colly.NewCollector()
+	c := webClient.NewCollector()
 
 	// Debug hooks
 	c.OnRequest(func(r *colly.Request) {
-		log.Printf("[DEBUG] Visiting %s", r.URL.String())
+		logger.Debug("visiting", "url", r.URL.String())
 	})
 	c.OnResponse(func(r *colly.Response) {
-		log.Printf("[DEBUG] Response from %s - Status %d", r.Request.URL, r.StatusCode)
+		logger.Debug("response", "url", r.Request.URL, "status", r.StatusCode)
 	})
 	c.OnError(func(r *colly.Response, err error) {
-		log.Printf("[ERROR] Request to %s failed: %v (Status: %d)", r.Request.URL, err, r.StatusCode)
+		logger.Error("request failed", "url", r.Request.URL, "status", r.StatusCode, "error", err)
 	})
 
 	// go to teh list-body div, the scroll-sm unordered list and then to the list item
@@ -47,14 +66,13 @@ func chapterURLs() (map[string]string, error) {
 		// safely type cast the chapter string to an integer:
 		num, err := strconv.Atoi(chapterNum)
 		if err != nil {
-			log.Printf("orv.ChapterURLs() - error converting chapter string to integer ")
+			logger.Warn("chapter number is not a number", "series", mangaUrl, "chapter", chapterNum, "error", err)
 			return
 		}
 
-		//  if the chapter number exists then pad it to 3 digits and create the file name
+		//  if the chapter number exists then create the file name
 		if chapterNum != "" {
-			padded := fmt.Sprintf("%03d", num)
-			chName = padded + "." + "cbz"
+			chName = parser.ChapterFilename(strconv.Itoa(num))
 		}
 
 		// append the chapter number and URL assuming they are not null
@@ -78,99 +96,117 @@ func chapterURLs() (map[string]string, error) {
 
 // return all the image URLs for the chapter
 func chapterImageUrls(chapterUrl string) ([]string, error) {
-	// Create context with cancel
-	ctx, cancel := chromedp.NewContext(context.Background())
+	urls, _, err := chapterImages(chapterUrl, false)
+	return urls, err
+}
+
+// return all the image URLs for the chapter and, when capture is set, the image bytes the browser loaded by URL
+func chapterImages(chapterUrl string, capture bool) ([]string, map[string][]byte, error) {
+	// Open a tab in the shared browser
+	ctx, cancel, err := browser.NewTab(2 * time.Minute) // allow time to scroll through long chapters
+	if err != nil {
+		return nil, nil, err
+	}
 	defer cancel()
 
-	// Enable network domain to listen to requests/responses (optional)
-	if err := chromedp.Run(ctx, network.Enable()); err != nil {
-		return nil, err
+	// Enable network domain to listen to requests/responses, recording the image responses when capturing
+	var images *browser.ImageCapture
+	if capture {
+		if images, err = browser.CaptureImages(ctx, nil); err != nil {
+			return nil, nil, err
+		}
+	} else if err := chromedp.Run(ctx, network.Enable()); err != nil {
+		return nil, nil, err
 	}
 
 	// Setup listeners for network requests & responses to log them (optional)
 	chromedp.ListenTarget(ctx, func(ev interface{}) {
 		switch ev := ev.(type) {
 		case *network.EventRequestWillBeSent:
-			log.Printf("REQUEST: %s %s\n", ev.Request.Method, ev.Request.URL)
+			logger.Debug("request", "method", ev.Request.Method, "url", ev.Request.URL)
 		case *network.EventResponseReceived:
-			log.Printf("RESPONSE: %d %s\n", ev.Response.Status, ev.Response.URL)
+			logger.Debug("response", "status", ev.Response.Status, "url", ev.Response.URL)
 		}
 	})
 
 	var imageURLs []string
 
 	// Run chromedp tasks to navigate and extract image URLs
-	err := chromedp.Run(ctx,
+	err = chromedp.Run(ctx,
 		chromedp.Navigate(chapterUrl),
 		chromedp.WaitReady("img", chromedp.ByQueryAll),
+		browser.ScrollFor("orv", "img"),
 		chromedp.Evaluate(`Array.from(document.querySelectorAll('img')).map(img => img.src)`, &imageURLs),
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return imageURLs, nil
-}
-
-// main func to downlownd the orv manga chapters
-func DownloadMangaChapters() {
-
-	// get a list of the chapter in the current directory
-	downloadedChapters, err := parser.FileList(".")
-	if err != nil {
-		log.Fatalf("error getting file list: %v", err)
+	if images == nil {
+		return imageURLs, nil, nil
 	}
+	return imageURLs, images.Images(ctx), nil
+}
 
-	// get a list of all the chapters from the website
-	chapterMap, err := chapterURLs()
+// DownloadChapter downloads the chapter images and creates the chapter cbz file
+func DownloadChapter(chapter parser.Chapter) error {
+	chapterImageUrls, captured, err := chapterImages(chapter.URL, browser.CaptureEnabled())
 	if err != nil {
-		log.Fatalf("Get Chapter URls failed: %v", err)
+		return err
 	}
 
-	// remove all chapters that have already been downloaded from the chapter map
-	for _, chName := range downloadedChapters {
-		// remove chapter number from the chapter map if it is already downloaded
-		if _, ok := chapterMap[chName]; ok {
-			delete(chapterMap, chName)
-			log.Printf("%s already downloaded, removed from chapterMap", chName)
-		}
+	// create chapter number to present to user
+	chapterNum := strings.SplitN(chapter.Filename, ".", 2)
+	fmt.Println("Starting download for chapter: ", chapterNum[0])
+	if len(chapterImageUrls) == 0 {
+		return fmt.Errorf("chapter %s: %w", chapterNum[0], debugbundle.NoMatch(chapter.URL, "img", "images"))
 	}
 
-	// after removing any chapters that have already been downloaded
-	// sort chapter keys to parse oldest to newest
-	chapterNames, err := parser.SortKeys(chapterMap)
+	// stage the chapter, the images staged by an earlier run are kept
+	stage, err := parser.StageChapter(chapter.URL)
 	if err != nil {
-		log.Fatal("error when sorting chapter name slice")
+		return fmt.Errorf("failed to stage %s: %w", chapterNum[0], err)
 	}
 
-	// download any new chapters
-	for _, chapter := range chapterNames {
-		chapterImageUrls, _ := chapterImageUrls(chapterMap[chapter])
-
-		// create chapter number to present to user
-		chapterNum := strings.SplitN(chapter, ".", 2)
-		fmt.Println("Starting download for chapter: ", chapterNum[0])
-
-		// create temp dir for image file download
-		tmpDir, err := os.MkdirTemp("", "chapter-"+chapterNum[0])
-		if err != nil {
-			log.Printf("Failed to create temp dir for %s: %v", chapterNum, err)
-			fmt.Printf("Failed to create temp dir for %s: %v", chapterNum, err)
+	// this part is teh image download so only download teh images here
+	for _, url := range chapterImageUrls {
+
+		// use the image the browser already loaded, only download the ones it did not
+		if data, ok := captured[url]; ok {
+			err := stage.Page(parser.JPGName(url), url, func() error {
+				return parser.SaveImageToJPG(data, url, stage.Dir)
+			})
+			if err != nil {
+				logger.Error("failed to save captured image", "chapter", chapter.Number, "url", url, "error", err)
+			}
 			continue
 		}
-		defer os.RemoveAll(tmpDir)
-
-		// this part is teh image download so only download teh images here
-		for _, url := range chapterImageUrls {
 
-			// download all the chapter images to temp dir
-			parser.DownloadAndConvertToJPG(url, tmpDir)
+		// download all the chapter images to the staging dir
+		err := stage.Page(parser.JPGName(url), url, func() error {
+			return parser.DownloadAndConvertToJPG(url, chapter.URL, stage.Dir)
+		})
+		if err != nil {
+			logger.Error("failed to download image", "chapter", chapter.Number, "url", url, "error", err)
 		}
-		// after all the images in the chapter are downloaded
-		// create cbz and move to the target dir (the current dir)
-		targetFile := "./" + chapter
-		parser.CreateCbzFromDir(tmpDir, targetFile)
+	}
+	// after all the images in the chapter are downloaded
+	// create cbz and move to the target dir (the current dir)
+	targetFile := "./" + chapter.Filename
+	if err := stage.Archive(targetFile, len(chapterImageUrls)); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s chapter file created...\n", targetFile)
+	return nil
+}
 
-		fmt.Printf("%s chapter file created...\n", targetFile)
+// SeriesInfo returns the ORV series info, taken from the OpenGraph tags of the home page
+func SeriesInfo(seriesURL string) (*parser.SeriesInfo, error) {
+	doc, err := webClient.FetchDocument(seriesURL)
+	if err != nil {
+		return nil, err
 	}
+
+	return parser.OpenGraphSeriesInfo(doc, seriesURL), nil
 }
@@ -0,0 +1,29 @@
+package orv
+
+import (
+	"path/filepath"
+	"scrape/parser"
+	"scrape/replay/replaytest"
+	"slices"
+	"testing"
+)
+
+func TestSeriesChapters(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	chapters, err := SeriesChapters(SeriesURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the list item without a chapter number is skipped
+	want := []parser.Chapter{
+		{Number: "9", Filename: "ch009.cbz", URL: SeriesURL + "comic/omniscient-readers-viewpoint-chapter-9/"},
+		{Number: "201", Filename: "ch201.cbz", URL: SeriesURL + "comic/omniscient-readers-viewpoint-chapter-201/"},
+	}
+	if !slices.EqualFunc(chapters, want, func(a, b parser.Chapter) bool {
+		return a.Number == b.Number && a.Filename == b.Filename && a.URL == b.URL
+	}) {
+		t.Errorf("SeriesChapters() = %+v, expected %+v", chapters, want)
+	}
+}
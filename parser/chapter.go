@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// local status of a chapter archive
+const (
+	StatusDownloaded = "downloaded"
+	StatusMissing    = "missing"
+	StatusPartial    = "partial"
+)
+
+// Chapter is a single chapter as listed on a source site, along with its local state.
+type Chapter struct {
+	Number   string    `json:"number"`
+	Title    string    `json:"title,omitempty"`
+	Filename string    `json:"filename"`
+	URL      string    `json:"url"`
+	Released time.Time `json:"released,omitzero"`
+	Source   string    `json:"source,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	Pages    []string  `json:"pages,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// chapterNumberRe matches the chapter number in any of the archive naming schemes used by the site packages:
+// ch005.cbz, ch05.cbz, 005.cbz, vol01ch05.cbz, ch043.4.cbz, ch012.2.1.cbz and ch072-season-1-end.cbz
+var chapterNumberRe = regexp.MustCompile(`^(?:vol\d+)?(?:ch(?:apter)?[-_ ]?)?0*(\d+)((?:\.\d+)*)`)
+
+// ChapterNumber extracts the chapter number from a chapter archive filename (eg: ch005.cbz -> "5",
+// ch043.4.cbz -> "43.4"). Returns an empty string if the filename does not contain a chapter number.
+func ChapterNumber(filename string) string {
+	name := strings.ToLower(filepath.Base(filename))
+	name = strings.TrimSuffix(name, ".cbz")
+
+	m := chapterNumberRe.FindStringSubmatch(name)
+	if m == nil {
+		return ""
+	}
+	return m[1] + m[2]
+}
+
+// ChapterFilename returns the archive name for a chapter number in the current naming template: ch<number>.cbz with
+// the whole number padded to 3 digits (eg: "5" -> ch005.cbz, "43.4" -> ch043.4.cbz).
+func ChapterFilename(number string) string {
+	whole, decimals, _ := strings.Cut(number, ".")
+	whole = strings.TrimLeft(whole, "0")
+	for len(whole) < 3 {
+		whole = "0" + whole
+	}
+	if decimals != "" {
+		return "ch" + whole + "." + decimals + ".cbz"
+	}
+	return "ch" + whole + ".cbz"
+}
+
+// TemplateFilename renames a chapter archive filename from any of the older naming schemes to the current template,
+// keeping any suffix after the chapter number (eg: vol01ch05.cbz -> ch005.cbz, ch72-season-1-end.cbz ->
+// ch072-season-1-end.cbz). Returns false if the filename does not contain a chapter number.
+func TemplateFilename(filename string) (string, bool) {
+	name := strings.ToLower(filepath.Base(filename))
+	name = strings.TrimSuffix(name, ".cbz")
+
+	m := chapterNumberRe.FindStringSubmatchIndex(name)
+	if m == nil {
+		return "", false
+	}
+	number := name[m[2]:m[3]] + name[m[4]:m[5]]
+	suffix := name[m[1]:]
+
+	return strings.TrimSuffix(ChapterFilename(number), ".cbz") + suffix + ".cbz", true
+}
+
+// PageFilename returns the name of a page image inside a chapter archive in the current naming template,
+// page-<index>.<ext> with the index padded to 3 digits. index starts at 1.
+func PageFilename(index int, ext string) string {
+	return fmt.Sprintf("page-%03d%s", index, strings.ToLower(ext))
+}
+
+// ChapterNumberValue converts a chapter number string to a float for ordering and range checks, parts past the first
+// decimal (eg: "12.2.1") are ignored.
+func ChapterNumberValue(number string) (float64, bool) {
+	parts := strings.SplitN(number, ".", 3)
+	value := parts[0]
+	if len(parts) > 1 {
+		value += "." + parts[1]
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// ChaptersFromMap converts a chapter map (filename as the key and url as the value) into a chapter slice sorted by
+// chapter number.
+func ChaptersFromMap(chapterMap map[string]string) []Chapter {
+	chapters := make([]Chapter, 0, len(chapterMap))
+	for filename, url := range chapterMap {
+		chapters = append(chapters, Chapter{
+			Number:   ChapterNumber(filename),
+			Filename: filename,
+			URL:      url,
+		})
+	}
+	SortChapters(chapters)
+	return chapters
+}
+
+// ChapterMap converts a chapter slice back into a chapter map (filename as the key and url as the value).
+func ChapterMap(chapters []Chapter) map[string]string {
+	chapterMap := make(map[string]string, len(chapters))
+	for _, ch := range chapters {
+		chapterMap[ch.Filename] = ch.URL
+	}
+	return chapterMap
+}
+
+// SortChapters sorts chapters by chapter number (ascending), falling back to the filename when the numbers match or
+// cannot be parsed.
+func SortChapters(chapters []Chapter) {
+	sort.SliceStable(chapters, func(i, j int) bool {
+		a, aok := ChapterNumberValue(chapters[i].Number)
+		b, bok := ChapterNumberValue(chapters[j].Number)
+		if aok && bok && a != b {
+			return a < b
+		}
+		if aok != bok {
+			return aok
+		}
+		return chapters[i].Filename < chapters[j].Filename
+	})
+}
+
+// ChapterStatus reports the local status of a chapter archive in dir: downloaded if it is a readable archive with at
+// least one page, partial if the file exists but is empty or broken, and missing otherwise.
+func ChapterStatus(dir, filename string) string {
+	path := filepath.Join(dir, filename)
+
+	if _, err := os.Stat(path); err != nil {
+		return StatusMissing
+	}
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return StatusPartial
+	}
+	defer archive.Close()
+
+	if len(archive.File) == 0 {
+		return StatusPartial
+	}
+	return StatusDownloaded
+}
@@ -0,0 +1,55 @@
+package parser
+
+import "testing"
+
+func TestChapterNumber(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "ch005.cbz", want: "5"},
+		{input: "ch05.cbz", want: "5"},
+		{input: "ch120.cbz", want: "120"},
+		{input: "005.cbz", want: "5"},
+		{input: "vol01ch05.cbz", want: "5"},
+		{input: "vol02ch03.5.cbz", want: "3.5"},
+		{input: "ch043.4.cbz", want: "43.4"},
+		{input: "ch012.2.1.cbz", want: "12.2.1"},
+		{input: "ch072-season-1-end.cbz", want: "72"},
+		{input: "ch000.cbz", want: "0"},
+		{input: "cover.jpg", want: ""},
+	}
+
+	for _, tt := range tests {
+		got := ChapterNumber(tt.input)
+		if got != tt.want {
+			t.Errorf("ChapterNumber(%q) = %q; want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateFilename(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "ch05.cbz", want: "ch005.cbz"},
+		{input: "ch005.cbz", want: "ch005.cbz"},
+		{input: "ch120.cbz", want: "ch120.cbz"},
+		{input: "005.cbz", want: "ch005.cbz"},
+		{input: "vol01ch05.cbz", want: "ch005.cbz"},
+		{input: "vol02ch03.5.cbz", want: "ch003.5.cbz"},
+		{input: "ch43.4.cbz", want: "ch043.4.cbz"},
+		{input: "ch72-season-1-end.cbz", want: "ch072-season-1-end.cbz"},
+		{input: "ch1000.cbz", want: "ch1000.cbz"},
+		{input: "ch0.cbz", want: "ch000.cbz"},
+		{input: "extra.cbz", want: ""},
+	}
+
+	for _, tt := range tests {
+		got, _ := TemplateFilename(tt.input)
+		if got != tt.want {
+			t.Errorf("TemplateFilename(%q) = %q; want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"scrape/atomicfile"
+)
+
+// ComicInfoFile is the name of the metadata entry inside a chapter archive
+const ComicInfoFile = "ComicInfo.xml"
+
+// ComicInfo is the chapter metadata stored in a chapter archive, the subset of the ComicRack ComicInfo.xml schema
+// the scrapers fill in. Web is the chapter URL and Notes records which source the chapter was downloaded from.
+type ComicInfo struct {
+	XMLName xml.Name `xml:"ComicInfo"`
+	Title   string   `xml:"Title,omitempty"`
+	Series  string   `xml:"Series,omitempty"`
+	Number  string   `xml:"Number,omitempty"`
+	Web     string   `xml:"Web,omitempty"`
+	Notes   string   `xml:"Notes,omitempty"`
+}
+
+// WriteComicInfo adds the metadata to the chapter archive at path, replacing any ComicInfo.xml already in it.
+func WriteComicInfo(path string, info *ComicInfo) error {
+	data, err := xml.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), data...)
+
+	return RewriteArchive(path,
+		func(f *zip.File) (string, bool) {
+			return f.Name, f.Name != ComicInfoFile
+		},
+		func(zw *zip.Writer) error {
+			w, err := zw.Create(ComicInfoFile)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		})
+}
+
+// ReadComicInfo reads the metadata of the chapter archive at path. Returns nil if the archive has none.
+func ReadComicInfo(path string) (*ComicInfo, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	f, err := archive.Open(ComicInfoFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var info ComicInfo
+	if err := xml.NewDecoder(f).Decode(&info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// RewriteArchive rewrites the zip archive at path. edit is called for every entry and returns the entry's new name
+// and whether to keep it, kept entries are copied without recompressing. add (optional) writes new entries after the
+// existing ones. The archive is replaced atomically.
+func RewriteArchive(path string, edit func(f *zip.File) (string, bool), add func(zw *zip.Writer) error) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	return atomicfile.Write(path, 0644, func(w io.Writer) error {
+		zw := zip.NewWriter(w)
+		for _, f := range archive.File {
+			name, keep := edit(f)
+			if !keep {
+				continue
+			}
+
+			header := f.FileHeader
+			header.Name = name
+
+			raw, err := f.OpenRaw()
+			if err != nil {
+				return err
+			}
+			w, err := zw.CreateRaw(&header)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(w, raw); err != nil {
+				return err
+			}
+		}
+
+		if add != nil {
+			if err := add(zw); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	})
+}
@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteComicInfo(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ch001.cbz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("001.jpg")
+	w.Write([]byte("page"))
+	zw.Close()
+	f.Close()
+
+	want := &ComicInfo{Series: "Ugly Complex", Number: "1", Web: "https://kunmanga.com/manga/ugly-complex/chapter-1/"}
+	if err := WriteComicInfo(path, want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadComicInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Series != want.Series || got.Number != want.Number || got.Web != want.Web {
+		t.Errorf("ReadComicInfo() = %+v, want %+v", got, want)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0644 {
+		t.Errorf("archive mode = %o, want 644", mode)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("files left next to the archive: %v", entries)
+	}
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"scrape/atomicfile"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultJPEGQuality is the quality pages are converted to JPEG with when no image_quality is configured
+const DefaultJPEGQuality = 90
+
+var jpegQuality atomic.Int64
+
+// JPEGQuality returns the quality the pages of the chapter being downloaded are converted to JPEG with
+func JPEGQuality() int {
+	if q := jpegQuality.Load(); q > 0 {
+		return int(q)
+	}
+	return DefaultJPEGQuality
+}
+
+// SetJPEGQuality sets the JPEG quality for the chapters downloaded next, 0 restores DefaultJPEGQuality
+func SetJPEGQuality(quality int) {
+	jpegQuality.Store(int64(quality))
+}
+
+// imageExts are the page image extensions by format
+var imageExts = map[string]string{".jpg": "jpeg", ".jpeg": "jpeg", ".png": "png", ".gif": "gif", ".webp": "webp"}
+
+// ConvertArchiveImages converts the page images of the chapter archive at path to format (jpg or png), JPEG pages
+// are encoded with quality. Pages already in format and the other entries (eg: ComicInfo.xml) are copied unchanged,
+// and the archive is replaced atomically.
+func ConvertArchiveImages(path, format string, quality int) error {
+	ext, target := ".jpg", "jpeg"
+	switch strings.ToLower(format) {
+	case "jpg", "jpeg":
+	case "png":
+		ext, target = ".png", "png"
+	default:
+		return fmt.Errorf("unsupported image format %q", format)
+	}
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	converted := false
+	for _, f := range archive.File {
+		if current, ok := imageExts[strings.ToLower(filepath.Ext(f.Name))]; ok && current != target {
+			converted = true
+			break
+		}
+	}
+	if !converted {
+		return nil
+	}
+
+	return atomicfile.Write(path, 0644, func(w io.Writer) error {
+		zw := zip.NewWriter(w)
+		for _, f := range archive.File {
+			current, ok := imageExts[strings.ToLower(filepath.Ext(f.Name))]
+			if !ok || current == target {
+				if err := zw.Copy(f); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := convertEntry(zw, f, ext, quality); err != nil {
+				return fmt.Errorf("failed to convert %s: %w", f.Name, err)
+			}
+		}
+		return zw.Close()
+	})
+}
+
+// convertEntry decodes the image of f and writes it into zw encoded as ext, under the same name with the new extension
+func convertEntry(zw *zip.Writer, f *zip.File, ext string, quality int) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	header := &zip.FileHeader{
+		Name:     strings.TrimSuffix(f.Name, filepath.Ext(f.Name)) + ext,
+		Method:   f.Method,
+		Modified: f.Modified,
+	}
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	if ext == ".png" {
+		return png.Encode(w, img)
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
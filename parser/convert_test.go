@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConvertArchiveImages(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	var pngData, jpgData bytes.Buffer
+	png.Encode(&pngData, img)
+	jpeg.Encode(&jpgData, img, nil)
+
+	path := filepath.Join(t.TempDir(), "ch001.cbz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{
+		{"page-001.png", pngData.Bytes()},
+		{"page-002.jpg", jpgData.Bytes()},
+		{"ComicInfo.xml", []byte("<ComicInfo></ComicInfo>")},
+	} {
+		w, _ := zw.Create(entry.name)
+		w.Write(entry.data)
+	}
+	zw.Close()
+	f.Close()
+
+	if err := ConvertArchiveImages(path, "jpg", 80); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+		r, _ := f.Open()
+		data, _ := io.ReadAll(r)
+		r.Close()
+
+		switch f.Name {
+		case "page-001.jpg":
+			if format, _ := DetectImageFormat(data); format != "jpeg" {
+				t.Errorf("page-001.jpg is %s, want jpeg", format)
+			}
+		case "page-002.jpg":
+			if !bytes.Equal(data, jpgData.Bytes()) {
+				t.Error("page-002.jpg was re-encoded, want it copied unchanged")
+			}
+		}
+	}
+	if want := []string{"page-001.jpg", "page-002.jpg", "ComicInfo.xml"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("entries = %v, want %v", names, want)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0644 {
+		t.Errorf("archive mode = %o, want 644", mode)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("files left next to the archive: %v", entries)
+	}
+
+	if err := ConvertArchiveImages(path, "tiff", 80); err == nil {
+		t.Error("converting to tiff succeeded, want an error")
+	}
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// releaseDateLayouts are the absolute date formats used in the chapter lists of the supported sites
+var releaseDateLayouts = []string{
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"January 2 2006",
+	"2 January 2006",
+	"2006-01-02",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"02/01/2006",
+}
+
+// relativeDateRe matches relative release dates such as "3 days ago" or "an hour ago"
+var relativeDateRe = regexp.MustCompile(`^(\d+|an?)\s+(sec|second|min|minute|hour|day|week|month|year)s?\s+ago$`)
+
+// ParseReleaseDate parses a chapter release date as shown in a chapter list, either an absolute date
+// ("September 3, 2024") or a relative one ("2 days ago", "yesterday"). Relative dates are resolved against now.
+func ParseReleaseDate(text string, now time.Time) (time.Time, bool) {
+	text = strings.ToLower(CleanText(text))
+	if text == "" {
+		return time.Time{}, false
+	}
+
+	switch text {
+	case "today", "just now":
+		return now, true
+	case "yesterday":
+		return now.AddDate(0, 0, -1), true
+	}
+
+	if m := relativeDateRe.FindStringSubmatch(text); m != nil {
+		n := 1
+		if m[1] != "a" && m[1] != "an" {
+			n, _ = strconv.Atoi(m[1])
+		}
+
+		switch m[2] {
+		case "sec", "second":
+			return now.Add(-time.Duration(n) * time.Second), true
+		case "min", "minute":
+			return now.Add(-time.Duration(n) * time.Minute), true
+		case "hour":
+			return now.Add(-time.Duration(n) * time.Hour), true
+		case "day":
+			return now.AddDate(0, 0, -n), true
+		case "week":
+			return now.AddDate(0, 0, -7*n), true
+		case "month":
+			return now.AddDate(0, -n, 0), true
+		case "year":
+			return now.AddDate(-n, 0, 0), true
+		}
+	}
+
+	// time.Parse matches month names case insensitively
+	for _, layout := range releaseDateLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// MadaraReleaseDate reads the release date of a Madara chapter list item (li.wp-manga-chapter). Recent chapters show
+// a "new" badge with the relative date in its title instead of the date text.
+func MadaraReleaseDate(item *goquery.Selection, now time.Time) time.Time {
+	date := item.Find("span.chapter-release-date").First()
+
+	text := date.Text()
+	if CleanText(text) == "" {
+		text = date.Find("a").AttrOr("title", "")
+	}
+
+	released, _ := ParseReleaseDate(text, now)
+	return released
+}
@@ -9,19 +9,15 @@ import (
 	"image/jpeg"
 	"io"
 	"log"
-	"math/rand"
-	"net/http"
+	"log/slog"
 	"os"
-	"os/exec"
-	"os/signal"
 	"path/filepath"
 	"regexp"
+	"scrape/atomicfile"
+	"scrape/webClient"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
-	"syscall"
-	"time"
 
 	_ "image/gif" // register GIF decoder
 	"image/png"   // register PNG decoder
@@ -60,13 +56,7 @@ func FileList(rootDir string, exclusionList ...string) ([]string, error) {
 // CBZExists checks if ch<num>.cbz already exists in current directory.
 // Returns true if exists, false otherwise. Returns error if unexpected.
 func CBZExists(chapterNumber int) (bool, error) {
-	var cbzName string
-	if chapterNumber < 10 {
-		cbzName = fmt.Sprintf("ch%02d.cbz", chapterNumber)
-	} else {
-		cbzName = fmt.Sprintf("ch%d.cbz", chapterNumber)
-	}
-	cbzPath := filepath.Join(".", cbzName)
+	cbzPath := filepath.Join(".", ChapterFilename(strconv.Itoa(chapterNumber)))
 
 	_, err := os.Stat(cbzPath)
 	if err == nil {
@@ -113,7 +103,7 @@ func FilterUndownloadedChapters(chapterStrs []string) []string {
 		if _, err := os.Stat(cbzName); os.IsNotExist(err) {
 			filtered = append(filtered, str)
 		} else {
-			log.Printf("[MAIN] Skipping chapter %s: CBZ already exists", raw)
+			slog.Info("skipping chapter, CBZ already exists", "chapter", raw)
 		}
 	}
 
@@ -134,11 +124,11 @@ func SortKeys(inputMap map[string]string) ([]string, error) {
 	return sortedList, nil
 }
 
-// DownloadAndConvertToJPG downloads an image from imageURL,
+// DownloadAndConvertToJPG downloads an image from imageURL, shown on pageURL,
 // converts to JPG if needed, and saves it inside targetDir.
 // Returns error if any.
-func DownloadAndConvertToJPG(imageURL, targetDir string) error {
-	resp, err := http.Get(imageURL)
+func DownloadAndConvertToJPG(imageURL, pageURL, targetDir string) error {
+	resp, err := webClient.GetImage(imageURL, pageURL)
 	if err != nil {
 		return fmt.Errorf("failed to download image: %w", err)
 	}
@@ -153,20 +143,32 @@ func DownloadAndConvertToJPG(imageURL, targetDir string) error {
 		return fmt.Errorf("failed to read image data: %w", err)
 	}
 
-	format, err := DetectImageFormat(imgBytes)
-	if err != nil {
-		return fmt.Errorf("failed to detect image format: %w", err)
-	}
+	return SaveImageToJPG(imgBytes, imageURL, targetDir)
+}
 
+// SaveImageToJPG saves already downloaded image bytes to targetDir as a JPG named after the image URL, the same way
+// DownloadAndConvertToJPG does, eg: bytes captured by the browser
+func SaveImageToJPG(imgBytes []byte, imageURL, targetDir string) error {
+	return WriteJPG(imgBytes, filepath.Join(targetDir, JPGName(imageURL)), JPEGQuality())
+}
+
+// JPGName returns the file name DownloadAndConvertToJPG and SaveImageToJPG save the image at imageURL as
+func JPGName(imageURL string) string {
 	base := filepath.Base(imageURL)
 	ext := strings.ToLower(filepath.Ext(base))
 	name := strings.TrimSuffix(base, ext)
 
 	// pad the image filename to 3 digits
-	padedFileName := padFileName(name + ".jpg")
+	return padFileName(name + ".jpg")
+}
 
-	// join teh padded dir / filename back together
-	outputFile := filepath.Join(targetDir, padedFileName)
+// WriteJPG writes image bytes (JPEG, PNG, GIF or WebP) to outputFile as a JPEG with the given quality, JPEG images are
+// written unchanged
+func WriteJPG(imgBytes []byte, outputFile string, quality int) error {
+	format, err := DetectImageFormat(imgBytes)
+	if err != nil {
+		return fmt.Errorf("failed to detect image format: %w", err)
+	}
 
 	// If already JPEG, just save raw bytes directly
 	if format == "jpeg" {
@@ -202,7 +204,7 @@ func DownloadAndConvertToJPG(imageURL, targetDir string) error {
 	}
 	defer outFile.Close()
 
-	opts := jpeg.Options{Quality: 90}
+	opts := jpeg.Options{Quality: quality}
 	err = jpeg.Encode(outFile, img, &opts)
 	if err != nil {
 		return fmt.Errorf("failed to encode jpeg: %w", err)
@@ -247,7 +249,7 @@ func padFileName(inputFileName string) string {
 		// convert the fielname string to an integer
 		fileNamePart, err := strconv.Atoi(parts[0])
 		if err != nil {
-			log.Printf("padFileName() - error when converting filename integer %v", err)
+			slog.Warn("filename is not a page number", "file", inputFileName, "error", err)
 		}
 		// pad the resulting integer
 		padded := fmt.Sprintf("%03d", fileNamePart)
@@ -256,7 +258,7 @@ func padFileName(inputFileName string) string {
 		outputFileName = padded + "." + parts[1]
 
 	} else {
-		log.Fatal("padFileName() - inputFilename must contain an extension eg: filename.ext")
+		log.Fatalf("page filename %q must have an extension, eg: 1.jpg", inputFileName)
 	}
 
 	return outputFileName
@@ -303,40 +305,44 @@ func CreateCbzFromDir(sourceDir, zipName string) error {
 	// Sort files alphabetically for ordered inclusion
 	sort.Strings(files)
 
-	// Create output cbz (zip) file
-	zipFile, err := os.Create(zipName)
-	if err != nil {
-		return fmt.Errorf("parser.CreateCbzFromDir() - failed to create cbz file: %w", err)
-	}
-	defer zipFile.Close()
-
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
-	// Add each file to the zip archive
-	for _, file := range files {
-		filePath := filepath.Join(sourceDir, file)
+	return writeCbz(sourceDir, files, zipName)
+}
 
-		err := func() error {
-			f, err := os.Open(filePath)
-			if err != nil {
+// writeCbz writes the files of sourceDir into the cbz (zip) file zipName in order. The archive is written next to
+// zipName and renamed over it once complete, so an interrupted run never leaves a truncated archive behind.
+func writeCbz(sourceDir string, files []string, zipName string) error {
+	err := atomicfile.Write(zipName, 0644, func(out io.Writer) error {
+		zipWriter := zip.NewWriter(out)
+
+		// Add each file to the zip archive
+		for _, file := range files {
+			filePath := filepath.Join(sourceDir, file)
+
+			err := func() error {
+				f, err := os.Open(filePath)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				w, err := zipWriter.Create(file)
+				if err != nil {
+					return err
+				}
+
+				_, err = io.Copy(w, f)
 				return err
-			}
-			defer f.Close()
-
-			w, err := zipWriter.Create(file)
+			}()
 			if err != nil {
-				return err
+				return fmt.Errorf("error adding %s to cbz: %w", filePath, err)
 			}
-
-			_, err = io.Copy(w, f)
-			return err
-		}()
-		if err != nil {
-			return fmt.Errorf("error adding %s to cbz: %w", filePath, err)
 		}
-	}
 
+		return zipWriter.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create cbz file %s: %w", zipName, err)
+	}
 	return nil
 }
 
@@ -388,7 +394,7 @@ func DecodeImageToPng(data []byte, sourceURL string) (image.Image, error) {
 
 	switch {
 	case len(data) >= 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF:
-		log.Println("Detected image format: JPEG")
+		slog.Debug("detected image format", "format", "jpeg", "url", sourceURL)
 		img, err = jpeg.Decode(bytes.NewReader(data))
 		if err != nil {
 			fmt.Printf("Failed to decode JPEG from %s: %v\n", sourceURL, err)
@@ -396,7 +402,7 @@ func DecodeImageToPng(data []byte, sourceURL string) (image.Image, error) {
 		}
 
 	case len(data) >= 8 && bytes.Equal(data[:8], []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}):
-		log.Println("Detected image format: PNG")
+		slog.Debug("detected image format", "format", "png", "url", sourceURL)
 		img, err = png.Decode(bytes.NewReader(data))
 		if err != nil {
 			fmt.Printf("Failed to decode PNG from %s: %v\n", sourceURL, err)
@@ -404,7 +410,7 @@ func DecodeImageToPng(data []byte, sourceURL string) (image.Image, error) {
 		}
 
 	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
-		log.Println("Detected image format: WebP")
+		slog.Debug("detected image format", "format", "webp", "url", sourceURL)
 		img, err = webp.Decode(bytes.NewReader(data))
 		if err != nil {
 			fmt.Printf("Failed to decode WebP from %s: %v\n", sourceURL, err)
@@ -420,32 +426,6 @@ func DecodeImageToPng(data []byte, sourceURL string) (image.Image, error) {
 	return img, nil
 }
 
-// check chrome/chromium browser is installed (dependancy)
-func CheckBrowser(funcName string) {
-	binaryList := []string{
-		"chromium",
-		"chromium-browser",
-		"google-chrome",
-		"google-chrome-beta",
-		"google-chrome-unstable",
-		"google-chrome-dev",
-	}
-
-	for _, name := range binaryList {
-		cmd := exec.Command("which", name)
-		output, _ := cmd.CombinedOutput() // ignore errors
-		path := strings.TrimSpace(string(output))
-		if path != "" {
-			log.Printf("%s - Chrome family browser found: %s", funcName, path)
-			return // silently continue
-		}
-	}
-
-	// none found, hard exit
-	fmt.Printf("Required dependency Chrome/Chromium browser not installed.\n")
-	log.Fatalf("Required dependency Chrome/Chromium browser not installed.")
-}
-
 // MgekoUrlToName extracts the manga name from a given Mgeko URL.
 // It supports URLs in the form:
 //
@@ -457,7 +437,7 @@ func CheckBrowser(funcName string) {
 //	Input:  https://www.mgeko.cc/manga/monster-eater/all-chapters/
 //	Output: monster-eater
 func MgekoUrlToName(url string) string {
-	log.Printf("extracting manga name from: %s", url)
+	slog.Debug("extracting manga name", "url", url)
 
 	// Split the URL into parts by "/"
 	parts := strings.Split(url, "/")
@@ -474,11 +454,11 @@ func MgekoUrlToName(url string) string {
 	return ""
 }
 
-// DownloadAndConvertToPNG downloads an image from imageURL,
+// DownloadAndConvertToPNG downloads an image from imageURL, shown on pageURL,
 // converts it to PNG if needed, and saves it inside targetDir.
 // Returns error if any.
-func DownloadAndConvertToPNG(imageURL, targetDir string) error {
-	resp, err := http.Get(imageURL)
+func DownloadAndConvertToPNG(imageURL, pageURL, targetDir string) error {
+	resp, err := webClient.GetImage(imageURL, pageURL)
 	if err != nil {
 		return fmt.Errorf("failed to download image: %w", err)
 	}
@@ -547,72 +527,6 @@ func DownloadAndConvertToPNG(imageURL, targetDir string) error {
 	return nil
 }
 
-// variables and constants for seeding concurrency safe random strings
-var (
-	randSrc = rand.New(rand.NewSource(time.Now().UnixNano()))
-	randMux sync.Mutex
-)
-
-const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
-
-// Generate random 5-letter string, safe for concurrent use.
-func RandomString5() string {
-	b := make([]byte, 5)
-
-	randMux.Lock()
-	for i := range b {
-		b[i] = letters[randSrc.Intn(len(letters))]
-	}
-	randMux.Unlock()
-
-	return string(b)
-}
-
-// CreateTempDir creates a unique temporary directory with the given prefix
-// and a random 5-letter string appended. Returns the temp directory path.
-func CreateTempDir(prefix string) (string, error) {
-	uniquePrefix := prefix + RandomString5() // append random string to prefix
-
-	tempDir, err := os.MkdirTemp("", uniquePrefix)
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp directory: %w", err)
-	}
-
-	return tempDir, nil
-}
-
-// CleanupTempDirs removes all directories in tempDirs slice.
-// It can also be set up to handle OS interrupts (SIGINT/SIGTERM).
-func CleanupTempDirs(tempDirs *[]string) {
-	// Catch OS interrupt signals
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		<-sigs
-		log.Println("Interrupt received, cleaning up temp directories...")
-		for _, dir := range *tempDirs {
-			if err := os.RemoveAll(dir); err != nil {
-				log.Printf("Failed to remove temp dir %s: %v", dir, err)
-			} else {
-				log.Printf("Removed tempdir: %s", dir)
-			}
-		}
-		os.Exit(1)
-	}()
-
-	// also remove temp dirs when this function exits
-	defer func() {
-		for _, dir := range *tempDirs {
-			if err := os.RemoveAll(dir); err != nil {
-				log.Printf("Failed to remove temp dir %s: %v", dir, err)
-			} else {
-				log.Printf("Removed tempdir: %s", dir)
-			}
-		}
-	}()
-}
-
 // Create file name, takes the resulting chapter number from either the URL or a list (must be a number in string format)
 func CreateFilename(inputChapterNumber string) string {
 	// Normalize the string: replace '-' and '_' with '.'
@@ -622,7 +536,7 @@ func CreateFilename(inputChapterNumber string) string {
 	// Try parsing as float
 	inputChapter, err := strconv.ParseFloat(normalized, 64)
 	if err != nil {
-		log.Printf("[ERROR ravenscans] - CreateFilename() - cannot parse chapter number %q: %v", inputChapterNumber, err)
+		slog.Error("cannot parse chapter number", "chapter", inputChapterNumber, "error", err)
 		return "ch000.cbz"
 	}
 
@@ -635,7 +549,7 @@ func CreateFilename(inputChapterNumber string) string {
 		return "ch" + padded + ".cbz"
 	}
 
-	// Otherwise keep decimal (remove trailing zeros)
-	chapterNum := fmt.Sprintf("ch%s.cbz", strings.TrimSuffix(strings.TrimSuffix(fmt.Sprintf("%03.2f", inputChapter), "0"), "."))
-	return chapterNum
+	// Otherwise keep the decimal part without trailing zeros, named like every other site (eg: 12.50 => ch012.5.cbz),
+	// the older ch12.5.cbz archives are renamed by migrate-names
+	return ChapterFilename(strconv.FormatFloat(inputChapter, 'f', -1, 64))
 }
@@ -32,3 +32,20 @@ func TestMgekoUrlToName(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateFilename(t *testing.T) {
+	tests := map[string]string{
+		"1":     "ch001.cbz",
+		"12.5":  "ch012.5.cbz",
+		"12-5":  "ch012.5.cbz",
+		"7.25":  "ch007.25.cbz",
+		"3.50":  "ch003.5.cbz",
+		"120":   "ch120.cbz",
+		"bonus": "ch000.cbz",
+	}
+	for input, want := range tests {
+		if got := CreateFilename(input); got != want {
+			t.Errorf("CreateFilename(%q) = %q; want %q", input, got, want)
+		}
+	}
+}
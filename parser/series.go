@@ -0,0 +1,220 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// SeriesInfo holds the descriptive metadata shown on a series landing page.
+// Any field the source does not expose is left empty.
+type SeriesInfo struct {
+	Source    string   `json:"source"`
+	URL       string   `json:"url"`
+	Title     string   `json:"title"`
+	AltTitles []string `json:"alt_titles,omitempty"`
+	Authors   []string `json:"authors,omitempty"`
+	Artists   []string `json:"artists,omitempty"`
+	Genres    []string `json:"genres,omitempty"`
+	Status    string   `json:"status,omitempty"`
+	Synopsis  string   `json:"synopsis,omitempty"`
+	CoverURL  string   `json:"cover_url,omitempty"`
+}
+
+// String renders the series info as a human readable block of text.
+func (s *SeriesInfo) String() string {
+	var b strings.Builder
+
+	line := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%-12s %s\n", label+":", value)
+		}
+	}
+
+	line("Title", s.Title)
+	line("Alt titles", strings.Join(s.AltTitles, "; "))
+	line("Authors", strings.Join(s.Authors, ", "))
+	line("Artists", strings.Join(s.Artists, ", "))
+	line("Genres", strings.Join(s.Genres, ", "))
+	line("Status", s.Status)
+	line("Cover", s.CoverURL)
+	line("Source", s.Source)
+	line("URL", s.URL)
+	if s.Synopsis != "" {
+		fmt.Fprintf(&b, "\n%s\n", s.Synopsis)
+	}
+
+	return b.String()
+}
+
+// whitespace collapses runs of whitespace (including newlines) when cleaning scraped text
+var whitespace = regexp.MustCompile(`\s+`)
+
+// CleanText trims and collapses the whitespace in text scraped from a page.
+func CleanText(text string) string {
+	return strings.TrimSpace(whitespace.ReplaceAllString(text, " "))
+}
+
+// SplitList splits a scraped "a, b / c" style list into cleaned, non empty entries.
+func SplitList(text string) []string {
+	var list []string
+	for _, part := range regexp.MustCompile(`[,;/]`).Split(text, -1) {
+		part = CleanText(part)
+		if part != "" && part != "-" && !strings.EqualFold(part, "updating") {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+// selectionTexts returns the cleaned text of every element in the selection.
+func selectionTexts(sel *goquery.Selection) []string {
+	var list []string
+	sel.Each(func(_ int, s *goquery.Selection) {
+		if text := CleanText(s.Text()); text != "" {
+			list = append(list, text)
+		}
+	})
+	return list
+}
+
+// ImageSource returns the real image URL of an <img>, preferring the lazy load attributes WordPress themes use over
+// the placeholder in src.
+func ImageSource(img *goquery.Selection) string {
+	for _, attr := range []string{"data-src", "data-lazy-src", "data-cfsrc", "src"} {
+		if val := strings.TrimSpace(img.AttrOr(attr, "")); val != "" && !strings.HasPrefix(val, "data:") {
+			return val
+		}
+	}
+	return ""
+}
+
+// ResolveURL resolves ref against the page it was found on, returning ref unchanged if either cannot be parsed.
+func ResolveURL(pageURL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ref
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// OpenGraphSeriesInfo builds series info from the OpenGraph / standard meta tags, every WordPress style site has
+// these even when the theme specific blocks are missing.
+func OpenGraphSeriesInfo(doc *goquery.Document, pageURL string) *SeriesInfo {
+	meta := func(names ...string) string {
+		for _, name := range names {
+			sel := doc.Find(fmt.Sprintf(`meta[property=%q], meta[name=%q]`, name, name)).First()
+			if val := CleanText(sel.AttrOr("content", "")); val != "" {
+				return val
+			}
+		}
+		return ""
+	}
+
+	title := meta("og:title", "twitter:title")
+	if title == "" {
+		title = CleanText(doc.Find("title").First().Text())
+	}
+
+	return &SeriesInfo{
+		URL:      pageURL,
+		Title:    title,
+		Synopsis: meta("og:description", "description", "twitter:description"),
+		CoverURL: ResolveURL(pageURL, meta("og:image", "twitter:image")),
+	}
+}
+
+// MadaraSeriesInfo extracts series info from the summary block of the Madara WordPress theme
+// (div.post-title, div.summary_image and the div.post-content_item rows).
+func MadaraSeriesInfo(doc *goquery.Document, pageURL string) *SeriesInfo {
+	info := OpenGraphSeriesInfo(doc, pageURL)
+
+	if title := doc.Find("div.post-title h1, div.post-title h3").First(); title.Length() > 0 {
+		// the title heading can contain "HOT" / "NEW" badges as child spans
+		title.Find("span").Remove()
+		info.Title = CleanText(title.Text())
+	}
+
+	if cover := ImageSource(doc.Find("div.summary_image img").First()); cover != "" {
+		info.CoverURL = ResolveURL(pageURL, cover)
+	}
+
+	doc.Find("div.post-content_item").Each(func(_ int, item *goquery.Selection) {
+		heading := strings.ToLower(CleanText(item.Find("div.summary-heading").Text()))
+		content := item.Find("div.summary-content")
+
+		switch {
+		case strings.HasPrefix(heading, "alternative"):
+			info.AltTitles = SplitList(content.Text())
+		case strings.HasPrefix(heading, "author"):
+			info.Authors = selectionTexts(content.Find("a"))
+		case strings.HasPrefix(heading, "artist"):
+			info.Artists = selectionTexts(content.Find("a"))
+		case strings.HasPrefix(heading, "genre"):
+			info.Genres = selectionTexts(content.Find("a"))
+		case strings.HasPrefix(heading, "status"):
+			info.Status = CleanText(content.Text())
+		}
+	})
+
+	if synopsis := CleanText(doc.Find("div.summary__content, div.description-summary div.summary__content").First().Text()); synopsis != "" {
+		info.Synopsis = synopsis
+	}
+
+	return info
+}
+
+// ThemesiaSeriesInfo extracts series info from the div.infox block of the MangaThemesia WordPress theme.
+func ThemesiaSeriesInfo(doc *goquery.Document, pageURL string) *SeriesInfo {
+	info := OpenGraphSeriesInfo(doc, pageURL)
+
+	if title := CleanText(doc.Find("div.infox h1.entry-title, h1.entry-title").First().Text()); title != "" {
+		info.Title = title
+	}
+
+	if alt := CleanText(doc.Find("div.infox .alternative, div.seriestualt").First().Text()); alt != "" {
+		info.AltTitles = SplitList(alt)
+	}
+
+	if cover := ImageSource(doc.Find("div.thumb img").First()); cover != "" {
+		info.CoverURL = ResolveURL(pageURL, cover)
+	}
+
+	// the side table rows look like: <div class="imptdt">Status <i>Ongoing</i></div>
+	doc.Find("div.tsinfo div.imptdt, div.infox div.fmed").Each(func(_ int, row *goquery.Selection) {
+		label := strings.ToLower(CleanText(row.Find("b").Text()))
+		value := CleanText(row.Find("i, span, a").First().Text())
+		if label == "" {
+			label = strings.ToLower(CleanText(row.Contents().First().Text()))
+		}
+
+		switch {
+		case strings.HasPrefix(label, "status"):
+			info.Status = value
+		case strings.HasPrefix(label, "author"):
+			info.Authors = SplitList(value)
+		case strings.HasPrefix(label, "artist"):
+			info.Artists = SplitList(value)
+		}
+	})
+
+	if genres := selectionTexts(doc.Find("div.infox span.mgen a, div.wd-full span.mgen a")); len(genres) > 0 {
+		info.Genres = genres
+	}
+
+	if synopsis := CleanText(doc.Find(`div.entry-content[itemprop="description"]`).First().Text()); synopsis != "" {
+		info.Synopsis = synopsis
+	}
+
+	return info
+}
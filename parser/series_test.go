@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestMadaraSeriesInfo(t *testing.T) {
+	html := `<html><head><meta property="og:title" content="Fallback"></head><body>
+<div class="post-title"><h1><span class="manga-title-badges hot">HOT</span> Ugly Complex </h1></div>
+<div class="summary_image"><a href="#"><img src="data:image/gif;base64,xx" data-src="/covers/ugly.jpg"></a></div>
+<div class="post-content_item"><div class="summary-heading"><h5>Alternative</h5></div><div class="summary-content">Complex, Ugly / 못난이</div></div>
+<div class="post-content_item"><div class="summary-heading"><h5>Author(s)</h5></div><div class="summary-content"><div class="author-content"><a>Kim</a><a>Lee</a></div></div></div>
+<div class="post-content_item"><div class="summary-heading"><h5>Genre(s)</h5></div><div class="summary-content"><div class="genres-content"><a>Drama</a>, <a>Romance</a></div></div></div>
+<div class="post-content_item"><div class="summary-heading"><h5>Status</h5></div><div class="summary-content"> OnGoing </div></div>
+<div class="description-summary"><div class="summary__content"><p>A   story
+about things.</p></div></div>
+</body></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := MadaraSeriesInfo(doc, "https://kunmanga.com/manga/ugly-complex/")
+	want := &SeriesInfo{
+		URL:       "https://kunmanga.com/manga/ugly-complex/",
+		Title:     "Ugly Complex",
+		AltTitles: []string{"Complex", "Ugly", "못난이"},
+		Authors:   []string{"Kim", "Lee"},
+		Genres:    []string{"Drama", "Romance"},
+		Status:    "OnGoing",
+		Synopsis:  "A story about things.",
+		CoverURL:  "https://kunmanga.com/covers/ugly.jpg",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MadaraSeriesInfo() = %+v; want %+v", got, want)
+	}
+}
+
+func TestThemesiaSeriesInfo(t *testing.T) {
+	html := `<html><body>
+<div class="thumb"><img src="https://cdn.example.com/cover.webp"></div>
+<div class="tsinfo"><div class="imptdt">Status <i>Ongoing</i></div><div class="imptdt">Artist <i>Park</i></div></div>
+<div class="infox"><h1 class="entry-title">Raven Title</h1><span class="alternative">Alt One, Alt Two</span>
+<div class="fmed"><b>Author</b><span>Choi</span></div>
+<div class="wd-full"><span class="mgen"><a>Action</a><a>Fantasy</a></span></div></div>
+<div class="entry-content" itemprop="description"><p>Synopsis text.</p></div>
+</body></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := ThemesiaSeriesInfo(doc, "https://ravenscans.com/manga/raven/")
+	want := &SeriesInfo{
+		URL:       "https://ravenscans.com/manga/raven/",
+		Title:     "Raven Title",
+		AltTitles: []string{"Alt One", "Alt Two"},
+		Authors:   []string{"Choi"},
+		Artists:   []string{"Park"},
+		Genres:    []string{"Action", "Fantasy"},
+		Status:    "Ongoing",
+		Synopsis:  "Synopsis text.",
+		CoverURL:  "https://cdn.example.com/cover.webp",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ThemesiaSeriesInfo() = %+v; want %+v", got, want)
+	}
+}
@@ -0,0 +1,207 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"scrape/atomicfile"
+	"slices"
+	"sync"
+	"time"
+)
+
+// manifestFile lists the verified pages of a staging directory
+const manifestFile = ".pages.json"
+
+var (
+	stagingMu  sync.Mutex
+	stagingDir string
+)
+
+// DefaultStagingDir returns the user cache directory the chapters are staged in, eg: ~/.cache/scrape/staging
+func DefaultStagingDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "scrape-staging")
+	}
+	return filepath.Join(dir, "scrape", "staging")
+}
+
+// ConfigureStaging sets the directory the chapters are staged in, DefaultStagingDir when empty
+func ConfigureStaging(dir string) {
+	stagingMu.Lock()
+	defer stagingMu.Unlock()
+
+	stagingDir = dir
+}
+
+// stagingRoot returns the configured staging directory
+func stagingRoot() string {
+	stagingMu.Lock()
+	defer stagingMu.Unlock()
+
+	if stagingDir == "" {
+		return DefaultStagingDir()
+	}
+	return stagingDir
+}
+
+// Stage is the staging directory of a chapter. It is the same directory on every run for the chapter URL, and
+// records the pages written into it once they are verified, so a chapter download that is interrupted resumes from
+// the pages it has instead of downloading them again.
+type Stage struct {
+	// Dir holds the page files of the chapter
+	Dir string
+
+	mu       sync.Mutex
+	manifest stageManifest
+}
+
+// stageManifest is the manifest file of a staging directory
+type stageManifest struct {
+	Chapter string                `json:"chapter"`
+	Pages   map[string]stagedPage `json:"pages"`
+}
+
+// stagedPage is a verified page file of a staging directory
+type stagedPage struct {
+	URL    string    `json:"url,omitempty"`
+	Size   int64     `json:"size"`
+	SHA256 string    `json:"sha256"`
+	Staged time.Time `json:"staged"`
+}
+
+// StageChapter opens the staging directory of the chapter at chapterURL, creating it or resuming the pages staged by
+// an earlier run.
+func StageChapter(chapterURL string) (*Stage, error) {
+	sum := sha256.Sum256([]byte(chapterURL))
+	s := &Stage{
+		Dir:      filepath.Join(stagingRoot(), hex.EncodeToString(sum[:8])),
+		manifest: stageManifest{Chapter: chapterURL, Pages: map[string]stagedPage{}},
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the staging directory: %w", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.Dir, manifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the staging manifest: %w", err)
+	}
+	var manifest stageManifest
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Chapter != chapterURL {
+		// an unreadable manifest only costs downloading the pages again
+		slog.Warn("invalid staging manifest, staging the chapter again", "dir", s.Dir, "error", err)
+		return s, nil
+	}
+	if manifest.Pages != nil {
+		s.manifest.Pages = manifest.Pages
+	}
+	slog.Debug("resuming the staged chapter", "chapter", chapterURL, "dir", s.Dir, "pages", len(s.manifest.Pages))
+	return s, nil
+}
+
+// Has reports whether the page file name was staged by this or an earlier run and is unchanged since
+func (s *Stage) Has(name string) bool {
+	s.mu.Lock()
+	page, ok := s.manifest.Pages[name]
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	size, sum, err := fileDigest(filepath.Join(s.Dir, name))
+	if err != nil || size != page.Size || sum != page.SHA256 {
+		slog.Warn("staged page changed, downloading it again", "dir", s.Dir, "page", name)
+		s.mu.Lock()
+		delete(s.manifest.Pages, name)
+		s.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+// Add records the page file name, downloaded from imageURL, as staged. Its size and hash are kept to verify it on
+// the next run.
+func (s *Stage) Add(name, imageURL string) error {
+	size, sum, err := fileDigest(filepath.Join(s.Dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to verify the staged page %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.manifest.Pages[name] = stagedPage{URL: imageURL, Size: size, SHA256: sum, Staged: time.Now()}
+	data, err := json.MarshalIndent(s.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(filepath.Join(s.Dir, manifestFile), data, 0644)
+}
+
+// Page stages the page file name: fetch is called to write it into Dir unless it was staged already, then it is
+// recorded with Add.
+func (s *Stage) Page(name, imageURL string, fetch func() error) error {
+	if s.Has(name) {
+		slog.Debug("page already staged", "dir", s.Dir, "page", name, "url", imageURL)
+		return nil
+	}
+	if err := fetch(); err != nil {
+		return err
+	}
+	return s.Add(name, imageURL)
+}
+
+// ErrIncompleteStage is returned by Archive when fewer pages are staged than the chapter has
+var ErrIncompleteStage = errors.New("chapter pages missing")
+
+// Archive writes the staged pages into the cbz archive zipName, replacing it atomically, and removes the staging
+// directory once the archive is written. Files of Dir that were not staged, eg: a page whose write was interrupted,
+// are left out. A chapter with fewer than pages staged is not archived, its stage is kept for the next run to fetch
+// the missing pages.
+func (s *Stage) Archive(zipName string, pages int) error {
+	s.mu.Lock()
+	var files []string
+	for name := range s.manifest.Pages {
+		files = append(files, name)
+	}
+	s.mu.Unlock()
+	slices.Sort(files)
+
+	if len(files) < pages {
+		return fmt.Errorf("%w: %d of %d pages staged in %s, kept for the next run", ErrIncompleteStage, len(files), pages, s.Dir)
+	}
+
+	if err := writeCbz(s.Dir, files, zipName); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(s.Dir); err != nil {
+		slog.Warn("failed to remove the staging directory", "dir", s.Dir, "error", err)
+	}
+	return nil
+}
+
+// fileDigest returns the size and the hex SHA-256 of the file at path
+func fileDigest(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStageResume(t *testing.T) {
+	ConfigureStaging(t.TempDir())
+	t.Cleanup(func() { ConfigureStaging("") })
+
+	const chapterURL = "https://example.com/series/chapter-1/"
+	stage, err := StageChapter(chapterURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, data string) func() error {
+		return func() error { return os.WriteFile(filepath.Join(stage.Dir, name), []byte(data), 0644) }
+	}
+	if err := stage.Page("001.jpg", "https://cdn.example.com/1.jpg", write("001.jpg", "page one")); err != nil {
+		t.Fatal(err)
+	}
+	if err := stage.Page("002.jpg", "https://cdn.example.com/2.jpg", write("002.jpg", "page two")); err != nil {
+		t.Fatal(err)
+	}
+	// an interrupted write is never recorded
+	if err := stage.Page("003.jpg", "https://cdn.example.com/3.jpg", func() error {
+		os.WriteFile(filepath.Join(stage.Dir, "003.jpg"), []byte("pa"), 0644)
+		return errors.New("connection reset")
+	}); err == nil {
+		t.Fatal("Page() error = nil, want the fetch error")
+	}
+
+	// the next run resumes from the same directory
+	resumed, err := StageChapter(chapterURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Dir != stage.Dir {
+		t.Fatalf("resumed Dir = %q, want %q", resumed.Dir, stage.Dir)
+	}
+	if err := resumed.Page("001.jpg", "https://cdn.example.com/1.jpg", func() error {
+		t.Error("fetched the staged page 001.jpg again")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Has("003.jpg") {
+		t.Error("Has(003.jpg) = true for an interrupted page")
+	}
+
+	// a page changed since it was staged is fetched again
+	if err := os.WriteFile(filepath.Join(stage.Dir, "002.jpg"), []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fetched := false
+	if err := resumed.Page("002.jpg", "https://cdn.example.com/2.jpg", func() error {
+		fetched = true
+		return write("002.jpg", "page two")()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !fetched {
+		t.Error("the changed page 002.jpg was not fetched again")
+	}
+
+	// 003.jpg never made it into the stage, the chapter is not archived and its pages are kept
+	zipName := filepath.Join(t.TempDir(), "ch001.cbz")
+	if err := resumed.Archive(zipName, 3); !errors.Is(err, ErrIncompleteStage) {
+		t.Fatalf("Archive() of an incomplete stage error = %v, want ErrIncompleteStage", err)
+	}
+	if _, err := os.Stat(zipName); !os.IsNotExist(err) {
+		t.Errorf("an incomplete chapter was archived, stat error = %v", err)
+	}
+	if !resumed.Has("001.jpg") {
+		t.Error("the staged pages were removed with the chapter incomplete")
+	}
+
+	if err := resumed.Archive(zipName, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.OpenReader(zipName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	if want := []string{"001.jpg", "002.jpg"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("archive files = %v, want %v", names, want)
+	}
+
+	if _, err := os.Stat(stage.Dir); !os.IsNotExist(err) {
+		t.Errorf("staging directory still exists after Archive, stat error = %v", err)
+	}
+	parts, _ := filepath.Glob(filepath.Join(filepath.Dir(zipName), "*.part"))
+	if len(parts) != 0 {
+		t.Errorf("temporary archive files left: %v", parts)
+	}
+}
+
+func TestStageChapterInvalidManifest(t *testing.T) {
+	ConfigureStaging(t.TempDir())
+	t.Cleanup(func() { ConfigureStaging("") })
+
+	stage, err := StageChapter("https://example.com/chapter-2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(stage.Dir, "001.jpg"), []byte("page"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(stage.Dir, manifestFile), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := StageChapter("https://example.com/chapter-2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Has("001.jpg") {
+		t.Error("Has(001.jpg) = true with an unreadable manifest")
+	}
+}
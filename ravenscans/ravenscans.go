@@ -7,14 +7,16 @@ import (
 	"image"
 	"image/jpeg"
 	"io"
-	"log"
-	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"scrape/browser"
+	"scrape/debugbundle"
+	"scrape/logging"
 	"scrape/parser"
+	"scrape/webClient"
 	"sort"
 	"strconv"
 	"strings"
@@ -29,21 +31,42 @@ import (
 	_ "image/png" // register PNG decoder
 )
 
-// returns the chapter urls as a map (filename as the key and url as the value)
-func chapterUrls(mangaUrl string) (map[string]string, error) {
-	chapterMap := make(map[string]string)
+var logger = logging.Site("ravenscans")
 
-	c := colly.NewCollector()
+// SeriesChapters returns the chapters listed on the series page
+func SeriesChapters(mangaUrl string) ([]parser.Chapter, error) {
+	chapters, err := chapterList(mangaUrl)
+	if err != nil {
+		return nil, err
+	}
+	parser.SortChapters(chapters)
+	return chapters, nil
+}
+
+// ChapterImages loads the chapter page and returns the ordered page image URLs
+func ChapterImages(chapterUrl string) ([]string, error) {
+	pageContent, err := visitPage(chapterUrl)
+	if err != nil {
+		return nil, err
+	}
+	return extractChapterImageUrls(pageContent, chapterUrl), nil
+}
+
+// scrape the chapter list from the series page
+func chapterList(mangaUrl string) ([]parser.Chapter, error) {
+	var chapters []parser.Chapter
+
+	c := webClient.NewCollector()
 
 	// Debug hooks
 	c.OnRequest(func(r *colly.Request) {
-		log.Printf("[DEBUG] ravenscans ChapterURLs() - Visiting %s", r.URL.String())
+		logger.Debug("visiting", "url", r.URL.String())
 	})
 	c.OnResponse(func(r *colly.Response) {
-		log.Printf("[DEBUG] ravenscans ChapterURLs() - Response from %s - Status %d", r.Request.URL, r.StatusCode)
+		logger.Debug("response", "url", r.Request.URL, "status", r.StatusCode)
 	})
 	c.OnError(func(r *colly.Response, err error) {
-		log.Printf("[ERROR] ravenscans ChapterURLs() - Request to %s failed: %v (Status: %d)", r.Request.URL, err, r.StatusCode)
+		logger.Error("request failed", "url", r.Request.URL, "status", r.StatusCode, "error", err)
 	})
 
 	c.OnHTML("div.eplister ul li", func(e *colly.HTMLElement) {
@@ -53,9 +76,17 @@ func chapterUrls(mangaUrl string) (map[string]string, error) {
 
 		filename := parser.CreateFilename(rawNum)
 
-		log.Printf("[INFO] ravenscans ChapterURLs() - Chapter %s -> %s (%s) => filename %s", rawNum, url, title, filename)
+		logger.Debug("found chapter", "series", mangaUrl, "chapter", rawNum, "url", url, "title", title, "file", filename)
+
+		released, _ := parser.ParseReleaseDate(e.ChildText("span.chapterdate"), time.Now())
 
-		chapterMap[filename] = url
+		chapters = append(chapters, parser.Chapter{
+			Number:   parser.ChapterNumber(filename),
+			Title:    title,
+			Filename: filename,
+			URL:      url,
+			Released: released,
+		})
 	})
 
 	// visit the chapter page
@@ -64,21 +95,13 @@ func chapterUrls(mangaUrl string) (map[string]string, error) {
 		return nil, err
 	}
 
-	return chapterMap, nil
+	return chapters, nil
 }
 
 // Loads a given URL, ensuring all JavaScript and resources are loaded.
 func visitPage(url string) (string, error) {
-	// Create a new context
-	ctx, cancel := chromedp.NewContext(context.Background())
-	defer cancel()
-
-	// Create a timeout context for the operation
-	ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
 	var htmlContent string
-	err := chromedp.Run(ctx,
+	err := browser.Run(30*time.Second,
 		chromedp.Navigate(url),
 		// Wait for the 'networkidle0' event, which signifies that there are no
 		// more than 0 network connections for at least 500ms. This helps ensure
@@ -86,6 +109,7 @@ func visitPage(url string) (string, error) {
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			return chromedp.WaitReady("body").Do(ctx) // Wait for the body element to be ready
 		}),
+		browser.ScrollFor("ravenscans", "#readerarea img"),
 		chromedp.OuterHTML("html", &htmlContent), // Get the outer HTML of the entire document
 	)
 	if err != nil {
@@ -161,85 +185,55 @@ func extractChapterImageUrls(pageContent, chapterURL string) []string {
 	return orderedURLs
 }
 
-func DownloadMangaChapters(mangaUrl string) {
-	// Get list of chapters already downloaded (only *.cbz)
-	downloadedChapters, err := parser.FileList(".")
-	if err != nil {
-		log.Fatalf("error getting file list: %v", err)
-	}
-	downloadedChapters = parser.FilterCBZFiles(downloadedChapters)
-	log.Printf("[INFO] raven scans DownloadMangaChapters() - chapters already downloaded: %v, from URL: %s", downloadedChapters, mangaUrl)
+// DownloadChapter grabs the chapter page, extracts the image urls and downloads the images into the chapter cbz file
+func DownloadChapter(chapter parser.Chapter) error {
+	chapterName := chapter.Filename
+	chapterUrl := chapter.URL
+	fmt.Printf("Visiting: %s\n", chapterUrl)
 
-	// Get all chapters from website
-	chapterMap, err := chapterUrls(mangaUrl)
+	// get the full page content (after JS loading), extract image urls for the chapter, remove unrelated,
+	// deduplicate and sort
+	logger.Info("extracting, deduplicating and sorting image URLs", "chapter", chapter.Number, "url", chapterUrl)
+	imageUrls, err := ChapterImages(chapterUrl)
 	if err != nil {
-		log.Fatalf("[ERROR] raven scans DownloadMangaChapters() - Get Chapter URLs failed: %v", err)
+		return fmt.Errorf("failed to load chapter page %s: %w", chapterUrl, err)
 	}
-
-	// Remove already downloaded chapters from the map
-	for _, chName := range downloadedChapters {
-		if _, ok := chapterMap[chName]; ok {
-			delete(chapterMap, chName)
-			log.Printf("[INFO] raven scans DownloadMangaChapters() - %s already downloaded, removed from chapterMap", chName)
-		}
+	if len(imageUrls) == 0 {
+		return fmt.Errorf("%s: %w", chapterName, debugbundle.NoMatch(chapterUrl, "https://manga.pics/<series>/chapter-<n>/<page>.jpg", "images"))
 	}
 
-	// sort the chapters
-	sortedChapterList, sortError := parser.SortKeys(chapterMap)
-	if sortError != nil {
-		log.Fatalf("[ERROR] raven scans DownloadMangaChapters() - failed to srot chapter keys %v", sortError)
+	// Stage this chapter's images, the staging directory is kept across runs until the chapter is archived
+	stage, err := parser.StageChapter(chapterUrl)
+	if err != nil {
+		return fmt.Errorf("failed to stage %s: %w", chapterName, err)
 	}
 
-	// grab page, extract image urls and download images in the chapter
-	//for chapterName, chapterUrl := range chapterMap {
-	for _, chapterName := range sortedChapterList {
-		chapterUrl := chapterMap[chapterName]
-		fmt.Printf("[INFO] raven scans DownloadMangaChapters() - visiting: %s\n", chapterUrl)
+	logger.Info("starting chapter download", "chapter", chapter.Number, "url", chapterUrl, "pages", len(imageUrls))
 
-		// get the fuillpage content (after JS loading)
-		pageContent, _ := visitPage(chapterUrl)
-
-		// extract image urls for the chapter, remove unrealted, deduplicate and sort
-		log.Println("[INFO] raven scans DownloadMangaChapters() - extracing, deduplicating and sorting image urls:")
-		imageUrls := extractChapterImageUrls(pageContent, chapterUrl)
-
-		// Create temp directory for this chapter's images
-		tmpDir, err := os.MkdirTemp("", "chapter-"+chapterName)
-		if err != nil {
-			log.Printf("[ERROR] raven scans DownloadMangaChapters() - failed to create temp dir for %s: %v", chapterName, err)
-			continue
-		}
-		// Schedule temp dir removal after the current loop iteration ends
-		defer func(dir string) {
-			if err := os.RemoveAll(dir); err != nil {
-				log.Printf("[ERROR] raven scans DownloadMangaChapters() - failed to remove temp dir %s: %v", dir, err)
-			}
-		}(tmpDir)
-
-		log.Printf("Starting download for chapter: %s, with %d images", chapterName, len(chapterMap))
-
-		for imgIndex, imageUrl := range imageUrls {
-			imgDlErr := downloadAndConvertToJPG(imageUrl, tmpDir, chapterName, imgIndex, len(imageUrls))
-			if imgDlErr != nil {
-				log.Printf("[ERROR] raven scans DownloadMangaChapters() - error downloading image %d of chapter %s: %v", imgIndex, chapterName, err)
-			}
-		}
-		// Create CBZ from temp dir images
-		targetFile := "./" + chapterName
-		err = parser.CreateCbzFromDir(tmpDir, targetFile)
-		if err != nil {
-			log.Printf("[ERROR] raven scans DownloadMangaChapters() - failed to create CBZ for chapter %s: %v", chapterName, err)
-		} else {
-			log.Printf("[INFO] raven scans DownloadMangaChapters() - Created CBZ file: %s", targetFile)
-			fmt.Printf("Created CBZ file: %s\n", targetFile)
+	for imgIndex, imageUrl := range imageUrls {
+		imgDlErr := stage.Page(fmt.Sprintf("%03d.jpg", imgIndex), imageUrl, func() error {
+			return downloadAndConvertToJPG(imageUrl, chapterUrl, stage.Dir, chapterName, imgIndex, len(imageUrls))
+		})
+		if imgDlErr != nil {
+			logger.Error("failed to download image", "chapter", chapter.Number, "page", imgIndex, "url", imageUrl, "error", imgDlErr)
 		}
 	}
+	// Create CBZ from the staged images
+	targetFile := "./" + chapterName
+	err = stage.Archive(targetFile, len(imageUrls))
+	if err != nil {
+		return fmt.Errorf("failed to create CBZ for chapter %s: %w", chapterName, err)
+	}
+	logger.Info("created CBZ", "chapter", chapter.Number, "file", targetFile)
+	fmt.Printf("Created CBZ file: %s\n", targetFile)
+
+	return nil
 }
 
-func downloadAndConvertToJPG(imageURL, targetDir, chapterName string, imageIndex, totalImages int) error {
-	log.Printf("[DOWNLOAD] Chapter %s: Downloading image %d/%d: %s", chapterName, imageIndex, totalImages, imageURL)
+func downloadAndConvertToJPG(imageURL, chapterURL, targetDir, chapterName string, imageIndex, totalImages int) error {
+	logger.Info("downloading image", "chapter", chapterName, "page", imageIndex, "pages", totalImages, "url", imageURL)
 
-	resp, err := http.Get(imageURL)
+	resp, err := webClient.GetImage(imageURL, chapterURL)
 	if err != nil {
 		return fmt.Errorf("failed to download image: %w", err)
 	}
@@ -259,7 +253,7 @@ func downloadAndConvertToJPG(imageURL, targetDir, chapterName string, imageIndex
 		return fmt.Errorf("failed to detect image format: %w", err)
 	}
 
-	log.Printf("[INFO] Detected image format: %s", format)
+	logger.Debug("detected image format", "format", format, "url", imageURL)
 
 	paddedIndex := fmt.Sprintf("%03d", imageIndex)
 	filename := paddedIndex + ".jpg" // Always save as .jpg to enforce conversion
@@ -267,7 +261,7 @@ func downloadAndConvertToJPG(imageURL, targetDir, chapterName string, imageIndex
 	outputFile := filepath.Join(targetDir, filename)
 
 	if format == "jpeg" {
-		log.Printf("[INFO] Image is already JPEG, saving directly as %s", outputFile)
+		logger.Debug("image is already JPEG, saving it as is", "file", outputFile)
 		err = os.WriteFile(outputFile, imgBytes, 0644)
 		if err != nil {
 			return fmt.Errorf("failed to save jpeg image: %w", err)
@@ -275,8 +269,6 @@ func downloadAndConvertToJPG(imageURL, targetDir, chapterName string, imageIndex
 		return nil
 	}
 
-	log.Printf("[INFO] Decoding image to convert to JPEG")
-
 	var img image.Image
 
 	switch format {
@@ -294,21 +286,29 @@ func downloadAndConvertToJPG(imageURL, targetDir, chapterName string, imageIndex
 		return fmt.Errorf("unsupported image format: %s", format)
 	}
 
-	log.Printf("[INFO] Encoding image as JPEG and saving to %s", outputFile)
-
 	outFile, err := os.Create(outputFile)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
 	defer outFile.Close()
 
-	opts := jpeg.Options{Quality: 75} // the compression for the jpeg 75 == small file size, 90 == large file size
+	opts := jpeg.Options{Quality: parser.JPEGQuality()} // image_quality, 75 == small file size, 90 == large file size
 	err = jpeg.Encode(outFile, img, &opts)
 	if err != nil {
 		return fmt.Errorf("failed to encode jpeg: %w", err)
 	}
 
-	log.Printf("[SUCCESS] Converted and saved image as JPEG: %s", outputFile)
+	logger.Debug("converted image to JPEG", "format", format, "file", outputFile)
 
 	return nil
 }
+
+// SeriesInfo scrapes the series metadata from the MangaThemesia info block of the ravenscans series page
+func SeriesInfo(mangaUrl string) (*parser.SeriesInfo, error) {
+	doc, err := webClient.FetchDocument(mangaUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.ThemesiaSeriesInfo(doc, mangaUrl), nil
+}
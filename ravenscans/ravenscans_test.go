@@ -0,0 +1,61 @@
+package ravenscans
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"scrape/parser"
+	"scrape/replay/replaytest"
+	"slices"
+	"testing"
+	"time"
+)
+
+const seriesURL = "https://ravenscans.com/manga/the-tyrant-wants-to-live-honestly/"
+
+func TestSeriesChapters(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	chapters, err := SeriesChapters(seriesURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []parser.Chapter{
+		{Number: "1", Title: "Chapter 1", Filename: "ch001.cbz", URL: "https://ravenscans.com/the-tyrant-wants-to-live-honestly-chapter-1/",
+			Released: time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC)},
+		{Number: "12.5", Title: "Chapter 12.5", Filename: "ch012.5.cbz", URL: "https://ravenscans.com/the-tyrant-wants-to-live-honestly-chapter-12-5/",
+			Released: time.Date(2024, time.September, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	if !slices.EqualFunc(chapters, want, func(a, b parser.Chapter) bool {
+		return a.Number == b.Number && a.Title == b.Title && a.Filename == b.Filename && a.URL == b.URL && a.Released.Equal(b.Released)
+	}) {
+		t.Errorf("SeriesChapters() = %+v, expected %+v", chapters, want)
+	}
+}
+
+// the chapter page is loaded in the browser, its image URLs are extracted from the recorded page
+func TestExtractChapterImageUrls(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	chapterURL := "https://ravenscans.com/the-tyrant-wants-to-live-honestly-chapter-1/"
+	resp, err := http.Get(chapterURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// deduplicated, ordered by page number and without the images of other series
+	want := []string{
+		"https://manga.pics/the-tyrant-wants-to-live-honestly/chapter-1/1.jpg",
+		"https://manga.pics/the-tyrant-wants-to-live-honestly/chapter-1/2.jpg",
+		"https://manga.pics/the-tyrant-wants-to-live-honestly/chapter-1/10.jpg",
+	}
+	if got := extractChapterImageUrls(string(page), chapterURL); !slices.Equal(got, want) {
+		t.Errorf("extractChapterImageUrls() = %v, expected %v", got, want)
+	}
+}
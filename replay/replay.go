@@ -0,0 +1,206 @@
+// record the HTTP responses of a scrape into a fixture directory, replayed in the tests by replaytest
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// IndexFile lists the recorded responses of a fixture directory, the bodies are stored next to it
+const IndexFile = "replay.json"
+
+// Exchange is one recorded response
+type Exchange struct {
+	URL         string `json:"url"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	// File holds the body, relative to the fixture directory
+	File string `json:"file,omitempty"`
+}
+
+// Load reads the index of the fixture directory dir
+func Load(dir string) ([]Exchange, error) {
+	data, err := os.ReadFile(filepath.Join(dir, IndexFile))
+	if err != nil {
+		return nil, err
+	}
+	var exchanges []Exchange
+	if err := json.Unmarshal(data, &exchanges); err != nil {
+		return nil, fmt.Errorf("invalid %s in %s: %w", IndexFile, dir, err)
+	}
+	return exchanges, nil
+}
+
+// Recorder is a round tripper that saves every GET response it passes on into a fixture directory. Only the first
+// MaxImages images are saved, enough samples for the tests without recording whole chapters.
+type Recorder struct {
+	Dir       string
+	MaxImages int
+
+	next      http.RoundTripper
+	mu        sync.Mutex
+	exchanges []Exchange
+	images    int
+}
+
+// NewRecorder returns a Recorder saving into dir the responses of next, adding to the fixtures already in dir
+func NewRecorder(dir string, next http.RoundTripper, maxImages int) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	exchanges, err := Load(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return &Recorder{Dir: dir, MaxImages: maxImages, next: next, exchanges: exchanges}, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	return r.record(req, r.next)
+}
+
+// Unwrap returns the round tripper the responses are recorded from
+func (r *Recorder) Unwrap() http.RoundTripper {
+	return r.next
+}
+
+// Via returns a round tripper recording into r the responses of next instead, eg: a copy of the wrapped transport
+// configured with a site's proxy
+func (r *Recorder) Via(next http.RoundTripper) http.RoundTripper {
+	return &recordVia{recorder: r, next: next}
+}
+
+// recordVia records the responses of next into the recorder
+type recordVia struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+func (v *recordVia) RoundTrip(req *http.Request) (*http.Response, error) {
+	return v.recorder.record(req, v.next)
+}
+
+// record sends req with next and saves the response
+func (r *Recorder) record(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	resp, err := next.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := r.save(req.URL.String(), resp, body); err != nil {
+		return nil, fmt.Errorf("failed to record %s: %w", req.URL, err)
+	}
+	return resp, nil
+}
+
+// save writes the body and updates the index, a URL recorded again replaces its earlier response
+func (r *Recorder) save(rawURL string, resp *http.Response, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	contentType := resp.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "image/") {
+		if r.images >= r.MaxImages {
+			return nil
+		}
+		r.images++
+	}
+
+	exchange := Exchange{URL: rawURL, Status: resp.StatusCode, ContentType: contentType}
+	index := -1
+	for i, e := range r.exchanges {
+		if e.URL == rawURL {
+			index = i
+			exchange.File = e.File
+		}
+	}
+	if exchange.File == "" {
+		exchange.File = r.fileName(rawURL, contentType)
+	}
+
+	if err := os.WriteFile(filepath.Join(r.Dir, exchange.File), body, 0644); err != nil {
+		return err
+	}
+	if index >= 0 {
+		r.exchanges[index] = exchange
+	} else {
+		r.exchanges = append(r.exchanges, exchange)
+	}
+
+	data, err := json.MarshalIndent(r.exchanges, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.Dir, IndexFile), append(data, '\n'), 0644)
+}
+
+var (
+	unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+	imageExt    = regexp.MustCompile(`(?i)\.(jpe?g|png|webp|gif|avif)$`)
+)
+
+// fileName names a body after its URL, eg: kunmanga.com_manga_ugly-complex.html
+func (r *Recorder) fileName(rawURL, contentType string) string {
+	name := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		name = u.Host + u.Path
+		if u.RawQuery != "" {
+			name += "_" + u.RawQuery
+		}
+	}
+	name = strings.Trim(unsafeChars.ReplaceAllString(name, "_"), "_.")
+
+	ext := ".html"
+	if media, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch {
+		case media == "text/html":
+		case strings.HasPrefix(media, "image/"):
+			ext = "." + strings.TrimPrefix(media, "image/")
+		case media == "application/json":
+			ext = ".json"
+		default:
+			ext = ".txt"
+		}
+	}
+	// an image keeps the extension of its URL, eg: 01.jpg
+	if strings.HasPrefix(contentType, "image/") && imageExt.MatchString(name) {
+		ext = filepath.Ext(name)
+	}
+	name = strings.TrimSuffix(name, ext)
+	if len(name) > 120 {
+		name = name[:120]
+	}
+
+	// keep the names unique
+	taken := func(n string) bool {
+		for _, e := range r.exchanges {
+			if e.File == n {
+				return true
+			}
+		}
+		return false
+	}
+	file := name + ext
+	for i := 2; taken(file); i++ {
+		file = fmt.Sprintf("%s-%d%s", name, i, ext)
+	}
+	return file
+}
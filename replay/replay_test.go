@@ -0,0 +1,78 @@
+package replay_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"scrape/replay"
+	"scrape/replay/replaytest"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/manga/series/":
+			w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+			w.Write([]byte(`<a href="chapter-1/">Chapter 1</a>`))
+		case "/missing/":
+			http.NotFound(w, r)
+		default:
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png " + r.URL.Path))
+		}
+	}))
+	defer site.Close()
+
+	dir := t.TempDir()
+	recorder, err := replay.NewRecorder(dir, http.DefaultTransport, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: recorder}
+	for _, path := range []string{"/manga/series/", "/missing/", "/images/1.png", "/images/2.png"} {
+		resp, err := client.Get(site.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	exchanges, err := replay.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the first sample image is kept
+	if len(exchanges) != 3 {
+		t.Fatalf("recorded %d responses, expected 3: %+v", len(exchanges), exchanges)
+	}
+	if exchanges[1].Status != http.StatusNotFound {
+		t.Errorf("recorded status %d for %s, expected 404", exchanges[1].Status, exchanges[1].URL)
+	}
+	site.Close()
+
+	replaytest.Serve(t, dir)
+	tests := []struct {
+		url    string
+		status int
+		body   string
+	}{
+		{site.URL + "/manga/series/", http.StatusOK, `<a href="chapter-1/">Chapter 1</a>`},
+		{site.URL + "/missing/", http.StatusNotFound, "404 page not found\n"},
+		{site.URL + "/images/1.png", http.StatusOK, "png /images/1.png"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || string(body) != tt.body {
+			t.Errorf("GET %s = %d %q, expected %d %q", tt.url, resp.StatusCode, body, tt.status, tt.body)
+		}
+		if resp.Request.URL.String() != tt.url {
+			t.Errorf("response request URL %s, expected the original %s", resp.Request.URL, tt.url)
+		}
+	}
+}
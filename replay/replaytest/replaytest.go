@@ -0,0 +1,115 @@
+// serve recorded replay fixtures to the scrapers from an httptest server
+package replaytest
+
+import (
+	"archive/zip"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"scrape/parser"
+	"scrape/replay"
+	"testing"
+)
+
+// Serve starts an httptest server answering with the responses recorded in the fixture directory dir, and routes
+// every request made through http.DefaultTransport to it for the rest of the test. Requests that were not recorded
+// fail the test and get a 404.
+func Serve(t testing.TB, dir string) *httptest.Server {
+	t.Helper()
+
+	// the tests can change directory once the server is running
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchanges, err := replay.Load(dir)
+	if err != nil {
+		t.Fatalf("failed to load the fixtures: %v", err)
+	}
+	recorded := make(map[string]replay.Exchange, len(exchanges))
+	for _, e := range exchanges {
+		recorded[e.URL] = e
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		e, ok := recorded[req.Header.Get(originalURLHeader)]
+		if !ok {
+			t.Errorf("replay: no recorded response for %s", req.Header.Get(originalURLHeader))
+			http.NotFound(w, req)
+			return
+		}
+
+		var body []byte
+		if e.File != "" {
+			if body, err = os.ReadFile(filepath.Join(dir, e.File)); err != nil {
+				t.Errorf("replay: %v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if e.ContentType != "" {
+			w.Header().Set("Content-Type", e.ContentType)
+		}
+		w.WriteHeader(e.Status)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+
+	previous := http.DefaultTransport
+	http.DefaultTransport = &transport{server: server}
+	t.Cleanup(func() { http.DefaultTransport = previous })
+
+	// the chapters are staged in the test's directory instead of the user cache
+	parser.ConfigureStaging(t.TempDir())
+	t.Cleanup(func() { parser.ConfigureStaging("") })
+
+	return server
+}
+
+// originalURLHeader carries the URL the scraper asked for to the replay server
+const originalURLHeader = "X-Replay-Url"
+
+// transport sends every request to the replay server, the responses keep the original request so relative links
+// are still resolved against the site
+type transport struct {
+	server *httptest.Server
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(t.server.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	out := req.Clone(req.Context())
+	out.Header.Set(originalURLHeader, req.URL.String())
+	out.URL.Scheme = target.Scheme
+	out.URL.Host = target.Host
+	out.Host = target.Host
+
+	resp, err := t.server.Client().Transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+	return resp, nil
+}
+
+// ArchiveNames returns the names of the files inside the zip (cbz) archive at path
+func ArchiveNames(t testing.TB, path string) []string {
+	t.Helper()
+
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", path, err)
+	}
+	defer r.Close()
+
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	return names
+}
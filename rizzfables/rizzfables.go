@@ -1,24 +1,22 @@
 package rizzfables
 
 import (
-	"bytes"
-	"context"
 	"fmt"
-	"image"
-	"image/jpeg"
 	"io"
 	"log"
-	"net/http"
-	"os"
 	"path/filepath"
+	"scrape/browser"
+	"scrape/debugbundle"
+	"scrape/logging"
 	"scrape/parser"
+	"scrape/webClient"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/chromedp/chromedp"
 	//"github.com/chromedp/cdproto/input"
 
-	"github.com/chai2010/webp"
 	"github.com/chromedp/cdproto/network"
 	"github.com/gocolly/colly"
 	_ "golang.org/x/image/webp"
@@ -26,22 +24,38 @@ import (
 	_ "image/png" // register PNG decoder
 )
 
-// Get the chatper URLs return string slice
-func chapterURLs(mangaUrl string) (map[string]string, error) {
-	// resulting chapter map
-	chapterMap := make(map[string]string)
+var logger = logging.Site("rizzfables")
 
-	c := colly.NewCollector()
+// SeriesChapters returns the chapters listed on the series page
+func SeriesChapters(mangaUrl string) ([]parser.Chapter, error) {
+	chapters, err := chapterList(mangaUrl)
+	if err != nil {
+		return nil, err
+	}
+	parser.SortChapters(chapters)
+	return chapters, nil
+}
+
+// ChapterImages returns all the image URLs for the chapter
+func ChapterImages(chapterUrl string) ([]string, error) {
+	return chapterImageUrls(chapterUrl)
+}
+
+// scrape the chapter list from the series page
+func chapterList(mangaUrl string) ([]parser.Chapter, error) {
+	var chapters []parser.Chapter
+
+	c := webClient.NewCollector()
 
 	// Debug hooks
 	c.OnRequest(func(r *colly.Request) {
-		log.Printf("[DEBUG] Visiting %s", r.URL.String())
+		logger.Debug("visiting", "url", r.URL.String())
 	})
 	c.OnResponse(func(r *colly.Response) {
-		log.Printf("[DEBUG] Response from %s - Status %d", r.Request.URL, r.StatusCode)
+		logger.Debug("response", "url", r.Request.URL, "status", r.StatusCode)
 	})
 	c.OnError(func(r *colly.Response, err error) {
-		log.Printf("[ERROR] Request to %s failed: %v (Status: %d)", r.Request.URL, err, r.StatusCode)
+		logger.Error("request failed", "url", r.Request.URL, "status", r.StatusCode, "error", err)
 	})
 
 	// Go to the list-body div, the scroll-sm unordered list and then to the list item
@@ -57,7 +71,7 @@ func chapterURLs(mangaUrl string) (map[string]string, error) {
 		// Parse the chapterNum as float64 to validate it
 		_, err := strconv.ParseFloat(chapterNum, 64)
 		if err != nil {
-			log.Printf("error converting chapter string to float: %v", err)
+			logger.Warn("chapter number is not a number", "series", mangaUrl, "chapter", chapterNum, "error", err)
 			return
 		}
 
@@ -73,7 +87,7 @@ func chapterURLs(mangaUrl string) (map[string]string, error) {
 		// Pad the whole part to 3 digits
 		wholeNum, err := strconv.Atoi(wholePart)
 		if err != nil {
-			log.Printf("error converting whole part to int: %v", err)
+			logger.Warn("chapter number is not a number", "series", mangaUrl, "chapter", chapterNum, "error", err)
 			return
 		}
 		paddedWhole := fmt.Sprintf("%03d", wholeNum)
@@ -86,8 +100,17 @@ func chapterURLs(mangaUrl string) (map[string]string, error) {
 			chName = fmt.Sprintf("ch%s.cbz", paddedWhole)
 		}
 
-		// Add to map
-		chapterMap[chName] = url
+		// the chapter date is only shown as text, eg: "September 3, 2024"
+		released, _ := parser.ParseReleaseDate(e.ChildText("span.chapterdate"), time.Now())
+
+		// Add to list
+		chapters = append(chapters, parser.Chapter{
+			Number:   chapterNum,
+			Title:    e.ChildText("span.chapternum"),
+			Filename: chName,
+			URL:      url,
+			Released: released,
+		})
 	})
 
 	err := c.Visit(mangaUrl)
@@ -95,31 +118,36 @@ func chapterURLs(mangaUrl string) (map[string]string, error) {
 		return nil, err
 	}
 
-	if len(chapterMap) == 0 {
+	if len(chapters) == 0 {
 		return nil, fmt.Errorf("no chapter URLs found at %s", mangaUrl)
 	}
 
-	return chapterMap, nil
+	return chapters, nil
 }
 
 // return all the image URLs for the chapter
 func chapterImageUrls(chapterUrl string) ([]string, error) {
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.UserAgent(`Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/115.0.0.0 Safari/537.36`),
-		chromedp.Flag("headless", true),
-		chromedp.Flag("disable-blink-features", "AutomationControlled"),
-		chromedp.Flag("disable-gpu", true),
-	)
-
-	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer cancelAlloc()
+	urls, _, err := chapterImages(chapterUrl, false)
+	return urls, err
+}
 
-	ctx, cancel := chromedp.NewContext(allocCtx)
+// return all the image URLs for the chapter and, when capture is set, the image bytes the browser loaded by URL
+func chapterImages(chapterUrl string, capture bool) ([]string, map[string][]byte, error) {
+	ctx, cancel, err := browser.NewTab(0)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer cancel()
 
-	// Enable network events
-	if err := chromedp.Run(ctx, network.Enable()); err != nil {
-		return nil, err
+	// Enable network events, recording the chapter image responses when capturing
+	var images *browser.ImageCapture
+	if capture {
+		isPage := func(url string) bool { return strings.Contains(url, "cdn.rizzfables.com/wp-content/uploads") }
+		if images, err = browser.CaptureImages(ctx, isPage); err != nil {
+			return nil, nil, err
+		}
+	} else if err := chromedp.Run(ctx, network.Enable()); err != nil {
+		return nil, nil, err
 	}
 
 	// Listen to network events, filter logs to only target site images to reduce noise
@@ -129,13 +157,13 @@ func chapterImageUrls(chapterUrl string) ([]string, error) {
 			url := ev.Request.URL
 			if strings.Contains(url, "rizzfables.com") &&
 				(strings.HasSuffix(url, ".webp") || strings.HasSuffix(url, ".jpg") || strings.HasSuffix(url, ".png")) {
-				log.Printf("REQUEST: %s %s\n", ev.Request.Method, url)
+				logger.Debug("image request", "method", ev.Request.Method, "url", url)
 			}
 		case *network.EventResponseReceived:
 			url := ev.Response.URL
 			if strings.Contains(url, "rizzfables.com") &&
 				(strings.HasSuffix(url, ".webp") || strings.HasSuffix(url, ".jpg") || strings.HasSuffix(url, ".png")) {
-				log.Printf("RESPONSE: %d %s\n", ev.Response.Status, url)
+				logger.Debug("image response", "status", ev.Response.Status, "url", url)
 			}
 		}
 	})
@@ -148,25 +176,29 @@ func chapterImageUrls(chapterUrl string) ([]string, error) {
             .filter(src => src.includes('cdn.rizzfables.com/wp-content/uploads'))
     `
 
-	err := chromedp.Run(ctx,
+	err = chromedp.Run(ctx,
 		chromedp.Navigate(chapterUrl),
 		chromedp.WaitVisible("#readerarea img", chromedp.ByQuery),
+		browser.ScrollFor("rizzfables", "#readerarea img"),
 		chromedp.Evaluate(jsGetImages, &imageURLs),
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return imageURLs, nil
+	if images == nil {
+		return imageURLs, nil, nil
+	}
+	return imageURLs, images.Images(ctx), nil
 }
 
 // DownloadAndConvertToJPG downloads an image from imageURL,
 // converts to JPG if needed, and saves it inside targetDir.
 // Returns error if any.
-func downloadAndConvertToJPG(imageURL, targetDir, chapterName string, imageIndex, totalImages int) error {
-	log.Printf("[DOWNLOAD] Chapter %s: Downloading image %d/%d: %s", chapterName, imageIndex, totalImages, imageURL)
+func downloadAndConvertToJPG(imageURL, chapterURL, targetDir, chapterName string, imageIndex, totalImages int) error {
+	logger.Info("downloading image", "chapter", chapterName, "page", imageIndex, "pages", totalImages, "url", imageURL)
 
-	resp, err := http.Get(imageURL)
+	resp, err := webClient.GetImage(imageURL, chapterURL)
 	if err != nil {
 		return fmt.Errorf("failed to download image: %w", err)
 	}
@@ -181,61 +213,17 @@ func downloadAndConvertToJPG(imageURL, targetDir, chapterName string, imageIndex
 		return fmt.Errorf("failed to read image data: %w", err)
 	}
 
-	format, err := parser.DetectImageFormat(imgBytes)
-	if err != nil {
-		return fmt.Errorf("failed to detect image format: %w", err)
-	}
-
-	log.Printf("[INFO] Detected image format: %s", format)
-
 	paddedIndex := fmt.Sprintf("%03d", imageIndex)
 	filename := paddedIndex + ".jpg" // Always save as .jpg to enforce conversion
 
 	outputFile := filepath.Join(targetDir, filename)
 
-	if format == "jpeg" {
-		log.Printf("[INFO] Image is already JPEG, saving directly as %s", outputFile)
-		err = os.WriteFile(outputFile, imgBytes, 0644)
-		if err != nil {
-			return fmt.Errorf("failed to save jpeg image: %w", err)
-		}
-		return nil
-	}
-
-	log.Printf("[INFO] Decoding image to convert to JPEG")
-
-	var img image.Image
-
-	switch format {
-	case "png", "gif":
-		img, _, err = image.Decode(bytes.NewReader(imgBytes))
-		if err != nil {
-			return fmt.Errorf("failed to decode %s image: %w", format, err)
-		}
-	case "webp":
-		img, err = webp.Decode(bytes.NewReader(imgBytes))
-		if err != nil {
-			return fmt.Errorf("failed to decode webp image: %w", err)
-		}
-	default:
-		return fmt.Errorf("unsupported image format: %s", format)
+	// the jpeg quality is the image_quality setting, 75 == small file size, 90 == large file size
+	if err := parser.WriteJPG(imgBytes, outputFile, parser.JPEGQuality()); err != nil {
+		return err
 	}
 
-	log.Printf("[INFO] Encoding image as JPEG and saving to %s", outputFile)
-
-	outFile, err := os.Create(outputFile)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
-	}
-	defer outFile.Close()
-
-	opts := jpeg.Options{Quality: 75} // the compression for the jpeg 75 == small file size, 90 == large file size
-	err = jpeg.Encode(outFile, img, &opts)
-	if err != nil {
-		return fmt.Errorf("failed to encode jpeg: %w", err)
-	}
-
-	log.Printf("[SUCCESS] Converted and saved image as JPEG: %s", outputFile)
+	logger.Debug("saved image as JPEG", "file", outputFile)
 
 	return nil
 }
@@ -254,7 +242,7 @@ func padFileName(inputFileName string) string {
 		// convert the fielname string to an integer
 		fileNamePart, err := strconv.Atoi(parts[0])
 		if err != nil {
-			log.Printf("padFileName() - error when converting filename integer %v", err)
+			logger.Warn("filename is not a page number", "file", inputFileName, "error", err)
 		}
 		// pad the resulting integer
 		padded := fmt.Sprintf("%03d", fileNamePart)
@@ -263,82 +251,74 @@ func padFileName(inputFileName string) string {
 		outputFileName = padded + "." + parts[1]
 
 	} else {
-		log.Fatal("padFileName() - inputFilename must contain an extension eg: filename.ext")
+		log.Fatalf("page filename %q must have an extension, eg: 1.jpg", inputFileName)
 	}
 
 	return outputFileName
 }
 
-// main func to downlownd the rizzfables mangas
-func DownloadMangaChapters(mangaUrl string) {
-	// Get list of chapters already downloaded (only *.cbz)
-	downloadedChapters, err := parser.FileList(".")
-	if err != nil {
-		log.Fatalf("error getting file list: %v", err)
-	}
-	downloadedChapters = parser.FilterCBZFiles(downloadedChapters)
-	log.Printf("Chapters already downloaded: %v, from URL: %s", downloadedChapters, mangaUrl)
-
-	// Get all chapters from website
-	chapterMap, err := chapterURLs(mangaUrl)
+// DownloadChapter downloads the chapter images, converts them to JPG and creates the chapter cbz file
+func DownloadChapter(chapter parser.Chapter) error {
+	chapterImageURLs, captured, err := chapterImages(chapter.URL, browser.CaptureEnabled())
 	if err != nil {
-		log.Fatalf("Get Chapter URLs failed: %v", err)
+		return fmt.Errorf("failed to get images for chapter %s: %w", chapter.Filename, err)
 	}
 
-	// Remove already downloaded chapters from the map
-	for _, chName := range downloadedChapters {
-		if _, ok := chapterMap[chName]; ok {
-			delete(chapterMap, chName)
-			log.Printf("%s already downloaded, removed from chapterMap", chName)
-		}
+	chapterNum := strings.SplitN(chapter.Filename, ".", 2)[0]
+	if len(chapterImageURLs) == 0 {
+		return fmt.Errorf("chapter %s: %w", chapterNum, debugbundle.NoMatch(chapter.URL, "#readerarea img", "images"))
 	}
+	logger.Info("starting chapter download", "chapter", chapter.Number, "url", chapter.URL, "pages", len(chapterImageURLs))
+	fmt.Printf("Starting download for chapter: %s, with %d images\n", chapterNum, len(chapterImageURLs))
 
-	// Sort chapter keys ascending (oldest to newest)
-	chapterNames, err := parser.SortKeys(chapterMap)
+	// Stage the chapter's images, its staging directory is kept across runs until the CBZ is created
+	stage, err := parser.StageChapter(chapter.URL)
 	if err != nil {
-		log.Fatal("error when sorting chapter name slice")
+		return fmt.Errorf("failed to stage chapter %s: %w", chapterNum, err)
 	}
 
-	for _, chapter := range chapterNames {
-		chapterImageURLs, err := chapterImageUrls(chapterMap[chapter])
-		if err != nil {
-			log.Printf("Failed to get images for chapter %s: %v", chapter, err)
-			continue
-		}
-
-		chapterNum := strings.SplitN(chapter, ".", 2)[0]
-		log.Printf("Starting download for chapter: %s, with %d images", chapterNum, len(chapterImageURLs))
-		fmt.Printf("Starting download for chapter: %s, with %d images\n", chapterNum, len(chapterImageURLs))
+	// Download all images in order with debug logging and correct naming, skipping the ones already staged
+	for i, url := range chapterImageURLs {
+		name := fmt.Sprintf("%03d.jpg", i+1)
 
-		// Create temp directory for this chapter's images
-		tmpDir, err := os.MkdirTemp("", "chapter-"+chapterNum)
-		if err != nil {
-			log.Printf("Failed to create temp dir for %s: %v", chapterNum, err)
-			continue
-		}
-		// defer remove after archive creation below, NOT here (so it’s not removed too early)
-
-		// Download all images in order with debug logging and correct naming
-		for i, url := range chapterImageURLs {
-			err := downloadAndConvertToJPG(url, tmpDir, chapterNum, i+1, len(chapterImageURLs))
+		// use the image the browser already loaded, only download the ones it did not
+		if data, ok := captured[url]; ok {
+			logger.Info("using captured image", "chapter", chapter.Number, "page", i+1, "pages", len(chapterImageURLs), "url", url)
+			err := stage.Page(name, url, func() error {
+				return parser.WriteJPG(data, filepath.Join(stage.Dir, name), parser.JPEGQuality())
+			})
 			if err != nil {
-				log.Printf("Error downloading image %d of chapter %s: %v", i+1, chapterNum, err)
+				logger.Error("failed to save captured image", "chapter", chapter.Number, "page", i+1, "error", err)
 			}
+			continue
 		}
 
-		// Create CBZ from temp dir images
-		targetFile := "./" + chapter
-		err = parser.CreateCbzFromDir(tmpDir, targetFile)
+		err := stage.Page(name, url, func() error {
+			return downloadAndConvertToJPG(url, chapter.URL, stage.Dir, chapterNum, i+1, len(chapterImageURLs))
+		})
 		if err != nil {
-			log.Printf("Failed to create CBZ for chapter %s: %v", chapterNum, err)
-		} else {
-			log.Printf("Created CBZ file: %s", targetFile)
-			fmt.Printf("Created CBZ file: %s\n", targetFile)
+			logger.Error("failed to download image", "chapter", chapter.Number, "page", i+1, "url", url, "error", err)
 		}
+	}
 
-		// Clean up temp directory after CBZ creation
-		if err := os.RemoveAll(tmpDir); err != nil {
-			log.Printf("Failed to remove temp dir %s: %v", tmpDir, err)
-		}
+	// Create CBZ from the staged images
+	targetFile := "./" + chapter.Filename
+	err = stage.Archive(targetFile, len(chapterImageURLs))
+	if err != nil {
+		return fmt.Errorf("failed to create CBZ for chapter %s: %w", chapterNum, err)
 	}
+	logger.Info("created CBZ", "chapter", chapter.Number, "file", targetFile)
+	fmt.Printf("Created CBZ file: %s\n", targetFile)
+
+	return nil
+}
+
+// SeriesInfo scrapes the series metadata from the MangaThemesia info block of the rizzfables series page
+func SeriesInfo(mangaUrl string) (*parser.SeriesInfo, error) {
+	doc, err := webClient.FetchDocument(mangaUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.ThemesiaSeriesInfo(doc, mangaUrl), nil
 }
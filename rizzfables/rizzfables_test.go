@@ -0,0 +1,49 @@
+package rizzfables
+
+import (
+	"path/filepath"
+	"scrape/parser"
+	"scrape/replay/replaytest"
+	"slices"
+	"testing"
+)
+
+func TestSeriesChapters(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	chapters, err := SeriesChapters("https://rizzfables.com/series/r2311170-revenge-of-the-iron-blooded-sword-hound/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const chapterURL = "https://rizzfables.com/chapter/r2311170-revenge-of-the-iron-blooded-sword-hound-chapter-"
+	want := []parser.Chapter{
+		{Number: "1", Title: "Chapter 1", Filename: "ch001.cbz", URL: chapterURL + "1/"},
+		{Number: "7.5", Title: "Chapter 7.5", Filename: "ch007.5.cbz", URL: chapterURL + "7-5/"},
+		{Number: "81", Title: "Chapter 81", Filename: "ch081.cbz", URL: chapterURL + "81/"},
+	}
+	if !slices.EqualFunc(chapters, want, func(a, b parser.Chapter) bool {
+		return a.Number == b.Number && a.Title == b.Title && a.Filename == b.Filename && a.URL == b.URL
+	}) {
+		t.Errorf("SeriesChapters() = %+v, expected %+v", chapters, want)
+	}
+	for _, ch := range chapters {
+		if ch.Released.IsZero() {
+			t.Errorf("chapter %s has no release date", ch.Number)
+		}
+	}
+}
+
+func TestPadFileName(t *testing.T) {
+	tests := map[string]string{
+		"1.jpg":    "001.jpg",
+		"12.webp":  "012.webp",
+		"123.png":  "123.png",
+		"1000.jpg": "1000.jpg",
+	}
+	for input, want := range tests {
+		if got := padFileName(input); got != want {
+			t.Errorf("padFileName(%q) = %q, expected %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package selection
+
+import (
+	"fmt"
+	"math"
+	"scrape/parser"
+	"strings"
+)
+
+// Range is a single entry of a --chapters list: an inclusive numeric range, a single chapter number, or the name of
+// a special chapter.
+type Range struct {
+	Low  float64
+	High float64
+	Name string
+}
+
+// ParseRanges parses a comma separated chapter list such as "10-20,25,30.5,extra,72-season-1-end". Open ended ranges
+// ("100-") run to the newest chapter.
+func ParseRanges(spec string) ([]Range, error) {
+	var ranges []Range
+
+	for _, token := range strings.Split(spec, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+
+		if value, ok := parser.ChapterNumberValue(token); ok {
+			if !finite(value) {
+				return nil, fmt.Errorf("invalid chapter %q: not a finite number", token)
+			}
+			ranges = append(ranges, Range{Low: value, High: value})
+			continue
+		}
+
+		// a hyphen only separates a range when both ends are chapter numbers, specials with several hyphens such as
+		// 72-season-1-end are names
+		low, high, isRange := strings.Cut(token, "-")
+		if !isRange {
+			ranges = append(ranges, Range{Name: token})
+			continue
+		}
+		lowValue, lowOK := parser.ChapterNumberValue(strings.TrimSpace(low))
+		highValue, highOK := math.Inf(1), true
+		if high = strings.TrimSpace(high); high != "" {
+			highValue, highOK = parser.ChapterNumberValue(high)
+			if highOK && !finite(highValue) {
+				return nil, fmt.Errorf("invalid chapter range %q: %q is not a finite number", token, high)
+			}
+		}
+		if lowOK && !finite(lowValue) {
+			return nil, fmt.Errorf("invalid chapter range %q: %q is not a finite number", token, strings.TrimSpace(low))
+		}
+		if !lowOK || !highOK {
+			if (lowOK || highOK) && strings.Count(token, "-") == 1 {
+				return nil, fmt.Errorf("invalid chapter range %q: both ends must be chapter numbers", token)
+			}
+			ranges = append(ranges, Range{Name: token})
+			continue
+		}
+
+		if lowValue > highValue {
+			return nil, fmt.Errorf("invalid chapter range %q: start is after end", token)
+		}
+		ranges = append(ranges, Range{Low: lowValue, High: highValue})
+	}
+
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("empty chapter list %q", spec)
+	}
+	return ranges, nil
+}
+
+// finite reports whether a chapter number is usable as a range bound, ParseFloat also accepts "inf" and "nan"
+func finite(value float64) bool {
+	return !math.IsInf(value, 0) && !math.IsNaN(value)
+}
+
+// Contains reports whether ch is selected by the range.
+func (r Range) Contains(ch parser.Chapter) bool {
+	if r.Name != "" {
+		return matchesName(ch, r.Name)
+	}
+
+	value, ok := parser.ChapterNumberValue(ch.Number)
+	return ok && value >= r.Low && value <= r.High
+}
@@ -0,0 +1,154 @@
+// chapter selection shared by every site command, applied to the resolved chapter list before anything is downloaded
+package selection
+
+import (
+	"fmt"
+	"scrape/parser"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Selection narrows a resolved chapter list. The zero value selects every chapter.
+type Selection struct {
+	// Chapters is a comma separated list of chapter numbers and ranges, eg: "10-20,25,30.5"
+	Chapters string
+	// From and To bound the selection (inclusive), either a chapter number or the name of a special chapter
+	From string
+	To   string
+	// Since drops chapters released before this time
+	Since time.Time
+	// Latest keeps only the newest N chapters of the selection
+	Latest int
+}
+
+// IsZero reports whether the selection keeps every chapter.
+func (s Selection) IsZero() bool {
+	return s.Chapters == "" && s.From == "" && s.To == "" && s.Since.IsZero() && s.Latest == 0
+}
+
+// Apply returns the chapters matched by the selection, in chapter order. The filters are applied in the order
+// --chapters, --from/--to, --since and finally --latest.
+func (s Selection) Apply(chapters []parser.Chapter) ([]parser.Chapter, error) {
+	selected := make([]parser.Chapter, len(chapters))
+	copy(selected, chapters)
+	parser.SortChapters(selected)
+
+	if s.Chapters != "" {
+		ranges, err := ParseRanges(s.Chapters)
+		if err != nil {
+			return nil, err
+		}
+		// a misspelled special chapter name is an error rather than an empty selection
+		for _, r := range ranges {
+			if r.Name != "" && !slices.ContainsFunc(selected, r.Contains) {
+				return nil, fmt.Errorf("no chapter matches %q", r.Name)
+			}
+		}
+		selected = filter(selected, func(ch parser.Chapter) bool {
+			for _, r := range ranges {
+				if r.Contains(ch) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+
+	if s.From != "" || s.To != "" {
+		from, to := 0, len(selected)-1
+		if s.From != "" {
+			i, err := position(selected, s.From, true)
+			if err != nil {
+				return nil, fmt.Errorf("--from: %w", err)
+			}
+			from = i
+		}
+		if s.To != "" {
+			i, err := position(selected, s.To, false)
+			if err != nil {
+				return nil, fmt.Errorf("--to: %w", err)
+			}
+			to = i
+		}
+		if from > to {
+			selected = nil
+		} else {
+			selected = selected[from : to+1]
+		}
+	}
+
+	if !s.Since.IsZero() {
+		dated := false
+		for _, ch := range chapters {
+			if !ch.Released.IsZero() {
+				dated = true
+				break
+			}
+		}
+		if !dated {
+			return nil, fmt.Errorf("--since: this source does not list chapter release dates")
+		}
+
+		// chapters without a release date cannot be placed, so they are dropped
+		selected = filter(selected, func(ch parser.Chapter) bool {
+			return !ch.Released.IsZero() && !ch.Released.Before(s.Since)
+		})
+	}
+
+	if s.Latest > 0 && len(selected) > s.Latest {
+		selected = selected[len(selected)-s.Latest:]
+	}
+
+	return selected, nil
+}
+
+// filter returns the chapters for which keep returns true
+func filter(chapters []parser.Chapter, keep func(parser.Chapter) bool) []parser.Chapter {
+	var kept []parser.Chapter
+	for _, ch := range chapters {
+		if keep(ch) {
+			kept = append(kept, ch)
+		}
+	}
+	return kept
+}
+
+// position finds the index of the chapter a --from / --to bound refers to in the sorted chapter list. Numeric bounds
+// land on the first chapter >= the bound (from) or the last chapter <= the bound (to), so the bound does not have to
+// exist. Any other value must name a chapter by its number, filename or title.
+func position(chapters []parser.Chapter, bound string, from bool) (int, error) {
+	if value, ok := parser.ChapterNumberValue(bound); ok {
+		if from {
+			for i, ch := range chapters {
+				if n, ok := parser.ChapterNumberValue(ch.Number); ok && n >= value {
+					return i, nil
+				}
+			}
+			return len(chapters), nil
+		}
+
+		for i := len(chapters) - 1; i >= 0; i-- {
+			if n, ok := parser.ChapterNumberValue(chapters[i].Number); ok && n <= value {
+				return i, nil
+			}
+		}
+		return -1, nil
+	}
+
+	for i, ch := range chapters {
+		if matchesName(ch, bound) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no chapter named %q", bound)
+}
+
+// matchesName reports whether a special (non numeric) chapter reference names ch
+func matchesName(ch parser.Chapter, name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.EqualFold(ch.Number, name) ||
+		strings.EqualFold(strings.TrimSuffix(ch.Filename, ".cbz"), name) ||
+		strings.Contains(strings.ToLower(ch.Filename), name) ||
+		strings.Contains(strings.ToLower(ch.Title), name)
+}
@@ -0,0 +1,133 @@
+package selection
+
+import (
+	"reflect"
+	"scrape/parser"
+	"testing"
+	"time"
+)
+
+func testChapters() []parser.Chapter {
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	return []parser.Chapter{
+		{Number: "10", Filename: "ch010.cbz", Released: day(1)},
+		{Number: "11", Filename: "ch011.cbz", Released: day(2)},
+		{Number: "11.5", Filename: "ch011.5.cbz", Released: day(3)},
+		{Number: "12", Filename: "ch012.cbz", Released: day(4)},
+		{Number: "72", Filename: "ch072-season-1-end.cbz", Title: "Ch. 72 Season 1 End", Released: day(5)},
+		{Number: "", Filename: "extra.cbz", Title: "Extra", Released: day(6)},
+	}
+}
+
+func filenames(chapters []parser.Chapter) []string {
+	var names []string
+	for _, ch := range chapters {
+		names = append(names, ch.Filename)
+	}
+	return names
+}
+
+func TestSelectionApply(t *testing.T) {
+	tests := []struct {
+		name string
+		sel  Selection
+		want []string
+	}{
+		{
+			name: "zero value keeps everything",
+			sel:  Selection{},
+			want: []string{"ch010.cbz", "ch011.cbz", "ch011.5.cbz", "ch012.cbz", "ch072-season-1-end.cbz", "extra.cbz"},
+		},
+		{
+			name: "ranges and single chapters",
+			sel:  Selection{Chapters: "10-11,12"},
+			want: []string{"ch010.cbz", "ch011.cbz", "ch012.cbz"},
+		},
+		{
+			name: "decimal chapter and named special",
+			sel:  Selection{Chapters: "11.5,extra"},
+			want: []string{"ch011.5.cbz", "extra.cbz"},
+		},
+		{
+			name: "open ended range",
+			sel:  Selection{Chapters: "12-"},
+			want: []string{"ch012.cbz", "ch072-season-1-end.cbz"},
+		},
+		{
+			name: "from and to with decimals",
+			sel:  Selection{From: "11.2", To: "12"},
+			want: []string{"ch011.5.cbz", "ch012.cbz"},
+		},
+		{
+			name: "from a special chapter",
+			sel:  Selection{From: "season-1-end"},
+			want: []string{"ch072-season-1-end.cbz", "extra.cbz"},
+		},
+		{
+			name: "latest",
+			sel:  Selection{Latest: 2},
+			want: []string{"ch072-season-1-end.cbz", "extra.cbz"},
+		},
+		{
+			name: "since",
+			sel:  Selection{Since: time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC)},
+			want: []string{"ch012.cbz", "ch072-season-1-end.cbz", "extra.cbz"},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.sel.Apply(testChapters())
+		if err != nil {
+			t.Errorf("%s: Apply() error: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(filenames(got), tt.want) {
+			t.Errorf("%s: Apply() = %v; want %v", tt.name, filenames(got), tt.want)
+		}
+	}
+}
+
+func TestParseRanges(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []Range
+	}{
+		{spec: "10-20", want: []Range{{Low: 10, High: 20}}},
+		{spec: "10.5 - 12", want: []Range{{Low: 10.5, High: 12}}},
+		{spec: "72-season-1-end", want: []Range{{Name: "72-season-1-end"}}},
+		{spec: "1-5,72-season-1-end,extra", want: []Range{{Low: 1, High: 5}, {Name: "72-season-1-end"}, {Name: "extra"}}},
+		{spec: "season-1-end", want: []Range{{Name: "season-1-end"}}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRanges(tt.spec)
+		if err != nil {
+			t.Errorf("ParseRanges(%q) error: %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRanges(%q) = %+v; want %+v", tt.spec, got, tt.want)
+		}
+	}
+
+	got, err := Selection{Chapters: "72-season-1-end"}.Apply(testChapters())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ch072-season-1-end.cbz"}; !reflect.DeepEqual(filenames(got), want) {
+		t.Errorf("--chapters 72-season-1-end selected %v; want %v", filenames(got), want)
+	}
+
+	if _, err := (Selection{Chapters: "1-5,season-2-end"}).Apply(testChapters()); err == nil {
+		t.Error("--chapters with a name matching no chapter expected an error")
+	}
+}
+
+func TestParseRangesErrors(t *testing.T) {
+	for _, spec := range []string{"", " , ", "20-10", "12.5-3", "a-5", "5-b", "10-2O", "inf-5", "5-inf", "nan", "inf"} {
+		if _, err := ParseRanges(spec); err == nil {
+			t.Errorf("ParseRanges(%q) expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"scrape/debugbundle"
+	"scrape/parser"
+	"slices"
+	"strings"
+)
+
+// The checks run against the canary series of a source
+const (
+	CheckChapters = "chapters"
+	CheckNumbers  = "numbers"
+	CheckPages    = "pages"
+)
+
+// SelectorCheck is the outcome of one check of a source
+type SelectorCheck struct {
+	Name     string `json:"name"`
+	Selector string `json:"selector,omitempty"`
+	Passed   bool   `json:"passed"`
+	Detail   string `json:"detail"`
+}
+
+// CheckResult is the health of a source: its chapter list, chapter numbers and the pages of its newest chapter
+type CheckResult struct {
+	Source    string `json:"source"`
+	SeriesURL string `json:"series_url,omitempty"`
+	// Chapter is the newest chapter, whose pages were resolved
+	Chapter string `json:"chapter,omitempty"`
+	// Skipped is why the source was not checked, eg: no browser available
+	Skipped string          `json:"skipped,omitempty"`
+	Passed  bool            `json:"passed"`
+	Checks  []SelectorCheck `json:"checks"`
+}
+
+// Check fetches the canary series of the source (seriesURL when given) and checks that the chapter list is not empty,
+// that every chapter number parses and that the newest chapter resolves to at least minImages pages. A check that
+// fails stops the ones after it, and writes a debug bundle when --debug-dir is set.
+func (s *Source) Check(seriesURL string, minImages int) *CheckResult {
+	if seriesURL == "" {
+		seriesURL = s.Canary
+	}
+	if seriesURL == "" {
+		seriesURL = s.SeriesURL
+	}
+
+	result := &CheckResult{Source: s.Name, SeriesURL: seriesURL, Checks: []SelectorCheck{}}
+	if seriesURL == "" {
+		result.Skipped = "no canary series configured"
+		return result
+	}
+	if err := s.CheckBrowser(); err != nil {
+		result.Skipped = err.Error()
+		return result
+	}
+
+	add := func(name, selector string, passed bool, detail string, args ...any) bool {
+		result.Checks = append(result.Checks, SelectorCheck{
+			Name:     name,
+			Selector: selector,
+			Passed:   passed,
+			Detail:   fmt.Sprintf(detail, args...),
+		})
+		return passed
+	}
+	defer func() {
+		result.Passed = result.Skipped == "" && !slices.ContainsFunc(result.Checks, func(c SelectorCheck) bool { return !c.Passed })
+	}()
+
+	debugbundle.Reset()
+	var chapters []parser.Chapter
+	err := s.withLogin(func() (err error) {
+		chapters, err = s.Chapters(seriesURL)
+		return err
+	})
+	if err == nil && len(chapters) == 0 {
+		err = debugbundle.NoMatch(seriesURL, s.ChapterSelector, "chapters")
+	}
+	if err != nil {
+		add(CheckChapters, s.ChapterSelector, false, "%v", err)
+		writeBundle(s.Name, parser.Chapter{Filename: "series", URL: seriesURL}, err)
+		return result
+	}
+	add(CheckChapters, s.ChapterSelector, true, "%d chapters", len(chapters))
+
+	var invalid []string
+	for _, ch := range chapters {
+		if _, ok := parser.ChapterNumberValue(ch.Number); !ok {
+			invalid = append(invalid, fmt.Sprintf("%q (%s)", ch.Number, ch.URL))
+		}
+	}
+	if len(invalid) > 0 {
+		add(CheckNumbers, s.ChapterSelector, false, "%d of %d chapter numbers do not parse: %s", len(invalid),
+			len(chapters), strings.Join(invalid, ", "))
+		return result
+	}
+	add(CheckNumbers, s.ChapterSelector, true, "%d chapter numbers parse", len(chapters))
+
+	sorted := slices.Clone(chapters)
+	parser.SortChapters(sorted)
+	newest := sorted[len(sorted)-1]
+	result.Chapter = newest.Number
+
+	debugbundle.Reset()
+	var pages []string
+	err = s.withLogin(func() (err error) {
+		pages, err = s.Pages(newest.URL)
+		return err
+	})
+	if err != nil {
+		// the site reports the selector that matched nothing
+		selector := s.PageSelector
+		var noMatch *debugbundle.NoMatchError
+		if errors.As(err, &noMatch) && noMatch.Selector != "" {
+			selector = noMatch.Selector
+		}
+		add(CheckPages, selector, false, "chapter %s: %v", newest.Number, err)
+		writeBundle(s.Name, newest, err)
+		return result
+	}
+	if !add(CheckPages, s.PageSelector, len(pages) >= minImages, "chapter %s: %d images, expected at least %d",
+		newest.Number, len(pages), minImages) {
+		writeBundle(s.Name, newest, fmt.Errorf("%d images, expected at least %d", len(pages), minImages))
+	}
+
+	return result
+}
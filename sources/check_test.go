@@ -0,0 +1,75 @@
+package sources
+
+import (
+	"path/filepath"
+	"scrape/parser"
+	"scrape/replay/replaytest"
+	"testing"
+)
+
+// TestCheckFixtures runs check-sites against the recorded fixtures of the sites that do not need a browser
+func TestCheckFixtures(t *testing.T) {
+	for _, src := range All() {
+		if src.Browser {
+			continue
+		}
+		t.Run(src.Name, func(t *testing.T) {
+			replaytest.Serve(t, filepath.Join("..", src.Name, "testdata", "replay"))
+
+			result := src.Check("", 1)
+			if !result.Passed {
+				t.Errorf("Check() = %+v, expected every check to pass", result)
+			}
+			if len(result.Checks) != 3 {
+				t.Errorf("Check() ran %d checks, expected 3: %+v", len(result.Checks), result.Checks)
+			}
+		})
+	}
+}
+
+func TestCheckFailures(t *testing.T) {
+	src := &Source{
+		Name:            "redesigned",
+		Canary:          "https://redesigned.test/series",
+		ChapterSelector: "li.chapter a",
+		PageSelector:    "div.reader img",
+		Chapters: func(seriesURL string) ([]parser.Chapter, error) {
+			return []parser.Chapter{
+				{Number: "1", URL: seriesURL + "/chapter-1"},
+				{Number: "2", URL: seriesURL + "/chapter-2"},
+			}, nil
+		},
+		Pages: func(chapterURL string) ([]string, error) {
+			return []string{chapterURL + "/1.jpg"}, nil
+		},
+	}
+
+	result := src.Check("", 2)
+	if result.Passed || result.Chapter != "2" || len(result.Checks) != 3 {
+		t.Fatalf("Check() = %+v, expected the pages check of chapter 2 to fail", result)
+	}
+	if c := result.Checks[2]; c.Name != CheckPages || c.Passed || c.Selector != "div.reader img" {
+		t.Errorf("pages check = %+v, expected a failure on div.reader img", c)
+	}
+
+	// a chapter list that matches nothing stops the other checks
+	src.Chapters = func(seriesURL string) ([]parser.Chapter, error) { return nil, nil }
+	result = src.Check("", 1)
+	if result.Passed || len(result.Checks) != 1 || result.Checks[0].Selector != "li.chapter a" {
+		t.Errorf("Check() = %+v, expected only the chapters check to fail", result)
+	}
+
+	// so does a chapter number that does not parse
+	src.Chapters = func(seriesURL string) ([]parser.Chapter, error) {
+		return []parser.Chapter{{Number: "Prologue", URL: seriesURL + "/prologue"}}, nil
+	}
+	result = src.Check("", 1)
+	if result.Passed || len(result.Checks) != 2 || result.Checks[1].Name != CheckNumbers {
+		t.Errorf("Check() = %+v, expected the numbers check to fail", result)
+	}
+
+	src.Canary = ""
+	if result = src.Check("", 1); result.Skipped == "" || result.Passed {
+		t.Errorf("Check() = %+v, expected a source without a canary to be skipped", result)
+	}
+}
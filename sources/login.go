@@ -0,0 +1,83 @@
+package sources
+
+import (
+	"fmt"
+	"log/slog"
+	"scrape/auth"
+	"scrape/config"
+	"scrape/webClient"
+	"strings"
+	"sync"
+)
+
+// loginState is the login of a source during the run
+type loginState struct {
+	// err is the login failure, the login is not tried again
+	err error
+}
+
+var (
+	loginMu sync.Mutex
+	logins  = make(map[*Source]*loginState)
+)
+
+// credentials returns the account configured for the source, false when there is none or the site has no login
+func (s *Source) credentials() (auth.Credentials, bool) {
+	if s.Login == nil {
+		return auth.Credentials{}, false
+	}
+	username, password := config.Site(s.Name).Login.Credentials()
+	return auth.Credentials{Username: username, Password: password}, username != ""
+}
+
+// login signs in to the source on its first request of the run, and again when a response showed its session has
+// expired. A failed login is returned again without retrying it, so a wrong password is not sent for every chapter.
+func (s *Source) login() error {
+	creds, ok := s.credentials()
+	if !ok {
+		return nil
+	}
+
+	loginMu.Lock()
+	defer loginMu.Unlock()
+
+	state := logins[s]
+	if state != nil && (state.err != nil || !webClient.SessionExpired(s.Name)) {
+		return state.err
+	}
+
+	if creds.Password == "" {
+		err := fmt.Errorf("%s: no password for %s, set login.password or login.password_env in the config file, or SCRAPE_%s_PASSWORD",
+			s.Name, creds.Username, strings.ToUpper(s.Name))
+		logins[s] = &loginState{err: err}
+		return err
+	}
+
+	slog.Info("logging in", "site", s.Name, "username", creds.Username, "url", s.Login.URL())
+	if err := s.Login.Login(creds); err != nil {
+		err = fmt.Errorf("%s: login as %s failed: %w", s.Name, creds.Username, err)
+		logins[s] = &loginState{err: err}
+		return err
+	}
+	webClient.ResetSession(s.Name)
+	logins[s] = &loginState{}
+	return nil
+}
+
+// withLogin runs fetch logged in to the source. When fetch fails because the session expired (a redirect to the login
+// page, a 401 or a 403), the source logs in again and fetch is retried once.
+func (s *Source) withLogin(fetch func() error) error {
+	if err := s.login(); err != nil {
+		return err
+	}
+	err := fetch()
+	if _, ok := s.credentials(); err == nil || !ok || !webClient.SessionExpired(s.Name) {
+		return err
+	}
+
+	slog.Warn("session expired, logging in again", "site", s.Name, "error", err)
+	if err := s.login(); err != nil {
+		return err
+	}
+	return fetch()
+}
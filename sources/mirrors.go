@@ -0,0 +1,178 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"scrape/debugbundle"
+	"scrape/library"
+	"scrape/parser"
+	"scrape/webClient"
+)
+
+// Mirror is a series on one source site
+type Mirror struct {
+	Source *Source
+	URL    string
+}
+
+// MirrorSet is a series followed on an ordered set of source sites, the primary first and then its fallbacks. A
+// chapter is downloaded from the first mirror that lists it, later mirrors are tried when that download fails.
+type MirrorSet struct {
+	Mirrors []Mirror
+
+	// the chapters of each mirror by chapter key, filled in by Resolve
+	chapters []map[string]parser.Chapter
+}
+
+// MirrorsFromState builds the mirror set of a series from its library state: the primary source and then the
+// fallbacks in order.
+func MirrorsFromState(state *library.State) (*MirrorSet, error) {
+	set := &MirrorSet{}
+	refs := append([]library.SourceRef{{Source: state.Source, URL: state.URL}}, state.Fallbacks...)
+
+	for _, ref := range refs {
+		src, err := Get(ref.Source)
+		if err != nil {
+			return nil, err
+		}
+		set.Mirrors = append(set.Mirrors, Mirror{Source: src, URL: ref.URL})
+	}
+	return set, nil
+}
+
+// Resolve lists the chapters of every mirror and merges them: every chapter of the primary, plus the chapters only a
+// fallback lists (from the first fallback that has them). Chapters are matched by chapter number, the Source of each
+// merged chapter is the mirror it will be downloaded from. A fallback that fails to list its chapters is logged and
+// skipped, the primary failing is an error.
+func (m *MirrorSet) Resolve(dir string) ([]parser.Chapter, error) {
+	m.chapters = make([]map[string]parser.Chapter, len(m.Mirrors))
+
+	var merged []parser.Chapter
+	seen := make(map[string]bool)
+
+	for i, mirror := range m.Mirrors {
+		chapters, err := mirror.Source.Resolve(mirror.URL, dir)
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			slog.Warn("skipping fallback source", "site", mirror.Source.Name, "error", err)
+			fmt.Printf("Fallback %s unavailable: %v\n", mirror.Source.Name, err)
+			continue
+		}
+
+		m.chapters[i] = make(map[string]parser.Chapter, len(chapters))
+		for _, ch := range chapters {
+			key := library.ChapterKey(ch)
+			m.chapters[i][key] = ch
+			if !seen[key] {
+				seen[key] = true
+				merged = append(merged, ch)
+			}
+		}
+	}
+
+	parser.SortChapters(merged)
+	return merged, nil
+}
+
+// DiscoverPages resolves the page image URLs of every chapter that is not yet downloaded, from the mirror each
+// chapter will be downloaded from.
+func (m *MirrorSet) DiscoverPages(chapters []parser.Chapter) {
+	for _, mirror := range m.Mirrors {
+		var indexes []int
+		var own []parser.Chapter
+		for i, ch := range chapters {
+			if ch.Source == mirror.Source.Name {
+				indexes = append(indexes, i)
+				own = append(own, ch)
+			}
+		}
+
+		mirror.Source.DiscoverPages(own)
+		for j, i := range indexes {
+			chapters[i] = own[j]
+		}
+	}
+}
+
+// DownloadChapters downloads every chapter that is not already downloaded, in the given order. Each chapter is tried
+// on every mirror that lists it, in mirror order, until one succeeds. A chapter that fails everywhere is logged and
+// skipped so the remaining chapters still download, the returned error reports how many failed.
+func (m *MirrorSet) DownloadChapters(chapters []parser.Chapter) error {
+	if webClient.Offline() {
+		return errors.New("chapters are not downloaded with --offline, use --list or --dry-run to re-run parsing on cached pages")
+	}
+
+	var pending []parser.Chapter
+	for _, ch := range chapters {
+		if ch.Status == parser.StatusDownloaded {
+			slog.Info("skipping chapter, already exists", "site", ch.Source, "chapter", ch.Number, "file", ch.Filename)
+			continue
+		}
+		pending = append(pending, ch)
+	}
+
+	fmt.Println("Downloading", len(pending), "chapters")
+
+	failed := 0
+	for _, ch := range pending {
+		var errs []error
+		downloaded := false
+
+		for _, candidate := range m.candidates(ch) {
+			src := candidate.src
+			if err := src.CheckBrowser(); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			if len(errs) > 0 {
+				fmt.Printf("Trying chapter %s from fallback %s\n", ch.Number, src.Name)
+			}
+			debugbundle.Reset()
+			err := src.download(candidate.chapter)
+			if err == nil {
+				downloaded = true
+				break
+			}
+			slog.Error("error downloading chapter", "site", src.Name, "chapter", candidate.chapter.Number, "url", candidate.chapter.URL, "error", err)
+			writeBundle(src.Name, candidate.chapter, err)
+			errs = append(errs, fmt.Errorf("%s: %w", src.Name, err))
+		}
+
+		if !downloaded {
+			fmt.Printf("%s\nError downloading chapter: %s\n", errors.Join(errs...), ch.Filename)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d chapters failed to download", failed, len(pending))
+	}
+	return nil
+}
+
+// candidate is a chapter as listed on one mirror
+type candidate struct {
+	src     *Source
+	chapter parser.Chapter
+}
+
+// candidates lists the mirrors ch can be downloaded from, in mirror order. Without resolved mirror chapter lists
+// (a single source) the chapter itself is the only candidate.
+func (m *MirrorSet) candidates(ch parser.Chapter) []candidate {
+	if m.chapters == nil {
+		return []candidate{{src: m.Mirrors[0].Source, chapter: ch}}
+	}
+
+	key := library.ChapterKey(ch)
+	var found []candidate
+	for i, mirror := range m.Mirrors {
+		if listed, ok := m.chapters[i][key]; ok {
+			found = append(found, candidate{src: mirror.Source, chapter: listed})
+		}
+	}
+	return found
+}
@@ -0,0 +1,86 @@
+package sources
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"reflect"
+	"scrape/parser"
+	"strings"
+	"testing"
+)
+
+// fakeSource lists the given chapter numbers and writes a one page archive for each download, failing for the
+// chapter numbers in fail
+func fakeSource(name string, numbers []string, fail ...string) *Source {
+	return &Source{
+		Name: name,
+		Chapters: func(seriesURL string) ([]parser.Chapter, error) {
+			var chapters []parser.Chapter
+			for _, n := range numbers {
+				chapters = append(chapters, parser.Chapter{
+					Number:   n,
+					Filename: parser.ChapterFilename(n),
+					URL:      seriesURL + "/chapter-" + n,
+				})
+			}
+			return chapters, nil
+		},
+		DownloadChapter: func(ch parser.Chapter) error {
+			for _, n := range fail {
+				if ch.Number == n {
+					return errors.New("pages failed")
+				}
+			}
+
+			f, err := os.Create(ch.Filename)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			zw := zip.NewWriter(f)
+			if _, err := zw.Create(parser.PageFilename(1, ".jpg")); err != nil {
+				return err
+			}
+			return zw.Close()
+		},
+	}
+}
+
+func TestMirrorSetFallbacks(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	set := &MirrorSet{Mirrors: []Mirror{
+		{Source: fakeSource("primary", []string{"1", "3", "4"}, "3"), URL: "https://primary.test/series"},
+		{Source: fakeSource("fallback", []string{"1", "2", "3"}), URL: "https://fallback.test/series"},
+	}}
+
+	chapters, err := set.Resolve(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, ch := range chapters {
+		got = append(got, ch.Number+"@"+ch.Source)
+	}
+	if want := []string{"1@primary", "2@fallback", "3@primary", "4@primary"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Resolve() = %v; want %v", got, want)
+	}
+
+	if err := set.DownloadChapters(chapters); err != nil {
+		t.Fatal(err)
+	}
+
+	// chapter 2 is missing on the primary and chapter 3 fails there, both come from the fallback
+	want := map[string]string{"1": "primary", "2": "fallback", "3": "fallback", "4": "primary"}
+	for number, source := range want {
+		info, err := parser.ReadComicInfo(parser.ChapterFilename(number))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info == nil || !strings.HasPrefix(info.Notes, "Source: "+source+",") {
+			t.Errorf("chapter %s provenance = %+v; want source %s", number, info, source)
+		}
+	}
+}
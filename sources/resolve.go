@@ -0,0 +1,127 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"scrape/config"
+	"scrape/debugbundle"
+	"scrape/library"
+	"scrape/parser"
+	"time"
+)
+
+// Resolve lists the chapters of a series and marks each with its local status in dir. A chapter is downloaded if an
+// archive with its filename or its chapter number exists in dir.
+func (s *Source) Resolve(seriesURL, dir string) ([]parser.Chapter, error) {
+	if seriesURL == "" {
+		seriesURL = s.SeriesURL
+	}
+
+	var chapters []parser.Chapter
+	err := s.withLogin(func() (err error) {
+		chapters, err = s.Chapters(seriesURL)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to list chapters for %s: %w", s.Name, seriesURL, err)
+	}
+
+	for i := range chapters {
+		chapters[i].Source = s.Name
+		chapters[i].Status = parser.ChapterStatus(dir, chapters[i].Filename)
+	}
+
+	// archives under another name (older naming scheme or another source) still count as downloaded
+	local, err := library.LocalChapters(dir)
+	if err != nil {
+		return nil, err
+	}
+	library.MarkDownloaded(chapters, local)
+
+	return chapters, nil
+}
+
+// DiscoverPages resolves the page image URLs of every chapter that is not yet downloaded. Failures are recorded on
+// the chapter instead of stopping the run.
+func (s *Source) DiscoverPages(chapters []parser.Chapter) {
+	for i := range chapters {
+		ch := &chapters[i]
+		if ch.Status == parser.StatusDownloaded {
+			continue
+		}
+
+		debugbundle.Reset()
+		var pages []string
+		err := s.withLogin(func() (err error) {
+			pages, err = s.Pages(ch.URL)
+			if err == nil && len(pages) == 0 {
+				err = errors.New("no images found")
+			}
+			return err
+		})
+		if err != nil {
+			slog.Warn("failed to resolve pages", "site", s.Name, "chapter", ch.Number, "url", ch.URL, "error", err)
+			ch.Error = err.Error()
+			writeBundle(s.Name, *ch, err)
+			continue
+		}
+		ch.Pages = pages
+	}
+}
+
+// DownloadChapters downloads every chapter that is not already downloaded, in the given order. A failed chapter is
+// logged and skipped so the remaining chapters still download, the returned error reports how many failed.
+func (s *Source) DownloadChapters(chapters []parser.Chapter) error {
+	return (&MirrorSet{Mirrors: []Mirror{{Source: s}}}).DownloadChapters(chapters)
+}
+
+// download downloads a single chapter into the current directory with the site's image settings, converts its pages
+// to the configured image format and records where it came from in the archive metadata
+func (s *Source) download(ch parser.Chapter) error {
+	settings := config.Site(s.Name)
+	parser.SetJPEGQuality(settings.ImageQuality)
+
+	if err := s.withLogin(func() error { return s.DownloadChapter(ch) }); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(ch.Filename); err != nil {
+		slog.Warn("chapter downloaded but its archive was not found, provenance not recorded", "site", s.Name, "chapter", ch.Number, "file", ch.Filename)
+		return nil
+	}
+
+	if settings.ImageFormat != "" {
+		if err := parser.ConvertArchiveImages(ch.Filename, settings.ImageFormat, parser.JPEGQuality()); err != nil {
+			slog.Warn("failed to convert the chapter pages", "site", s.Name, "chapter", ch.Number, "file", ch.Filename, "format", settings.ImageFormat, "error", err)
+		}
+	}
+
+	info := &parser.ComicInfo{
+		Title:  ch.Title,
+		Number: ch.Number,
+		Web:    ch.URL,
+		Notes:  fmt.Sprintf("Source: %s, downloaded %s", s.Name, time.Now().Format(time.RFC3339)),
+	}
+	if err := parser.WriteComicInfo(ch.Filename, info); err != nil {
+		slog.Warn("failed to record provenance", "site", s.Name, "chapter", ch.Number, "file", ch.Filename, "error", err)
+	}
+	return nil
+}
+
+// writeBundle saves a debug bundle for a chapter that failed, when --debug-dir is set
+func writeBundle(source string, ch parser.Chapter, failure error) {
+	chapter := ch.Number
+	if chapter == "" {
+		chapter = ch.Filename
+	}
+	bundle, err := debugbundle.Write(source, chapter, ch.URL, failure)
+	if err != nil {
+		slog.Warn("failed to write debug bundle", "site", source, "chapter", ch.Number, "url", ch.URL, "error", err)
+		return
+	}
+	if bundle != "" {
+		fmt.Printf("Debug bundle written to %s\n", bundle)
+	}
+}
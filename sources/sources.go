@@ -0,0 +1,171 @@
+// registry of the supported source sites, used by the commands that work across every site
+package sources
+
+import (
+	"fmt"
+	"net/url"
+	"scrape/asura"
+	"scrape/auth"
+	"scrape/browser"
+	"scrape/cfotz"
+	"scrape/hls"
+	"scrape/iluim"
+	"scrape/kunmanga"
+	"scrape/manhuaus"
+	"scrape/mgeko"
+	"scrape/orv"
+	"scrape/parser"
+	"scrape/ravenscans"
+	"scrape/rizzfables"
+	"scrape/stonescape"
+	"scrape/webClient"
+	"scrape/xbato"
+	"strings"
+)
+
+// Source describes a supported site and the functions used to scrape it.
+type Source struct {
+	// Name is the site name, it matches the site command name
+	Name string
+	// Hosts are the hostnames the site is served from, used to match a URL to its source
+	Hosts []string
+	// SeriesURL is set for single series sites that have a fixed home page instead of a series URL
+	SeriesURL string
+	// Browser is set when the site needs a Chrome family browser to resolve chapters or pages
+	Browser bool
+	// Canary is a long running series checked by check-sites, SeriesURL is checked when empty
+	Canary string
+	// ChapterSelector and PageSelector are what the chapter list and the page images are scraped with, reported by
+	// check-sites when a check fails
+	ChapterSelector string
+	PageSelector    string
+	// SeriesInfo scrapes the series metadata from the series page
+	SeriesInfo func(seriesURL string) (*parser.SeriesInfo, error)
+	// Chapters lists the chapters on the series page, using the same chapter map as the site download
+	Chapters func(seriesURL string) ([]parser.Chapter, error)
+	// Pages returns the ordered page image URLs of a chapter
+	Pages func(chapterURL string) ([]string, error)
+	// DownloadChapter downloads a single chapter into its archive in the current directory
+	DownloadChapter func(chapter parser.Chapter) error
+	// Headers is the header profile the site's pages and images are requested with, see webClient.HeaderProfile
+	Headers webClient.HeaderProfile
+	// Login signs in to the site with the account configured under login, for the sites that gate chapters behind
+	// one. nil for the sites without accounts.
+	Login auth.Flow
+}
+
+// registry holds every supported site, in the same order the site commands are registered
+var registry = []*Source{
+	{Name: "manhuaus", Hosts: []string{"manhuaus.com"}, Canary: "https://manhuaus.com/manga/martial-peak/",
+		ChapterSelector: `li.wp-manga-chapter a`, PageSelector: `div.reading-content img[data-src]`,
+		SeriesInfo: manhuaus.SeriesInfo, Chapters: manhuaus.SeriesChapters, Pages: manhuaus.ChapterImages,
+		DownloadChapter: manhuaus.DownloadChapter},
+	{Name: "kunmanga", Hosts: []string{"kunmanga.com"}, Canary: "https://kunmanga.com/manga/ugly-complex/",
+		ChapterSelector: `ul.main.version-chap li.wp-manga-chapter > a`, PageSelector: `div.reading-content img`,
+		SeriesInfo: kunmanga.SeriesInfo, Chapters: kunmanga.SeriesChapters, Pages: kunmanga.ChapterImages,
+		DownloadChapter: kunmanga.DownloadChapter, Headers: webClient.HeaderProfile{Referer: webClient.RefererPage}},
+	{Name: "xbato", Hosts: []string{"xbato.com"}, Browser: true,
+		ChapterSelector: `div.episode-list div.main a.visited.chapt`, PageSelector: `img.page-img`,
+		SeriesInfo: xbato.SeriesInfo, Chapters: xbato.SeriesChapters, Pages: xbato.ChapterImages,
+		DownloadChapter: xbato.DownloadChapter},
+	{Name: "iluim", Hosts: []string{"infinitelevelup.com"}, SeriesURL: iluim.SeriesURL, Browser: true,
+		ChapterSelector: `div#Chapters_List > ul > li.widget.ceo_latest_comics_widget > ul > li > a`, PageSelector: `img[data-src]`,
+		SeriesInfo: iluim.SeriesInfo, Chapters: iluim.SeriesChapters, Pages: iluim.ChapterImages,
+		DownloadChapter: iluim.DownloadChapter},
+	{Name: "orv", Hosts: []string{"manhwa.omniscientsreadersmanga.com"}, SeriesURL: orv.SeriesURL, Browser: true,
+		ChapterSelector: `div.list-body ul.scroll-sm li.item`, PageSelector: `img`,
+		SeriesInfo: orv.SeriesInfo, Chapters: orv.SeriesChapters, Pages: orv.ChapterImages,
+		DownloadChapter: orv.DownloadChapter},
+	{Name: "rizzfables", Hosts: []string{"rizzfables.com"}, Browser: true, Canary: "https://rizzfables.com/series/r2311170-revenge-of-the-iron-blooded-sword-hound/",
+		ChapterSelector: `div.eplister ul li`, PageSelector: `#readerarea img`,
+		SeriesInfo: rizzfables.SeriesInfo, Chapters: rizzfables.SeriesChapters, Pages: rizzfables.ChapterImages,
+		DownloadChapter: rizzfables.DownloadChapter},
+	{Name: "hls", Hosts: []string{"honeylemonsoda.xyz"}, SeriesURL: hls.SeriesURL,
+		ChapterSelector: `li.item a`, PageSelector: `div#content img, div.reading-content img`,
+		SeriesInfo: hls.SeriesInfo, Chapters: hls.SeriesChapters, Pages: hls.ChapterImages,
+		DownloadChapter: hls.DownloadChapter},
+	{Name: "mgeko", Hosts: []string{"mgeko.cc", "mgeko.com"}, Canary: "https://www.mgeko.cc/manga/monster-eater/all-chapters/",
+		ChapterSelector: `ul.chapter-list li a`, PageSelector: `#chapter-reader img`,
+		SeriesInfo: mgeko.SeriesInfo, Chapters: mgeko.SeriesChapters, Pages: mgeko.ChapterImages,
+		DownloadChapter: mgeko.DownloadChapter},
+	{Name: "cfotz", Hosts: []string{"childhoodfriendofthezenith.org"}, SeriesURL: cfotz.SeriesURL,
+		ChapterSelector: `#chapters-list-holder a.chapter-list-item`, PageSelector: `figure.wp-block-image img`,
+		SeriesInfo: cfotz.SeriesInfo, Chapters: cfotz.SeriesChapters, Pages: cfotz.ChapterImages,
+		DownloadChapter: cfotz.DownloadChapter},
+	{Name: "stonescape", Hosts: []string{"stonescape.xyz"}, Browser: true, Canary: "https://stonescape.xyz/series/the-eternal-supreme/",
+		ChapterSelector: `div.listing-chapters_wrap ul.main.version-chap li.wp-manga-chapter a`, PageSelector: `img.wp-manga-chapter-img`,
+		SeriesInfo: stonescape.SeriesInfo, Chapters: stonescape.SeriesChapters, Pages: stonescape.ChapterImages,
+		DownloadChapter: stonescape.DownloadChapter},
+	{Name: "asura", Hosts: []string{"asuracomic.net", "asurascans.com"}, Browser: true, Canary: "https://asuracomic.net/series/nano-machine-4b3d9cdc",
+		ChapterSelector: `a[href*='/chapter/']`, PageSelector: `script (page image URLs)`,
+		SeriesInfo: asura.SeriesInfo, Chapters: asura.SeriesChapters, Pages: asura.ChapterImages,
+		DownloadChapter: asura.DownloadChapter},
+	{Name: "ravenscans", Hosts: []string{"ravenscans.com"}, Browser: true, Canary: "https://ravenscans.com/manga/the-tyrant-wants-to-live-honestly/",
+		ChapterSelector: `div.eplister ul li`, PageSelector: `https://manga.pics/<series>/chapter-<n>/<page>.jpg`,
+		SeriesInfo: ravenscans.SeriesInfo, Chapters: ravenscans.SeriesChapters, Pages: ravenscans.ChapterImages,
+		DownloadChapter: ravenscans.DownloadChapter},
+}
+
+// All returns every registered source.
+func All() []*Source {
+	return registry
+}
+
+// Get returns the source with the given name.
+func Get(name string) (*Source, error) {
+	for _, src := range registry {
+		if src.Name == name {
+			return src, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown source %q", name)
+}
+
+// ForURL returns the source that serves rawURL, matching on the hostname (with or without a www. prefix).
+func ForURL(rawURL string) (*Source, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %q: %w", rawURL, err)
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+
+	for _, src := range registry {
+		for _, h := range src.Hosts {
+			if host == h || strings.HasSuffix(host, "."+h) {
+				return src, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no source registered for host %q", u.Hostname())
+}
+
+// CheckBrowser runs the browser capability probe for sources that need a browser, nil for the others.
+func (s *Source) CheckBrowser() error {
+	if !s.Browser {
+		return nil
+	}
+	if webClient.Offline() {
+		return fmt.Errorf("%s needs a browser, browser pages are not cached so it does not work with --offline", s.Name)
+	}
+	if err := browser.Check(); err != nil {
+		return fmt.Errorf("%s needs a browser: %w", s.Name, err)
+	}
+	return nil
+}
+
+// Info scrapes the series metadata for seriesURL, tagging it with the source name.
+func (s *Source) Info(seriesURL string) (*parser.SeriesInfo, error) {
+	if seriesURL == "" {
+		seriesURL = s.SeriesURL
+	}
+	var info *parser.SeriesInfo
+	err := s.withLogin(func() (err error) {
+		info, err = s.SeriesInfo(seriesURL)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	info.Source = s.Name
+	return info, nil
+}
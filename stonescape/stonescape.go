@@ -1,97 +1,86 @@
 package stonescape
 
 import (
-	"context"
 	"fmt"
-	"log"
 	"regexp"
+	"scrape/browser"
+	"scrape/debugbundle"
+	"scrape/logging"
 	"scrape/parser"
+	"scrape/webClient"
 	"strings"
 	"time"
 
 	"github.com/chromedp/chromedp"
 )
 
-func DownloadChapters(seriesUrl string) {
-	// step zero call clean up func
-	var tempDirList []string
-	parser.CleanupTempDirs(&tempDirList)
+var logger = logging.Site("stonescape")
 
-	// Step 1: Get chapter map from website
-	chapterMap, err := chapterUrls(seriesUrl)
-	if err != nil {
-		log.Fatalf("Failed to download chapter list: %v", err)
-	}
+// DownloadChapter downloads the chapter images, converts them to JPG and creates the chapter cbz file
+func DownloadChapter(chapter parser.Chapter) error {
+	filename := chapter.Filename
+	chapterName := strings.Split(filename, ".cbz")
+
+	chapterURL := chapter.URL
+	fmt.Printf("Downloading %s\n", chapterName[0])
+	logger.Info("downloading chapter", "chapter", chapter.Number, "file", filename, "url", chapterURL)
 
-	// Step 2: Sort keys descending
-	keys, err := parser.SortKeys(chapterMap)
+	// Fetch chapter images
+	chapterImageList, err := chapterImageUrls(chapterURL)
 	if err != nil {
-		log.Fatalf("Failed to sort chapters: %v", err)
+		return fmt.Errorf("failed to get chapter image: %w", err)
+	}
+	if len(chapterImageList) == 0 {
+		return fmt.Errorf("%s: %w", chapterName[0], debugbundle.NoMatch(chapterURL, "img.wp-manga-chapter-img", "images"))
 	}
 
-	// Step 3: Get existing CBZ files to skip already downloaded
-	existing, err := parser.GetDownloadedCBZ(".")
+	// an interrupted download resumes from the images staged by the last run
+	stage, err := parser.StageChapter(chapterURL)
 	if err != nil {
-		log.Fatalf("Failed to read current directory: %v", err)
+		return err
 	}
+	logger.Debug("staging chapter", "chapter", chapter.Number, "dir", stage.Dir)
 
-	// Step 4: Loop through each chapter
-	for _, filename := range keys {
-		if existing[filename] {
-			log.Printf("Skipping %s - already exists.", filename)
-			continue
-		}
-
-		chapterName := strings.Split(filename, ".cbz")
-
-		chapterURL := chapterMap[filename]
-		fmt.Printf("Downloading %s\n", chapterName[0])
-		log.Printf("Downloading %s from %s", filename, chapterURL)
-
-		// Fetch chapter images
-		chapterImageList, chImgErr := chapterImageUrls(chapterURL)
-		if chImgErr != nil {
-			log.Fatalf("Failed to get chapter image: %v", chImgErr)
-		}
-
-		// create temp dir name
-		dirName := strings.Split(filename, ".cbz")
-		tempPrefix := dirName[0]
-
-		// create the temp directory (prefix and random string create teh temp dir name)
-		tempDir, err := parser.CreateTempDir(tempPrefix)
+	// every image is converted to JPG, named after its URL
+	for _, image := range chapterImageList {
+		err := stage.Page(parser.JPGName(image), image, func() error {
+			return parser.DownloadAndConvertToJPG(image, chapterURL, stage.Dir)
+		})
 		if err != nil {
-			log.Printf("could not create temp directory: %s", tempDir)
-		}
-		// append to the list of temp directories that need to be removed once the download func finishes or
-		// crashes/hard exits
-		tempDirList = append(tempDirList, tempDir)
-		log.Printf("Created tempdir: %s", tempDir)
-
-		// download chapter images to temp directory
-		for _, image := range chapterImageList {
-			parser.DownloadAndConvertToJPG(image, tempDir)
+			logger.Error("failed to download image", "chapter", chapter.Number, "url", image, "error", err)
 		}
+	}
 
-		// create the cbz file
-		parser.CreateCbzFromDir(tempDir, filename)
-		fmt.Printf("Downloaded: %s\n", filename)
+	// create the cbz file
+	if err := stage.Archive(filename, len(chapterImageList)); err != nil {
+		return err
 	}
+	fmt.Printf("Downloaded: %s\n", filename)
+
+	return nil
 }
 
-// fetches all chapter URLs for a given StoneScape series URL
-// Returns a map of chapter number (string) : chapter URL
-func chapterUrls(seriesURL string) (map[string]string, error) {
-	ctx, cancel := chromedp.NewContext(context.Background())
-	defer cancel()
+// SeriesChapters returns the chapters listed on the series page
+func SeriesChapters(seriesURL string) ([]parser.Chapter, error) {
+	chapters, err := chapterList(seriesURL)
+	if err != nil {
+		return nil, err
+	}
+	parser.SortChapters(chapters)
+	return chapters, nil
+}
 
-	ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
+// ChapterImages returns all image URLs from a single StoneScape chapter page
+func ChapterImages(chapterURL string) ([]string, error) {
+	return chapterImageUrls(chapterURL)
+}
 
-	chapterMap := make(map[string]string)
+// fetches the chapter list for a given StoneScape series URL
+func chapterList(seriesURL string) ([]parser.Chapter, error) {
+	var chapters []parser.Chapter
 	var rawChapters []map[string]string
 
-	err := chromedp.Run(ctx,
+	err := browser.Run(30*time.Second,
 		chromedp.Navigate(seriesURL),
 		chromedp.WaitVisible(`div.listing-chapters_wrap ul.main.version-chap li.wp-manga-chapter a`, chromedp.ByQuery),
 		chromedp.Evaluate(`
@@ -101,7 +90,10 @@ func chapterUrls(seriesURL string) (map[string]string, error) {
         // Match "Ch. 72", "Ch. 72.5", "Ch. 72-5", "Ch. 72 Season 1 End", etc.
         const m = txt.match(/^Ch\.\s*(.+)$/i);
         if (m) {
-            return { num: m[1].trim().replace(/\s+/g, '-').toLowerCase(), url: a.href };
+            const li = a.closest('li');
+            const date = li && li.querySelector('.chapter-release-date');
+            const released = date ? (date.textContent.trim() || (date.querySelector('a[title]') || {}).title || '') : '';
+            return { num: m[1].trim().replace(/\s+/g, '-').toLowerCase(), url: a.href, title: txt, released: released };
         }
         return null;
     })
@@ -112,40 +104,42 @@ func chapterUrls(seriesURL string) (map[string]string, error) {
 		return nil, err
 	}
 
-	// Remove duplicates
+	// Remove duplicates and create the chapter file names
 	seen := make(map[string]struct{})
 	for _, chap := range rawChapters {
 		num := chap["num"]
-		url := chap["url"]
 		if _, exists := seen[num]; !exists {
 			seen[num] = struct{}{}
-			chapterMap[num] = url
+			fileName := chapterFileName(num)
+			released, _ := parser.ParseReleaseDate(chap["released"], time.Now())
+			chapters = append(chapters, parser.Chapter{
+				Number:   parser.ChapterNumber(fileName),
+				Title:    chap["title"],
+				Filename: fileName,
+				URL:      chap["url"],
+				Released: released,
+			})
 		}
 	}
 
-	// create the chapter file names and use them as the map keys
-	result := chapterMapping(chapterMap)
-
-	return result, nil
+	return chapters, nil
 }
 
 // Fetches all image URLs from a single StoneScape chapter page
 func chapterImageUrls(chapterURL string) ([]string, error) {
-	ctx, cancel := chromedp.NewContext(context.Background())
-	defer cancel()
-
-	ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
 	var imageLinks []string
 
-	err := chromedp.Run(ctx,
+	// allow time to scroll through long chapters
+	err := browser.Run(2*time.Minute,
 		// Navigate to the chapter page
 		chromedp.Navigate(chapterURL),
 
 		// Wait for at least one image to be visible
 		chromedp.WaitVisible(`img.wp-manga-chapter-img`, chromedp.ByQuery),
 
+		// Scroll down the page so the lazy loaded images are loaded
+		browser.ScrollFor("stonescape", "img.wp-manga-chapter-img"),
+
 		// Extract the src attributes of all images
 		chromedp.Evaluate(`
 			[...document.querySelectorAll('img.wp-manga-chapter-img')].map(img => img.src.trim())
@@ -158,19 +152,6 @@ func chapterImageUrls(chapterURL string) ([]string, error) {
 	return imageLinks, nil
 }
 
-// take in the chapter URLs map and contructs the chapter file names based on the map key
-func chapterMapping(inputMap map[string]string) map[string]string {
-
-	var chapterMap = make(map[string]string)
-
-	for key, value := range inputMap {
-		fileName := chapterFileName(key)
-		chapterMap[fileName] = value
-	}
-
-	return chapterMap
-}
-
 // from the chapter number (key in chapterList) return the chapter filename
 func chapterFileName(chapter string) string {
 	// Regex: captures integer + optional decimal + optional suffix
@@ -202,3 +183,13 @@ func chapterFileName(chapter string) string {
 
 	return fileName + ".cbz"
 }
+
+// SeriesInfo scrapes the series metadata from the Madara summary block of the stonescape series page
+func SeriesInfo(seriesURL string) (*parser.SeriesInfo, error) {
+	doc, err := webClient.FetchDocument(seriesURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.MadaraSeriesInfo(doc, seriesURL), nil
+}
@@ -0,0 +1,47 @@
+package stonescape
+
+import (
+	"path/filepath"
+	"scrape/replay/replaytest"
+	"testing"
+)
+
+func TestChapterFileName(t *testing.T) {
+	tests := map[string]string{
+		"1":               "ch001.cbz",
+		"72.5":            "ch072.5.cbz",
+		"72-5":            "ch072.5.cbz",
+		"72-season-1-end": "ch072-season-1-end.cbz",
+		"120":             "ch120.cbz",
+		"prologue":        "chprologue.cbz",
+	}
+	for input, want := range tests {
+		if got := chapterFileName(input); got != want {
+			t.Errorf("chapterFileName(%q) = %q, expected %q", input, got, want)
+		}
+	}
+}
+
+// the chapter list needs the browser, the series info is read from the recorded series page
+func TestSeriesInfo(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	seriesURL := "https://stonescape.xyz/series/the-eternal-supreme/"
+	info, err := SeriesInfo(seriesURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Title != "The Eternal Supreme" {
+		t.Errorf("Title = %q, expected %q", info.Title, "The Eternal Supreme")
+	}
+	if info.CoverURL != "https://stonescape.xyz/wp-content/uploads/eternal-supreme.jpg" {
+		t.Errorf("CoverURL = %q", info.CoverURL)
+	}
+	if len(info.Authors) != 1 || info.Authors[0] != "Anonymous" {
+		t.Errorf("Authors = %v, expected [Anonymous]", info.Authors)
+	}
+	if info.Status != "OnGoing" {
+		t.Errorf("Status = %q, expected OnGoing", info.Status)
+	}
+}
@@ -0,0 +1,278 @@
+package webClient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"scrape/atomicfile"
+	"strings"
+	"sync"
+	"time"
+)
+
+// URL classes, each with its own cache TTL
+const (
+	ClassSeries  = "series"
+	ClassChapter = "chapter"
+	ClassImage   = "image"
+)
+
+// CacheOptions configure the on-disk HTTP cache used by the collectors and NewHTTPClient
+type CacheOptions struct {
+	// Dir holds the cached responses, caching is disabled when empty
+	Dir string
+	// TTL is how long a response of each URL class is used without asking the site again. Once stale, a response
+	// with an ETag or Last-Modified header is revalidated with a conditional GET. A class with no TTL is not cached.
+	TTL map[string]time.Duration
+	// Offline serves every request from the cache, whatever its age, and fails the ones that are not cached
+	Offline bool
+}
+
+// DefaultCacheTTL are the TTLs used for the URL classes missing from CacheOptions.TTL. Series pages change whenever a
+// chapter is released, chapter pages hardly ever do, and images are not cached.
+var DefaultCacheTTL = map[string]time.Duration{
+	ClassSeries:  time.Hour,
+	ClassChapter: 7 * 24 * time.Hour,
+	ClassImage:   0,
+}
+
+// ErrNotCached is returned in offline mode for a request that is not in the cache
+var ErrNotCached = errors.New("not in the cache (offline)")
+
+// DefaultCacheDir returns the user cache directory for the HTTP cache, eg: ~/.cache/scrape/http
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "scrape", "http")
+}
+
+var (
+	cacheMu      sync.Mutex
+	cacheOptions CacheOptions
+)
+
+// ConfigureCache sets the HTTP cache options for the collectors and clients created after it is called
+func ConfigureCache(opts CacheOptions) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	cacheOptions = opts
+}
+
+// Offline reports whether requests are served only from the cache
+func Offline() bool {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	return cacheOptions.Offline
+}
+
+// Transport returns the round tripper for the scrapers' requests: the default transport with the per site request
+// options applied, see ConfigureRequests, behind the cache when it is configured. Cached responses do not count
+// towards a site's rate limit.
+func Transport() http.RoundTripper {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	site := &siteTransport{next: http.DefaultTransport}
+	if cacheOptions.Dir == "" && !cacheOptions.Offline {
+		return site
+	}
+	return &cacheTransport{opts: cacheOptions, next: site}
+}
+
+var imageExt = regexp.MustCompile(`(?i)\.(jpe?g|png|webp|gif|avif)$`)
+
+// chapterPath matches the chapter page paths of the sites, eg: /chapter-12/, /chapter/2013166, -chapter-10-5-eng-li/,
+// but not series pages that only list the chapters, eg: mgeko's /manga/<name>/all-chapters/
+var chapterPath = regexp.MustCompile(`(?i)chapter[-_/]\w`)
+
+// URLClass classifies a URL as an image, a chapter page or anything else (a series page)
+func URLClass(u string) string {
+	path := u
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	switch {
+	case imageExt.MatchString(path):
+		return ClassImage
+	case chapterPath.MatchString(path):
+		return ClassChapter
+	default:
+		return ClassSeries
+	}
+}
+
+// cacheEntry is the metadata of a cached response, the body is stored next to it
+type cacheEntry struct {
+	URL          string      `json:"url"`
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header"`
+	Stored       time.Time   `json:"stored"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+}
+
+// cacheTransport serves GET requests from the disk cache, revalidating stale responses with conditional requests
+type cacheTransport struct {
+	opts CacheOptions
+	next http.RoundTripper
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		if t.opts.Offline {
+			return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL, ErrNotCached)
+		}
+		return t.next.RoundTrip(req)
+	}
+
+	class := URLClass(req.URL.String())
+	ttl, ok := t.opts.TTL[class]
+	if !ok {
+		ttl = DefaultCacheTTL[class]
+	}
+
+	key := cacheKey(req)
+	entry, body, err := t.load(key)
+	if err != nil && !os.IsNotExist(err) {
+		slog.Warn("cache: ignoring unreadable entry", "url", req.URL, "error", err)
+	}
+	cached := err == nil
+
+	if t.opts.Offline {
+		if !cached {
+			return nil, fmt.Errorf("GET %s: %w", req.URL, ErrNotCached)
+		}
+		return entry.response(req, body), nil
+	}
+	if ttl <= 0 || t.opts.Dir == "" {
+		return t.next.RoundTrip(req)
+	}
+	if cached && time.Since(entry.Stored) < ttl {
+		slog.Debug("cache: fresh", "url", req.URL, "class", class)
+		return entry.response(req, body), nil
+	}
+
+	// revalidate a stale response that has validators, the caller's request is left untouched
+	outgoing := req
+	if cached && (entry.ETag != "" || entry.LastModified != "") {
+		outgoing = req.Clone(req.Context())
+		if entry.ETag != "" {
+			outgoing.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			outgoing.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		slog.Debug("cache: not modified", "url", req.URL, "class", class)
+		entry.Stored = time.Now()
+		if err := t.save(key, entry, body); err != nil {
+			slog.Warn("cache: failed to refresh", "url", req.URL, "error", err)
+		}
+		return entry.response(req, body), nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	entry = &cacheEntry{
+		URL:          req.URL.String(),
+		Status:       resp.StatusCode,
+		Header:       resp.Header,
+		Stored:       time.Now(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if err := t.save(key, entry, data); err != nil {
+		slog.Warn("cache: failed to store", "url", req.URL, "error", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+// cacheKey is the file name of the cached response of req
+func cacheKey(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + req.URL.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// entries are spread over subdirectories by the first two characters of the key
+func (t *cacheTransport) path(key string) string {
+	return filepath.Join(t.opts.Dir, key[:2], key)
+}
+
+func (t *cacheTransport) load(key string) (*cacheEntry, []byte, error) {
+	meta, err := os.ReadFile(t.path(key) + ".json")
+	if err != nil {
+		return nil, nil, err
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(meta, &entry); err != nil {
+		return nil, nil, err
+	}
+	body, err := os.ReadFile(t.path(key) + ".body")
+	if err != nil {
+		return nil, nil, err
+	}
+	return &entry, body, nil
+}
+
+// save writes the body before the metadata, so an entry is only found once it is complete
+func (t *cacheTransport) save(key string, entry *cacheEntry, body []byte) error {
+	meta, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path(key)), 0755); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(t.path(key)+".body", body, 0600); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(t.path(key)+".json", meta, 0600)
+}
+
+// response rebuilds the cached response for req
+func (e *cacheEntry) response(req *http.Request, body []byte) *http.Response {
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	resp.Header.Set("X-Scrape-Cache", "hit")
+	return resp
+}
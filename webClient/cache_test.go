@@ -0,0 +1,91 @@
+package webClient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheTransport(t *testing.T) {
+	requests, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("page " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	get := func(opts CacheOptions, path string) (string, error) {
+		t.Helper()
+		opts.Dir = dir
+		client := &http.Client{Transport: &cacheTransport{opts: opts, next: http.DefaultTransport}}
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	fresh := CacheOptions{TTL: map[string]time.Duration{ClassSeries: time.Hour}}
+	for range 2 {
+		if body, err := get(fresh, "/series/a"); err != nil || body != "page /series/a" {
+			t.Fatalf("get() = %q, %v", body, err)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("fresh entry refetched: %d requests", requests)
+	}
+
+	// a stale entry is revalidated with its ETag and served from the cache on 304
+	stale := CacheOptions{TTL: map[string]time.Duration{ClassSeries: time.Nanosecond}}
+	if body, err := get(stale, "/series/a"); err != nil || body != "page /series/a" {
+		t.Fatalf("revalidated get() = %q, %v", body, err)
+	}
+	if requests != 2 || notModified != 1 {
+		t.Fatalf("expected one conditional request, got %d requests and %d not modified", requests, notModified)
+	}
+
+	// a class with no TTL is not stored
+	if _, err := get(fresh, "/images/1.jpg"); err != nil {
+		t.Fatal(err)
+	}
+
+	offline := CacheOptions{Offline: true}
+	if body, err := get(offline, "/series/a"); err != nil || body != "page /series/a" {
+		t.Fatalf("offline get() = %q, %v", body, err)
+	}
+	if _, err := get(offline, "/images/1.jpg"); !errors.Is(err, ErrNotCached) {
+		t.Fatalf("offline get() of an uncached URL = %v, expected ErrNotCached", err)
+	}
+	if requests != 3 {
+		t.Fatalf("offline requests reached the site: %d requests", requests)
+	}
+}
+
+func TestURLClass(t *testing.T) {
+	tests := map[string]string{
+		"https://kunmanga.com/manga/ugly-complex/":                                                  ClassSeries,
+		"https://kunmanga.com/manga/ugly-complex/chapter-12/":                                       ClassChapter,
+		"https://cdn.example.com/uploads/page-001.JPG?v=2":                                          ClassImage,
+		"https://xbato.com/chapter/2013166/some-chapter-title":                                      ClassChapter,
+		"https://www.mgeko.cc/manga/monster-eater/all-chapters/":                                    ClassSeries,
+		"https://www.mgeko.cc/reader/en/monster-eater-chapter-10-5-eng-li/":                         ClassChapter,
+		"https://rizzfables.com/chapter/r2311170-revenge-of-the-iron-blooded-sword-hound-chapter-1": ClassChapter,
+	}
+	for u, want := range tests {
+		if got := URLClass(u); got != want {
+			t.Errorf("URLClass(%q) = %s, expected %s", u, got, want)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package webClient
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"scrape/atomicfile"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJar is the cookie jar shared by every request of a run: the collectors and HTTP clients through their
+// transport, and the browser tabs. It reads and writes the Netscape cookies.txt format browser extensions export.
+type CookieJar struct {
+	mu      sync.Mutex
+	cookies map[string]*jarCookie
+	changed bool
+}
+
+// jarCookie is a stored cookie, Domain has no leading dot
+type jarCookie struct {
+	Name     string
+	Value    string
+	Domain   string
+	Path     string
+	HostOnly bool
+	Secure   bool
+	HttpOnly bool
+	// Expires is zero for a session cookie
+	Expires time.Time
+}
+
+func (c *jarCookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+func (c *jarCookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// matches reports whether the cookie is sent with a request to u
+func (c *jarCookie) matches(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	if c.HostOnly && host != c.Domain || !c.HostOnly && !domainMatch(host, c.Domain) {
+		return false
+	}
+	if c.Secure && u.Scheme != "https" && u.Scheme != "wss" {
+		return false
+	}
+
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	return p == c.Path || strings.HasPrefix(p, c.Path) && (strings.HasSuffix(c.Path, "/") || p[len(c.Path)] == '/')
+}
+
+func domainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// NewCookieJar returns an empty cookie jar
+func NewCookieJar() *CookieJar {
+	return &CookieJar{cookies: make(map[string]*jarCookie)}
+}
+
+var cookieJar = NewCookieJar()
+
+// Jar returns the cookie jar shared by every request of the run
+func Jar() *CookieJar {
+	return cookieJar
+}
+
+// SetCookies stores the cookies of a response to u, implementing http.CookieJar. Cookies for a domain u does not
+// belong to are ignored.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, c := range cookies {
+		jc := &jarCookie{Name: c.Name, Value: c.Value, Path: c.Path, Secure: c.Secure, HttpOnly: c.HttpOnly, Domain: host, HostOnly: true}
+
+		if domain := strings.TrimPrefix(strings.ToLower(c.Domain), "."); domain != "" {
+			if !domainMatch(host, domain) || !strings.Contains(domain, ".") {
+				continue
+			}
+			jc.Domain, jc.HostOnly = domain, false
+		}
+		if !strings.HasPrefix(jc.Path, "/") {
+			jc.Path = defaultPath(u)
+		}
+
+		switch {
+		case c.MaxAge < 0:
+			jc.Expires = now
+		case c.MaxAge > 0:
+			jc.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		case !c.Expires.IsZero():
+			jc.Expires = c.Expires
+		}
+		j.store(jc, now)
+	}
+}
+
+// defaultPath is the directory of the request path, the path of a cookie set without one
+func defaultPath(u *url.URL) string {
+	p := u.EscapedPath()
+	if !strings.HasPrefix(p, "/") || strings.Count(p, "/") == 1 {
+		return "/"
+	}
+	return p[:strings.LastIndex(p, "/")]
+}
+
+// store adds or replaces a cookie, an expired cookie removes the stored one. j.mu must be held.
+func (j *CookieJar) store(c *jarCookie, now time.Time) {
+	old, ok := j.cookies[c.key()]
+	if c.expired(now) {
+		if ok {
+			delete(j.cookies, c.key())
+			j.changed = true
+		}
+		return
+	}
+	if ok && *old == *c {
+		return
+	}
+	j.cookies[c.key()] = c
+	j.changed = true
+}
+
+// Cookies returns the cookies to send with a request to u, implementing http.CookieJar. Longer paths come first.
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var matched []*jarCookie
+	for key, c := range j.cookies {
+		if c.expired(now) {
+			delete(j.cookies, key)
+			j.changed = true
+			continue
+		}
+		if c.matches(u) {
+			matched = append(matched, c)
+		}
+	}
+	sort.Slice(matched, func(a, b int) bool {
+		if len(matched[a].Path) != len(matched[b].Path) {
+			return len(matched[a].Path) > len(matched[b].Path)
+		}
+		return matched[a].Name < matched[b].Name
+	})
+
+	cookies := make([]*http.Cookie, len(matched))
+	for i, c := range matched {
+		cookies[i] = &http.Cookie{Name: c.Name, Value: c.Value}
+	}
+	return cookies
+}
+
+// All returns every stored cookie with its attributes, the Domain of a cookie sent to subdomains has a leading dot
+func (j *CookieJar) All() []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var cookies []*http.Cookie
+	for _, c := range j.sorted() {
+		domain := c.Domain
+		if !c.HostOnly {
+			domain = "." + domain
+		}
+		cookies = append(cookies, &http.Cookie{
+			Name: c.Name, Value: c.Value, Domain: domain, Path: c.Path,
+			Secure: c.Secure, HttpOnly: c.HttpOnly, Expires: c.Expires,
+		})
+	}
+	return cookies
+}
+
+// Add stores cookies that carry their own attributes, eg: read from the browser. A Domain with a leading dot is sent
+// to subdomains too, a cookie without a Path is for the whole site. A zero Expires is a session cookie.
+func (j *CookieJar) Add(cookies ...*http.Cookie) {
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, c := range cookies {
+		jc := &jarCookie{Name: c.Name, Value: c.Value, Path: c.Path, Secure: c.Secure, HttpOnly: c.HttpOnly, Expires: c.Expires}
+		jc.Domain = strings.ToLower(c.Domain)
+		jc.HostOnly = !strings.HasPrefix(jc.Domain, ".")
+		jc.Domain = strings.TrimPrefix(jc.Domain, ".")
+		if jc.Domain == "" {
+			continue
+		}
+		if jc.Path == "" {
+			jc.Path = "/"
+		}
+		j.store(jc, now)
+	}
+}
+
+// sorted returns the cookies ordered by domain, path and name. j.mu must be held.
+func (j *CookieJar) sorted() []*jarCookie {
+	cookies := make([]*jarCookie, 0, len(j.cookies))
+	for _, c := range j.cookies {
+		cookies = append(cookies, c)
+	}
+	sort.Slice(cookies, func(a, b int) bool { return cookies[a].key() < cookies[b].key() })
+	return cookies
+}
+
+// ReadCookiesTxt parses a Netscape cookies.txt file, skipping the cookies that have expired. keep (optional) selects
+// the cookies to return by domain, without the leading dot.
+func ReadCookiesTxt(r io.Reader, keep func(domain string) bool) ([]*http.Cookie, error) {
+	now := time.Now()
+	var cookies []*http.Cookie
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line, httpOnly = rest, true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab separated fields, got %d", n, len(fields))
+		}
+		domain, subdomains, cookiePath, secure, expiry, name, value := fields[0], fields[1], fields[2], fields[3], fields[4], fields[5], fields[6]
+
+		seconds, err := strconv.ParseInt(expiry, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry %q", n, expiry)
+		}
+		var expires time.Time
+		if seconds > 0 {
+			expires = time.Unix(seconds, 0)
+			if !expires.After(now) {
+				continue
+			}
+		}
+
+		// the domain field of a cookie sent to subdomains has a leading dot, some exporters only set the flag
+		domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+		if keep != nil && !keep(domain) {
+			continue
+		}
+		if strings.EqualFold(subdomains, "TRUE") {
+			domain = "." + domain
+		}
+
+		cookies = append(cookies, &http.Cookie{
+			Name: name, Value: value, Domain: domain, Path: cookiePath,
+			Secure: strings.EqualFold(secure, "TRUE"), HttpOnly: httpOnly, Expires: expires,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+// WriteCookiesTxt writes the cookies in the Netscape cookies.txt format, session cookies with an expiry of 0
+func WriteCookiesTxt(w io.Writer, cookies []*http.Cookie) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# Netscape HTTP Cookie File")
+	fmt.Fprintln(bw, "# Written by scrape, the cookies of the sites it has visited.")
+	fmt.Fprintln(bw)
+
+	flag := func(b bool) string {
+		if b {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	for _, c := range cookies {
+		prefix := ""
+		if c.HttpOnly {
+			prefix = "#HttpOnly_"
+		}
+		var expiry int64
+		if !c.Expires.IsZero() {
+			expiry = c.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s%s\t%s\t%s\t%s\t%d\t%s\t%s\n", prefix, c.Domain, flag(strings.HasPrefix(c.Domain, ".")),
+			c.Path, flag(c.Secure), expiry, c.Name, c.Value)
+	}
+	return bw.Flush()
+}
+
+// Load adds the cookies of the cookies.txt file at path to the jar, keep (optional) selects them by domain. Returns
+// the number of cookies added.
+func (j *CookieJar) Load(path string, keep func(domain string) bool) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	cookies, err := ReadCookiesTxt(f, keep)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	j.Add(cookies...)
+	return len(cookies), nil
+}
+
+// Save writes the jar to path in the cookies.txt format, readable only by the user, if it changed since it was
+// loaded or last saved
+func (j *CookieJar) Save(path string) error {
+	j.mu.Lock()
+	changed := j.changed
+	j.mu.Unlock()
+	if !changed {
+		return nil
+	}
+
+	var buf strings.Builder
+	if err := WriteCookiesTxt(&buf, j.All()); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(path, []byte(buf.String()), 0600); err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	j.changed = false
+	j.mu.Unlock()
+	return nil
+}
+
+// MarkSaved records the jar as unchanged, eg: after loading it from its own file
+func (j *CookieJar) MarkSaved() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.changed = false
+}
+
+// DefaultCookieFile returns the file the shared cookie jar is kept in between runs, in the XDG state directory, eg:
+// ~/.local/state/scrape/cookies.txt
+func DefaultCookieFile() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "scrape", "cookies.txt")
+}
+
+// HostCookieFilter returns a filter keeping the cookies of the given hosts and their subdomains, and the cookies of
+// the parent domains sent to them
+func HostCookieFilter(hosts []string) func(domain string) bool {
+	return func(domain string) bool {
+		for _, h := range hosts {
+			if domainMatch(domain, h) || domainMatch(h, domain) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+var _ http.CookieJar = (*CookieJar)(nil)
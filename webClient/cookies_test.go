@@ -0,0 +1,155 @@
+package webClient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const cookiesTxt = `# Netscape HTTP Cookie File
+
+.example.com	TRUE	/	FALSE	4102444800	session	abc
+#HttpOnly_www.example.com	FALSE	/reader	TRUE	0	token	xyz
+.other.org	TRUE	/	FALSE	4102444800	tracking	1
+.example.com	TRUE	/	FALSE	946684800	old	expired
+`
+
+func cookieNames(cookies []*http.Cookie) string {
+	var names []string
+	for _, c := range cookies {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestReadCookiesTxt(t *testing.T) {
+	cookies, err := ReadCookiesTxt(strings.NewReader(cookiesTxt), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := cookieNames(cookies); names != "session,token,tracking" {
+		t.Fatalf("cookies = %s, want the 3 that have not expired", names)
+	}
+	if c := cookies[1]; c.Domain != "www.example.com" || c.Path != "/reader" || !c.Secure || !c.HttpOnly || !c.Expires.IsZero() {
+		t.Errorf("token = %+v, want a host-only, secure, HttpOnly session cookie under /reader", c)
+	}
+
+	cookies, err = ReadCookiesTxt(strings.NewReader(cookiesTxt), HostCookieFilter([]string{"www.example.com"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := cookieNames(cookies); names != "session,token" {
+		t.Errorf("filtered cookies = %s, want the example.com ones", names)
+	}
+
+	if _, err := ReadCookiesTxt(strings.NewReader("example.com\tTRUE\t/\n"), nil); err == nil {
+		t.Error("reading a line with 3 fields succeeded, want an error")
+	}
+}
+
+func TestCookieJarMatching(t *testing.T) {
+	jar := NewCookieJar()
+	cookies, _ := ReadCookiesTxt(strings.NewReader(cookiesTxt), nil)
+	jar.Add(cookies...)
+
+	for _, test := range []struct {
+		url  string
+		want string
+	}{
+		{"https://www.example.com/reader/ch-1", "token,session"},
+		{"http://www.example.com/reader/ch-1", "session"},
+		{"https://www.example.com/readers", "session"},
+		{"https://cdn.example.com/reader/", "session"},
+		{"https://example.org/", ""},
+	} {
+		u, _ := url.Parse(test.url)
+		if got := cookieNames(jar.Cookies(u)); got != test.want {
+			t.Errorf("cookies for %s = %q, want %q", test.url, got, test.want)
+		}
+	}
+
+	u, _ := url.Parse("https://www.example.com/series/a")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "", Domain: "example.com", Path: "/", MaxAge: -1},
+		{Name: "foreign", Value: "1", Domain: "other.org"},
+		{Name: "visit", Value: "2"},
+	})
+	if got := cookieNames(jar.Cookies(u)); got != "visit" {
+		t.Errorf("cookies after the response = %q, want session deleted, foreign ignored and visit stored", got)
+	}
+	sub, _ := url.Parse("https://cdn.example.com/series/b")
+	if got := cookieNames(jar.Cookies(sub)); got != "" {
+		t.Errorf("cookies for a subdomain = %q, want none: visit is host-only", got)
+	}
+}
+
+func TestCookieJarSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "cookies.txt")
+	jar := NewCookieJar()
+	cookies, _ := ReadCookiesTxt(strings.NewReader(cookiesTxt), nil)
+	jar.Add(cookies...)
+	if err := jar.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("saved jar = %v, %v; want a file readable by the user only", info, err)
+	}
+
+	loaded := NewCookieJar()
+	if n, err := loaded.Load(path, nil); err != nil || n != 3 {
+		t.Fatalf("Load = %d, %v; want 3 cookies", n, err)
+	}
+	if a, b := jar.All(), loaded.All(); cookieNames(a) != cookieNames(b) || a[1].Domain != b[1].Domain || a[0].Domain != b[0].Domain {
+		t.Errorf("loaded %v, want the saved %v", b, a)
+	}
+
+	// an unchanged jar is not written again
+	loaded.MarkSaved()
+	os.Remove(path)
+	if err := loaded.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("an unchanged jar was saved")
+	}
+}
+
+func TestSiteTransportCookieJar(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err == nil {
+			got = append(got, c.Value)
+		} else {
+			got = append(got, "")
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "from-server", Path: "/", Expires: time.Now().Add(time.Hour)})
+	}))
+	defer server.Close()
+
+	saved := cookieJar
+	cookieJar = NewCookieJar()
+	defer func() { cookieJar = saved }()
+
+	client := &http.Client{Transport: &siteTransport{next: http.DefaultTransport}}
+	for range 2 {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	if len(got) != 2 || got[0] != "" || got[1] != "from-server" {
+		t.Errorf("session cookies sent = %q, want none then the one the server set", got)
+	}
+	if !cookieJar.changed {
+		t.Error("the jar is not marked changed after storing a cookie")
+	}
+}
@@ -0,0 +1,191 @@
+package webClient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+)
+
+// DefaultUserAgent is the browser User-Agent of DefaultProfile
+const DefaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36"
+
+// ErrChallenge is returned for an anti-bot challenge page, eg: Cloudflare's "Just a moment..." interstitial
+var ErrChallenge = errors.New("anti-bot challenge page")
+
+// Page is a fetched HTML page
+type Page struct {
+	// URL is the page URL after redirects
+	URL    string
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Document parses the page
+func (p *Page) Document() (*goquery.Document, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(p.Body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", p.URL, err)
+	}
+	return doc, nil
+}
+
+// Fetcher fetches web pages, over plain HTTP or in a browser
+type Fetcher interface {
+	// Fetch returns the page at pageURL. A challenge page is returned with an error wrapping ErrChallenge.
+	Fetch(pageURL string) (*Page, error)
+}
+
+// HTTPFetcher fetches pages with a colly collector, through the shared transport and cookie jar
+type HTTPFetcher struct{}
+
+func (f HTTPFetcher) Fetch(pageURL string) (*Page, error) {
+	c := NewCollector()
+
+	var page *Page
+	keep := func(r *colly.Response) {
+		page = &Page{URL: r.Request.URL.String(), Status: r.StatusCode, Body: r.Body}
+		if r.Headers != nil {
+			page.Header = *r.Headers
+		}
+	}
+	c.OnResponse(keep)
+	c.OnError(func(r *colly.Response, err error) {
+		if r != nil && r.Request != nil {
+			keep(r)
+		}
+	})
+
+	err := c.Visit(pageURL)
+	if page != nil && IsChallenge(page) {
+		return page, fmt.Errorf("%w at %s (HTTP %d)", ErrChallenge, pageURL, page.Status)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", pageURL, err)
+	}
+	if page == nil {
+		return nil, fmt.Errorf("empty response from %s", pageURL)
+	}
+	return page, nil
+}
+
+// IsChallenge reports whether page is an anti-bot challenge instead of the page asked for: Cloudflare's
+// cf-mitigated header, or a 403 / 429 / 503 carrying the challenge scripts, or the "Just a moment..." interstitial
+func IsChallenge(page *Page) bool {
+	if page.Header.Get("Cf-Mitigated") == "challenge" {
+		return true
+	}
+
+	body := page.Body
+	if len(body) > 64<<10 {
+		body = body[:64<<10]
+	}
+	lower := strings.ToLower(string(body))
+	if strings.Contains(lower, "<title>just a moment...</title>") || strings.Contains(lower, "<title>attention required! | cloudflare</title>") {
+		return true
+	}
+	switch page.Status {
+	case http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		for _, marker := range []string{"cf-chl", "challenge-platform", "cf_chl_opt", "cf-browser-verification"} {
+			if strings.Contains(lower, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// EscalatingFetcher fetches pages over plain HTTP, and retries the challenge pages through the site's solver
+// (RequestOptions.Solver) or else in Browser. The clearance cookies are saved to the shared cookie jar, so the plain
+// HTTP requests that follow (eg: the page images) pass too.
+type EscalatingFetcher struct {
+	HTTP Fetcher
+	// Browser is nil when no browser is available, the challenge error is then returned
+	Browser Fetcher
+}
+
+func (f EscalatingFetcher) Fetch(pageURL string) (*Page, error) {
+	page, err := f.HTTP.Fetch(pageURL)
+	if !errors.Is(err, ErrChallenge) || Offline() {
+		return page, err
+	}
+
+	if u, parseErr := url.Parse(pageURL); parseErr == nil {
+		if opts := requestOptions(u); opts.Solver != "" {
+			slog.Info("challenge page, retrying through the solver", "url", pageURL, "solver", opts.Solver)
+			site := opts.Site
+			if site == "" {
+				site = u.Hostname()
+			}
+			return siteSolver(opts.Solver, site).Fetch(pageURL)
+		}
+	}
+	if f.Browser == nil {
+		return page, err
+	}
+
+	slog.Info("challenge page, retrying in the browser", "url", pageURL)
+	page, browserErr := f.Browser.Fetch(pageURL)
+	if browserErr != nil {
+		return nil, errors.Join(err, fmt.Errorf("browser: %w", browserErr))
+	}
+	return page, nil
+}
+
+var (
+	fetchMu        sync.Mutex
+	browserFetcher Fetcher
+)
+
+// ConfigureBrowserFetcher sets the browser the shared fetchers escalate to on challenge pages, nil to not escalate
+func ConfigureBrowserFetcher(f Fetcher) {
+	fetchMu.Lock()
+	defer fetchMu.Unlock()
+
+	browserFetcher = f
+}
+
+// NewFetcher returns a fetcher sending the site's header profile over plain HTTP, escalating to the configured
+// browser on challenge pages
+func NewFetcher() Fetcher {
+	fetchMu.Lock()
+	defer fetchMu.Unlock()
+
+	return EscalatingFetcher{HTTP: HTTPFetcher{}, Browser: browserFetcher}
+}
+
+var (
+	agentMu       sync.Mutex
+	browserAgents = map[string]string{}
+)
+
+// UseBrowserAgent makes the requests to host and its subdomains send the browser's User-Agent, once the browser has
+// passed a challenge there: the clearance cookies are only accepted with the User-Agent they were issued to
+func UseBrowserAgent(host, userAgent string) {
+	agentMu.Lock()
+	defer agentMu.Unlock()
+
+	browserAgents[strings.TrimPrefix(strings.ToLower(host), "www.")] = userAgent
+}
+
+// browserAgent returns the User-Agent set with UseBrowserAgent for host or a parent domain, empty when there is none
+func browserAgent(host string) string {
+	agentMu.Lock()
+	defer agentMu.Unlock()
+
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	for domain, agent := range browserAgents {
+		if domainMatch(host, domain) {
+			return agent
+		}
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+package webClient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const challengeHTML = `<!DOCTYPE html><html><head><title>Just a moment...</title></head>
+<body><script src="/cdn-cgi/challenge-platform/h/g/orchestrate/chl_page/v1"></script></body></html>`
+
+func TestIsChallenge(t *testing.T) {
+	tests := []struct {
+		name string
+		page Page
+		want bool
+	}{
+		{"interstitial", Page{Status: http.StatusForbidden, Body: []byte(challengeHTML)}, true},
+		{"cf-mitigated", Page{Status: http.StatusForbidden, Header: http.Header{"Cf-Mitigated": {"challenge"}}}, true},
+		{"503 challenge script", Page{Status: http.StatusServiceUnavailable, Body: []byte(`<script>window._cf_chl_opt={}</script>`)}, true},
+		{"plain 403", Page{Status: http.StatusForbidden, Body: []byte("<html><title>Forbidden</title></html>")}, false},
+		{"page mentioning the script", Page{Status: http.StatusOK, Body: []byte("<p>cf-chl</p>")}, false},
+	}
+	for _, tt := range tests {
+		if got := IsChallenge(&tt.page); got != tt.want {
+			t.Errorf("%s: IsChallenge() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// stubBrowser passes the challenge of the test server like a browser would: it gets the clearance cookie and the
+// host's requests send its User-Agent from then on
+type stubBrowser struct {
+	fetched []string
+}
+
+func (b *stubBrowser) Fetch(pageURL string) (*Page, error) {
+	b.fetched = append(b.fetched, pageURL)
+	u, _ := url.Parse(pageURL)
+	cookieJar.Add(&http.Cookie{Name: "cf_clearance", Value: "cleared", Domain: u.Hostname()})
+	UseBrowserAgent(u.Hostname(), "stub-browser")
+	return &Page{URL: pageURL, Status: http.StatusOK, Body: []byte("<html><body>from the browser</body></html>")}, nil
+}
+
+func TestEscalatingFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("cf_clearance"); err != nil || c.Value != "cleared" || r.UserAgent() != "stub-browser" {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, challengeHTML)
+			return
+		}
+		io.WriteString(w, "<html><body>"+r.URL.Path+"</body></html>")
+	}))
+	defer server.Close()
+
+	saved := cookieJar
+	cookieJar = NewCookieJar()
+	defer func() {
+		cookieJar = saved
+		agentMu.Lock()
+		clear(browserAgents)
+		agentMu.Unlock()
+	}()
+
+	// without a browser the challenge is an error
+	if _, err := (EscalatingFetcher{HTTP: HTTPFetcher{}}).Fetch(server.URL + "/series"); !errors.Is(err, ErrChallenge) {
+		t.Fatalf("Fetch() error = %v, want ErrChallenge", err)
+	}
+
+	browser := &stubBrowser{}
+	fetcher := EscalatingFetcher{HTTP: HTTPFetcher{}, Browser: browser}
+	page, err := fetcher.Fetch(server.URL + "/series")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page.Body), "from the browser") || len(browser.fetched) != 1 {
+		t.Errorf("Fetch() = %q after %d browser fetches, want the browser's page", page.Body, len(browser.fetched))
+	}
+
+	// the clearance is reused by the plain HTTP requests that follow
+	page, err = fetcher.Fetch(server.URL + "/chapter-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page.Body), "/chapter-1") || len(browser.fetched) != 1 {
+		t.Errorf("Fetch() = %q, want the page over plain HTTP without the browser", page.Body)
+	}
+	resp, err := NewHTTPClient().Get(server.URL + "/images/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("image request status %d, want 200 with the clearance cookie", resp.StatusCode)
+	}
+}
@@ -0,0 +1,174 @@
+package webClient
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gocolly/colly"
+)
+
+// RefererPolicy is what the Referer header of a request is set to, from the page the request is made from
+type RefererPolicy string
+
+const (
+	// RefererOrigin sends the origin of the page, eg: https://kunmanga.com/
+	RefererOrigin RefererPolicy = "origin"
+	// RefererPage sends the page URL, eg: the chapter page for its images
+	RefererPage RefererPolicy = "page"
+	// RefererNone sends no Referer
+	RefererNone RefererPolicy = "none"
+)
+
+// RequestKind is what a request fetches, it picks the Accept header of the profile
+type RequestKind int
+
+const (
+	PageRequest RequestKind = iota
+	ImageRequest
+)
+
+// HeaderProfile are the headers a site's pages and images are requested with. The fields left empty in a site's
+// profile are taken from DefaultProfile.
+type HeaderProfile struct {
+	UserAgent string
+	// Accept is sent with the page requests, ImageAccept with the image requests
+	Accept         string
+	ImageAccept    string
+	AcceptLanguage string
+	Referer        RefererPolicy
+	// Headers are extra headers sent with every request
+	Headers map[string]string
+}
+
+// DefaultProfile is the header profile of a browser, used for the sites without their own and the hosts that are not
+// a site's
+var DefaultProfile = HeaderProfile{
+	UserAgent:      DefaultUserAgent,
+	Accept:         "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+	ImageAccept:    "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8",
+	AcceptLanguage: "en-US,en;q=0.9",
+	Referer:        RefererOrigin,
+}
+
+// withDefaults returns p with its empty fields taken from base, headers are merged by name
+func (p HeaderProfile) withDefaults(base HeaderProfile) HeaderProfile {
+	if p.UserAgent == "" {
+		p.UserAgent = base.UserAgent
+	}
+	if p.Accept == "" {
+		p.Accept = base.Accept
+	}
+	if p.ImageAccept == "" {
+		p.ImageAccept = base.ImageAccept
+	}
+	if p.AcceptLanguage == "" {
+		p.AcceptLanguage = base.AcceptLanguage
+	}
+	if p.Referer == "" {
+		p.Referer = base.Referer
+	}
+	headers := make(map[string]string, len(base.Headers)+len(p.Headers))
+	for name, value := range base.Headers {
+		headers[name] = value
+	}
+	for name, value := range p.Headers {
+		headers[name] = value
+	}
+	p.Headers = headers
+	return p
+}
+
+// referer returns the Referer of a request made from page under the policy, empty for none
+func (p HeaderProfile) referer(page *url.URL) string {
+	if page == nil || page.Host == "" {
+		return ""
+	}
+	switch p.Referer {
+	case RefererPage:
+		return page.String()
+	case RefererOrigin:
+		return page.Scheme + "://" + page.Host + "/"
+	}
+	return ""
+}
+
+// siteProfile returns the header profile of the site of u, with the configured User-Agent and headers applied over it
+func siteProfile(u *url.URL) HeaderProfile {
+	opts := requestOptions(u)
+	p := opts.Profile.withDefaults(DefaultProfile)
+	if opts.UserAgent != "" {
+		p.UserAgent = opts.UserAgent
+	}
+	for name, value := range opts.Headers {
+		p.Headers[name] = value
+	}
+	return p
+}
+
+// setHeaders sets the headers of a kind request for target made from page (nil for a page opened directly). The
+// profile is the one of the page's site, so the images on a CDN are requested the way the site's pages are.
+func setHeaders(h http.Header, kind RequestKind, target, page *url.URL) {
+	site := target
+	if page != nil && page.Host != "" {
+		site = page
+	}
+	p := siteProfile(site)
+
+	h.Set("User-Agent", p.UserAgent)
+	if kind == ImageRequest {
+		h.Set("Accept", p.ImageAccept)
+	} else {
+		h.Set("Accept", p.Accept)
+	}
+	h.Set("Accept-Language", p.AcceptLanguage)
+	if referer := p.referer(page); referer != "" {
+		h.Set("Referer", referer)
+	} else {
+		h.Del("Referer")
+	}
+	for name, value := range p.Headers {
+		h.Set(name, value)
+	}
+}
+
+// NewRequest returns a GET request for target with the headers of its site's profile, see HeaderProfile. page is
+// the URL of the page target is loaded from, which the Referer is set from; empty for a page opened directly.
+func NewRequest(kind RequestKind, target, page string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var pageURL *url.URL
+	if page != "" {
+		if pageURL, err = url.Parse(page); err != nil {
+			return nil, err
+		}
+	}
+	setHeaders(req.Header, kind, req.URL, pageURL)
+	return req, nil
+}
+
+// NewImageRequest returns a GET request for the image at imageURL, shown on page (eg: the chapter page)
+func NewImageRequest(imageURL, page string) (*http.Request, error) {
+	return NewRequest(ImageRequest, imageURL, page)
+}
+
+// GetImage fetches the image at imageURL shown on page, through the shared transport and cookie jar
+func GetImage(imageURL, page string) (*http.Response, error) {
+	req, err := NewImageRequest(imageURL, page)
+	if err != nil {
+		return nil, err
+	}
+	return NewHTTPClient().Do(req)
+}
+
+// profileHeaders sets the headers of the site's profile on the requests of a collector. A Referer set on the request
+// is taken as the page it is made from.
+func profileHeaders(r *colly.Request) {
+	var page *url.URL
+	if referer := r.Headers.Get("Referer"); referer != "" {
+		page, _ = url.Parse(referer)
+	}
+	setHeaders(*r.Headers, PageRequest, r.URL, page)
+}
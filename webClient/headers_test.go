@@ -0,0 +1,100 @@
+package webClient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewRequestProfile(t *testing.T) {
+	ConfigureRequests(func(host string) RequestOptions {
+		switch host {
+		case "manga.test":
+			return RequestOptions{Site: "manga", Profile: HeaderProfile{
+				Referer: RefererPage,
+				Headers: map[string]string{"Sec-Fetch-Site": "same-origin"},
+			}}
+		case "plain.test":
+			return RequestOptions{Site: "plain", UserAgent: "configured-agent", Headers: map[string]string{"Accept-Language": "fr"}}
+		}
+		return RequestOptions{}
+	})
+	defer ConfigureRequests(nil)
+
+	tests := []struct {
+		name         string
+		kind         RequestKind
+		target, page string
+		want         map[string]string
+	}{
+		{"page opened directly", PageRequest, "https://manga.test/series/a", "", map[string]string{
+			"User-Agent": DefaultUserAgent, "Accept": DefaultProfile.Accept, "Accept-Language": DefaultProfile.AcceptLanguage,
+			"Referer": "", "Sec-Fetch-Site": "same-origin",
+		}},
+		{"image on a CDN gets the site's profile", ImageRequest, "https://cdn.test/1.jpg", "https://manga.test/series/a/chapter-1/", map[string]string{
+			"Accept": DefaultProfile.ImageAccept, "Referer": "https://manga.test/series/a/chapter-1/", "Sec-Fetch-Site": "same-origin",
+		}},
+		{"default origin policy", ImageRequest, "https://cdn.test/1.jpg", "https://other.test/chapter/1", map[string]string{
+			"User-Agent": DefaultUserAgent, "Referer": "https://other.test/", "Sec-Fetch-Site": "",
+		}},
+		{"configured agent and headers", ImageRequest, "https://cdn.test/1.jpg", "https://plain.test/chapter/1", map[string]string{
+			"User-Agent": "configured-agent", "Accept-Language": "fr", "Referer": "https://plain.test/",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := NewRequest(tt.kind, tt.target, tt.page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got := req.Header.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRefererNone(t *testing.T) {
+	ConfigureRequests(func(host string) RequestOptions {
+		return RequestOptions{Site: "manga", Profile: HeaderProfile{Referer: RefererNone}}
+	})
+	defer ConfigureRequests(nil)
+
+	req, err := NewImageRequest("https://cdn.test/1.jpg", "https://manga.test/chapter/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if referer := req.Header.Get("Referer"); referer != "" {
+		t.Errorf("Referer = %q, want none", referer)
+	}
+}
+
+func TestCollectorProfile(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	ConfigureRequests(func(host string) RequestOptions {
+		return RequestOptions{Site: "manga", Profile: HeaderProfile{UserAgent: "site-agent", AcceptLanguage: "ko"}}
+	})
+	defer ConfigureRequests(nil)
+
+	if _, err := (HTTPFetcher{}).Fetch(server.URL + "/series/a"); err != nil {
+		t.Fatal(err)
+	}
+	if ua := got.Get("User-Agent"); ua != "site-agent" {
+		t.Errorf("User-Agent = %q, want the profile's, not colly's", ua)
+	}
+	if lang := got.Get("Accept-Language"); lang != "ko" {
+		t.Errorf("Accept-Language = %q, want ko", lang)
+	}
+	if accept := got.Get("Accept"); !strings.HasPrefix(accept, "text/html") {
+		t.Errorf("Accept = %q, want the page Accept", accept)
+	}
+}
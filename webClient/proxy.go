@@ -0,0 +1,191 @@
+package webClient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A proxy that fails proxyMaxFailures requests in a row is skipped for proxyCooldown
+const (
+	proxyMaxFailures = 3
+	proxyCooldown    = time.Minute
+)
+
+// proxyPool rotates the requests of a site over its proxies, skipping the unhealthy ones
+type proxyPool struct {
+	mu      sync.Mutex
+	proxies []*proxyState
+	next    int
+}
+
+// proxyState is the health of a proxy of a pool
+type proxyState struct {
+	url string
+	// failures is the number of requests in a row that failed
+	failures  int
+	downUntil time.Time
+}
+
+func newProxyPool(proxies []string) *proxyPool {
+	p := &proxyPool{}
+	for _, u := range proxies {
+		p.proxies = append(p.proxies, &proxyState{url: u})
+	}
+	return p
+}
+
+// pick returns the next healthy proxy in turn. When every proxy is down, the one that comes back first is used.
+func (p *proxyPool) pick(now time.Time) *proxyState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var soonest *proxyState
+	for i := range p.proxies {
+		s := p.proxies[(p.next+i)%len(p.proxies)]
+		if !s.downUntil.After(now) {
+			p.next = (p.next + i + 1) % len(p.proxies)
+			return s
+		}
+		if soonest == nil || s.downUntil.Before(soonest.downUntil) {
+			soonest = s
+		}
+	}
+	return soonest
+}
+
+// report records the outcome of a request through s
+func (p *proxyPool) report(s *proxyState, err error, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err == nil {
+		s.failures, s.downUntil = 0, time.Time{}
+		return
+	}
+	s.failures++
+	if s.failures >= proxyMaxFailures {
+		s.downUntil = now.Add(proxyCooldown)
+		slog.Warn("proxy unhealthy, skipped for a while", "proxy", redactProxy(s.url), "failures", s.failures, "until", s.downUntil.Format(time.TimeOnly), "error", err)
+	}
+}
+
+// sitePool returns the proxy pool of a site, created from proxies on its first request. nil without proxies.
+func sitePool(key string, proxies []string) *proxyPool {
+	if len(proxies) == 0 {
+		return nil
+	}
+
+	requestMu.Lock()
+	defer requestMu.Unlock()
+
+	key += " " + strings.Join(proxies, ",")
+	p, ok := pools[key]
+	if !ok {
+		p = newProxyPool(proxies)
+		pools[key] = p
+	}
+	return p
+}
+
+// redactProxy hides the password of a proxy URL for the logs
+func redactProxy(proxy string) string {
+	if u, err := url.Parse(proxy); err == nil {
+		return u.Redacted()
+	}
+	return proxy
+}
+
+// transportSettings are what a clone of the base transport is configured with
+type transportSettings struct {
+	proxy         string
+	caBundle      string
+	minTLSVersion uint16
+}
+
+// wrappingTransport is a round tripper around another one, eg: the --record recorder. The proxy and TLS settings are
+// applied to the transport it wraps, and Via puts the wrapper back on top of the configured copy.
+type wrappingTransport interface {
+	Unwrap() http.RoundTripper
+	Via(next http.RoundTripper) http.RoundTripper
+}
+
+// configuredTransport returns a copy of base sending its requests through the proxy with the TLS settings, one per
+// distinct settings. base itself when there is nothing to configure.
+func configuredTransport(base http.RoundTripper, settings transportSettings) (http.RoundTripper, error) {
+	if settings == (transportSettings{}) {
+		return base, nil
+	}
+	if w, ok := base.(wrappingTransport); ok {
+		next, err := configuredTransport(w.Unwrap(), settings)
+		if err != nil {
+			return nil, err
+		}
+		return w.Via(next), nil
+	}
+
+	requestMu.Lock()
+	defer requestMu.Unlock()
+
+	if t, ok := transports[settings]; ok {
+		return t, nil
+	}
+
+	transport, ok := base.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("the HTTP transport does not support proxy and TLS settings")
+	}
+	transport = transport.Clone()
+
+	if settings.proxy != "" {
+		u, err := url.Parse(settings.proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", redactProxy(settings.proxy))
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	if settings.caBundle != "" || settings.minTLSVersion != 0 {
+		tlsConfig := &tls.Config{}
+		if transport.TLSClientConfig != nil {
+			tlsConfig = transport.TLSClientConfig.Clone()
+		}
+		if settings.caBundle != "" {
+			pool, err := certPool(settings.caBundle)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if settings.minTLSVersion != 0 {
+			tlsConfig.MinVersion = settings.minTLSVersion
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	transports[settings] = transport
+	return transport, nil
+}
+
+// certPool returns the system certificate authorities with the ones of the PEM file at path
+func certPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("CA bundle %s holds no PEM certificate", path)
+	}
+	return pool, nil
+}
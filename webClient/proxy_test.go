@@ -0,0 +1,171 @@
+package webClient
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"scrape/replay"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestProxyPool(t *testing.T) {
+	p := newProxyPool([]string{"http://a:1", "http://b:1", "http://c:1"})
+	now := time.Now()
+
+	var got []string
+	for range 4 {
+		got = append(got, p.pick(now).url)
+	}
+	if want := []string{"http://a:1", "http://b:1", "http://c:1", "http://a:1"}; !slices.Equal(got, want) {
+		t.Errorf("picks = %v, want the proxies in turn %v", got, want)
+	}
+
+	// b fails proxyMaxFailures times and is skipped until the cooldown ends
+	b := p.proxies[1]
+	for range proxyMaxFailures {
+		p.report(b, errors.New("connection refused"), now)
+	}
+	for range 4 {
+		if s := p.pick(now); s == b {
+			t.Fatal("an unhealthy proxy was picked")
+		}
+	}
+	later := now.Add(proxyCooldown + time.Second)
+	picked := false
+	for range 3 {
+		picked = picked || p.pick(later) == b
+	}
+	if !picked {
+		t.Error("the proxy was not used again after the cooldown")
+	}
+
+	// a success resets the failures
+	p.report(b, nil, later)
+	p.report(b, errors.New("timeout"), later)
+	if b.failures != 1 || !b.downUntil.IsZero() {
+		t.Errorf("failures = %d, down until %v; want 1 and not down", b.failures, b.downUntil)
+	}
+
+	// with every proxy down the one back first is used
+	for _, s := range p.proxies {
+		s.downUntil = now.Add(time.Hour)
+	}
+	p.proxies[2].downUntil = now.Add(time.Minute)
+	if s := p.pick(now); s != p.proxies[2] {
+		t.Errorf("picked %s, want the proxy back first", s.url)
+	}
+}
+
+func TestSiteTransportProxies(t *testing.T) {
+	// a plain HTTP proxy gets the absolute URL of the request
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host != "manga.test" {
+			http.Error(w, "unexpected host "+r.URL.Host, http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	ConfigureRequests(func(host string) RequestOptions {
+		return RequestOptions{Site: "testsite", Proxies: []string{down.URL, proxy.URL}}
+	})
+	defer ConfigureRequests(nil)
+
+	client := &http.Client{Transport: &siteTransport{next: http.DefaultTransport}}
+	for range 2 {
+		resp, err := client.Get("http://manga.test/series/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "via proxy" {
+			t.Errorf("body = %q, want the proxy's response", body)
+		}
+	}
+}
+
+func TestSiteTransportProxyRecord(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	ConfigureRequests(func(host string) RequestOptions {
+		return RequestOptions{Site: "testsite", Proxies: []string{proxy.URL}}
+	})
+	defer ConfigureRequests(nil)
+
+	// --record wraps the default transport, the proxy is applied below the recorder
+	dir := t.TempDir()
+	recorder, err := replay.NewRecorder(dir, http.DefaultTransport, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &siteTransport{next: recorder}}
+	resp, err := client.Get("http://manga.test/series/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "via proxy" {
+		t.Errorf("body = %q, want the proxy's response", body)
+	}
+
+	exchanges, err := replay.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exchanges) != 1 || exchanges[0].URL != "http://manga.test/series/a" {
+		t.Errorf("recorded %+v, want the proxied response", exchanges)
+	}
+}
+
+func TestSiteTransportTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(opts RequestOptions) error {
+		ConfigureRequests(func(host string) RequestOptions { return opts })
+		client := &http.Client{Transport: &siteTransport{next: http.DefaultTransport}}
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	defer ConfigureRequests(nil)
+
+	if err := get(RequestOptions{Site: "testsite"}); err == nil {
+		t.Error("the test server's certificate was trusted without the CA bundle")
+	}
+	if err := get(RequestOptions{Site: "testsite", CABundle: bundle}); err != nil {
+		t.Errorf("request with the CA bundle: %v", err)
+	}
+	if err := get(RequestOptions{Site: "testsite", CABundle: bundle, MinTLSVersion: tls.VersionTLS13}); err != nil {
+		t.Errorf("request with TLS 1.3 minimum: %v", err)
+	}
+	if err := get(RequestOptions{Site: "testsite", CABundle: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("a missing CA bundle was not reported")
+	}
+}
@@ -0,0 +1,245 @@
+package webClient
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds the requests of the sites with no timeout configured
+const DefaultTimeout = 60 * time.Second
+
+// RequestOptions are the per site options applied to every request the scrapers send
+type RequestOptions struct {
+	// Site is the name of the site the request is for, the rate limit and concurrency are shared by its hosts. Empty
+	// for hosts that do not belong to a site, they are limited per host.
+	Site string
+	// Profile is the header profile of the site, see NewRequest
+	Profile HeaderProfile
+	// UserAgent replaces the User-Agent header when set
+	UserAgent string
+	// Headers are set on the request, replacing the headers of the same name
+	Headers map[string]string
+	// Cookies are added to the request, unless it already has a cookie of the same name. The cookies of the shared
+	// cookie jar are added after them, see Jar.
+	Cookies map[string]string
+	// Proxies are the URLs of the proxies the requests rotate over (http, https, socks5), a proxy that keeps failing is
+	// skipped for a while
+	Proxies []string
+	// CABundle is a PEM file of certificate authorities trusted besides the system ones
+	CABundle string
+	// MinTLSVersion is the lowest TLS version accepted, eg: tls.VersionTLS12. The Go default when 0.
+	MinTLSVersion uint16
+	// Timeout bounds the request including reading its body, DefaultTimeout when 0
+	Timeout time.Duration
+	// RateLimit is the minimum delay between the start of two requests to the site
+	RateLimit time.Duration
+	// Concurrency is the maximum number of requests in flight to the site, 0 is no limit
+	Concurrency int
+	// Solver is the API URL of the FlareSolverr compatible solver the site's challenge pages are fetched through,
+	// instead of the browser
+	Solver string
+	// LoginURL is the login page of the site when the run logs in to it. A redirect to it or a 401 / 403 response
+	// marks the site's session expired, see SessionExpired.
+	LoginURL string
+}
+
+var (
+	requestMu     sync.Mutex
+	requestLookup func(host string) RequestOptions
+	limiters      = map[string]*limiter{}
+	pools         = map[string]*proxyPool{}
+	transports    = map[transportSettings]http.RoundTripper{}
+)
+
+// ConfigureRequests sets the lookup of the request options by hostname, for the collectors and clients created after
+// it is called
+func ConfigureRequests(lookup func(host string) RequestOptions) {
+	requestMu.Lock()
+	defer requestMu.Unlock()
+
+	requestLookup = lookup
+	limiters = map[string]*limiter{}
+	pools = map[string]*proxyPool{}
+	transports = map[transportSettings]http.RoundTripper{}
+}
+
+// requestOptions returns the options for the host of u
+func requestOptions(u *url.URL) RequestOptions {
+	requestMu.Lock()
+	lookup := requestLookup
+	requestMu.Unlock()
+
+	if lookup == nil {
+		return RequestOptions{}
+	}
+	return lookup(strings.ToLower(u.Hostname()))
+}
+
+// siteTransport applies the RequestOptions of the request's site before handing it to next
+type siteTransport struct {
+	next http.RoundTripper
+}
+
+func (t *siteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	opts := requestOptions(req.URL)
+
+	key := opts.Site
+	if key == "" {
+		key = req.URL.Hostname()
+	}
+	release, err := siteLimiter(key, opts).wait(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+
+	// the caller's request is left untouched
+	out := req.Clone(ctx)
+	if opts.UserAgent != "" {
+		out.Header.Set("User-Agent", opts.UserAgent)
+	}
+	for name, value := range opts.Headers {
+		out.Header.Set(name, value)
+	}
+	if agent := browserAgent(req.URL.Hostname()); agent != "" {
+		out.Header.Set("User-Agent", agent)
+	}
+	for name, value := range opts.Cookies {
+		if _, err := out.Cookie(name); err == http.ErrNoCookie {
+			out.AddCookie(&http.Cookie{Name: name, Value: value})
+		}
+	}
+	for _, c := range cookieJar.Cookies(req.URL) {
+		if _, err := out.Cookie(c.Name); err == http.ErrNoCookie {
+			out.AddCookie(c)
+		}
+	}
+
+	resp, err := t.send(out, key, opts)
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+	if cookies := resp.Cookies(); len(cookies) > 0 {
+		cookieJar.SetCookies(req.URL, cookies)
+	}
+	if opts.Site != "" && opts.LoginURL != "" && loggedOut(resp, opts.LoginURL) {
+		markSessionExpired(opts.Site, req.URL.String(), resp.StatusCode)
+	}
+	// the timeout and the concurrency slot last until the body is closed
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: func() { cancel(); release() }}
+	return resp, nil
+}
+
+// send sends req with the site's proxy and TLS settings. A GET or HEAD that fails to go through a proxy is tried
+// through the next one, until every proxy of the site was tried.
+func (t *siteTransport) send(req *http.Request, key string, opts RequestOptions) (*http.Response, error) {
+	settings := transportSettings{caBundle: opts.CABundle, minTLSVersion: opts.MinTLSVersion}
+	pool := sitePool(key, opts.Proxies)
+	attempts := 1
+	if pool != nil && (req.Method == http.MethodGet || req.Method == http.MethodHead) {
+		attempts = len(opts.Proxies)
+	}
+
+	for attempt := 1; ; attempt++ {
+		var proxy *proxyState
+		if pool != nil {
+			proxy = pool.pick(time.Now())
+			settings.proxy = proxy.url
+		}
+		next, err := configuredTransport(t.next, settings)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := next.RoundTrip(req)
+		if proxy != nil {
+			pool.report(proxy, err, time.Now())
+		}
+		if err == nil || attempt >= attempts || req.Context().Err() != nil {
+			return resp, err
+		}
+		slog.Warn("request through the proxy failed, trying the next proxy", "url", req.URL, "proxy", redactProxy(proxy.url), "error", err)
+	}
+}
+
+// releaseBody calls release once, when the response body is closed
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// limiter spaces the requests to a site by its rate limit and bounds how many are in flight
+type limiter struct {
+	mu    sync.Mutex
+	next  time.Time
+	delay time.Duration
+	slots chan struct{}
+}
+
+// siteLimiter returns the limiter of a site, created from opts on its first request
+func siteLimiter(key string, opts RequestOptions) *limiter {
+	requestMu.Lock()
+	defer requestMu.Unlock()
+
+	l, ok := limiters[key]
+	if !ok {
+		l = &limiter{delay: opts.RateLimit}
+		if opts.Concurrency > 0 {
+			l.slots = make(chan struct{}, opts.Concurrency)
+		}
+		limiters[key] = l
+	}
+	return l
+}
+
+// wait blocks until the request may start, the returned func frees its concurrency slot
+func (l *limiter) wait(ctx context.Context) (func(), error) {
+	release := func() {}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+			release = func() { <-l.slots }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if l.delay > 0 {
+		l.mu.Lock()
+		now := time.Now()
+		start := l.next
+		if start.Before(now) {
+			start = now
+		}
+		l.next = start.Add(l.delay)
+		l.mu.Unlock()
+
+		select {
+		case <-time.After(time.Until(start)):
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
@@ -0,0 +1,122 @@
+package webClient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSiteTransport(t *testing.T) {
+	var mu sync.Mutex
+	var got []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r)
+		mu.Unlock()
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	ConfigureRequests(func(host string) RequestOptions {
+		return RequestOptions{
+			Site:      "testsite",
+			UserAgent: "configured-agent",
+			Headers:   map[string]string{"Accept-Language": "en"},
+			Cookies:   map[string]string{"session": "abc", "theme": "dark"},
+			RateLimit: 50 * time.Millisecond,
+		}
+	})
+	defer ConfigureRequests(nil)
+
+	client := &http.Client{Transport: &siteTransport{next: http.DefaultTransport}}
+	start := time.Now()
+	for range 3 {
+		req, _ := http.NewRequest("GET", server.URL+"/series/a", nil)
+		req.Header.Set("User-Agent", "site-agent")
+		req.AddCookie(&http.Cookie{Name: "theme", Value: "light"})
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if req.Header.Get("User-Agent") != "site-agent" {
+			t.Error("the caller's request was modified")
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 requests took %v, want at least 2 rate limit delays of 50ms", elapsed)
+	}
+	r := got[0]
+	if ua := r.UserAgent(); ua != "configured-agent" {
+		t.Errorf("User-Agent = %q, want the configured agent", ua)
+	}
+	if lang := r.Header.Get("Accept-Language"); lang != "en" {
+		t.Errorf("Accept-Language = %q, want en", lang)
+	}
+	if c, err := r.Cookie("session"); err != nil || c.Value != "abc" {
+		t.Errorf("session cookie = %v, %v; want abc", c, err)
+	}
+	if c, err := r.Cookie("theme"); err != nil || c.Value != "light" {
+		t.Errorf("theme cookie = %v, %v; want the request's own light", c, err)
+	}
+}
+
+func TestSiteTransportConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	ConfigureRequests(func(host string) RequestOptions { return RequestOptions{Site: "testsite", Concurrency: 2} })
+	defer ConfigureRequests(nil)
+
+	client := &http.Client{Transport: &siteTransport{next: http.DefaultTransport}}
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if maxInFlight != 2 {
+		t.Errorf("%d requests in flight at once, want the concurrency limit 2", maxInFlight)
+	}
+}
+
+func TestSiteTransportTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	ConfigureRequests(func(host string) RequestOptions { return RequestOptions{Timeout: 20 * time.Millisecond} })
+	defer ConfigureRequests(nil)
+
+	client := &http.Client{Transport: &siteTransport{next: http.DefaultTransport}}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("request outlived the configured timeout")
+	}
+}
@@ -0,0 +1,78 @@
+package webClient
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	sessionMu sync.Mutex
+	// expiredSessions are the sites whose login session a response showed to have expired
+	expiredSessions = map[string]bool{}
+)
+
+// SessionExpired reports whether a response from the site has shown its login session expired since the last
+// ResetSession: a 401 or 403 that is not a challenge page, or a redirect to the site's login page
+// (RequestOptions.LoginURL)
+func SessionExpired(site string) bool {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	return expiredSessions[site]
+}
+
+// ResetSession clears the expired session of the site, once it is logged in again
+func ResetSession(site string) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	delete(expiredSessions, site)
+}
+
+func markSessionExpired(site, requestURL string, status int) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	if !expiredSessions[site] {
+		slog.Info("login session expired", "site", site, "url", requestURL, "status", status)
+	}
+	expiredSessions[site] = true
+}
+
+// loggedOut reports whether resp shows the request was not logged in: a 401 or 403, or a redirect to loginURL. A
+// challenge page is left to the fetcher's escalation, logging in again does not get past it.
+func loggedOut(resp *http.Response, loginURL string) bool {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return !challengeResponse(resp)
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		return false
+	}
+	login, err := url.Parse(loginURL)
+	if err != nil {
+		return false
+	}
+	return strings.TrimPrefix(location.Hostname(), "www.") == strings.TrimPrefix(login.Hostname(), "www.") &&
+		strings.TrimSuffix(location.Path, "/") == strings.TrimSuffix(login.Path, "/")
+}
+
+// challengeResponse reports whether resp is a challenge page, see IsChallenge. The start of the body is read to tell,
+// resp.Body still returns the whole body.
+func challengeResponse(resp *http.Response) bool {
+	start, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(start), resp.Body), resp.Body}
+	if err != nil {
+		return false
+	}
+	return IsChallenge(&Page{Status: resp.StatusCode, Header: resp.Header, Body: start})
+}
@@ -0,0 +1,55 @@
+package webClient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSessionExpired(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/challenge":
+			w.Header().Set("Cf-Mitigated", "challenge")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("<html><title>Just a moment...</title></html>"))
+		case "/members":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("members only"))
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	ConfigureRequests(func(host string) RequestOptions {
+		return RequestOptions{Site: "testsite", LoginURL: server.URL + "/login"}
+	})
+	defer ConfigureRequests(nil)
+	defer ResetSession("testsite")
+
+	client := &http.Client{Transport: &siteTransport{next: http.DefaultTransport}}
+	get := func(path string) string {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	// the challenge page is escalated, not taken for a logged out session
+	if body := get("/challenge"); body != "<html><title>Just a moment...</title></html>" {
+		t.Errorf("challenge body = %q, want it whole", body)
+	}
+	if SessionExpired("testsite") {
+		t.Error("a challenge page marked the session expired")
+	}
+
+	get("/members")
+	if !SessionExpired("testsite") {
+		t.Error("a 403 did not mark the session expired")
+	}
+}
@@ -0,0 +1,177 @@
+package webClient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSolverTimeout bounds how long the solver works on a challenge
+const DefaultSolverTimeout = 60 * time.Second
+
+// Solver fetches pages through a FlareSolverr compatible challenge solver service, for the hosts whose challenge the
+// browser does not pass. The cookies and User-Agent of the solved page are kept for the plain HTTP requests that
+// follow, like a page cleared in the browser.
+type Solver struct {
+	// Endpoint is the solver's API URL, eg: http://localhost:8191/v1
+	Endpoint string
+	// Session is the solver session the requests reuse, so the challenge is solved once. None when empty.
+	Session string
+	// Timeout bounds the solving of a challenge, DefaultSolverTimeout when 0
+	Timeout time.Duration
+}
+
+// solverRequest is a command of the solver API
+type solverRequest struct {
+	Cmd        string `json:"cmd"`
+	URL        string `json:"url,omitempty"`
+	Session    string `json:"session,omitempty"`
+	MaxTimeout int64  `json:"maxTimeout,omitempty"`
+}
+
+type solverResponse struct {
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	Solution *struct {
+		URL       string            `json:"url"`
+		Status    int               `json:"status"`
+		Headers   map[string]string `json:"headers"`
+		Response  string            `json:"response"`
+		UserAgent string            `json:"userAgent"`
+		Cookies   []struct {
+			Name     string  `json:"name"`
+			Value    string  `json:"value"`
+			Domain   string  `json:"domain"`
+			Path     string  `json:"path"`
+			Expires  float64 `json:"expires"`
+			HTTPOnly bool    `json:"httpOnly"`
+			Secure   bool    `json:"secure"`
+		} `json:"cookies"`
+	} `json:"solution"`
+}
+
+func (s *Solver) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return DefaultSolverTimeout
+}
+
+// call sends a command to the solver, its requests do not go through the site transport
+func (s *Solver) call(cmd solverRequest) (*solverResponse, error) {
+	body, err := json.Marshal(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: s.timeout() + 10*time.Second}
+	resp, err := client.Post(s.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("solver %s: %w", s.Endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	var out solverResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("solver %s: invalid response (HTTP %d): %w", s.Endpoint, resp.StatusCode, err)
+	}
+	if out.Status != "ok" {
+		return nil, fmt.Errorf("solver %s: %s failed: %s", s.Endpoint, cmd.Cmd, out.Message)
+	}
+	return &out, nil
+}
+
+// CreateSession starts the solver session of the requests
+func (s *Solver) CreateSession() error {
+	_, err := s.call(solverRequest{Cmd: "sessions.create", Session: s.Session})
+	return err
+}
+
+// DestroySession closes the solver session, the solver's browser is freed
+func (s *Solver) DestroySession() error {
+	_, err := s.call(solverRequest{Cmd: "sessions.destroy", Session: s.Session})
+	return err
+}
+
+// Fetch gets pageURL through the solver. The returned cookies are added to the shared cookie jar and the host's
+// requests send the solver's User-Agent from then on.
+func (s *Solver) Fetch(pageURL string) (*Page, error) {
+	out, err := s.call(solverRequest{Cmd: "request.get", URL: pageURL, Session: s.Session, MaxTimeout: s.timeout().Milliseconds()})
+	if err != nil {
+		return nil, err
+	}
+	solution := out.Solution
+	if solution == nil {
+		return nil, fmt.Errorf("solver %s: no solution for %s", s.Endpoint, pageURL)
+	}
+
+	for _, c := range solution.Cookies {
+		cookie := &http.Cookie{Name: c.Name, Value: c.Value, Domain: c.Domain, Path: c.Path, HttpOnly: c.HTTPOnly, Secure: c.Secure}
+		if c.Expires > 0 {
+			cookie.Expires = time.Unix(int64(c.Expires), 0)
+		}
+		cookieJar.Add(cookie)
+	}
+	if u, err := url.Parse(solution.URL); err == nil && solution.UserAgent != "" {
+		UseBrowserAgent(u.Hostname(), solution.UserAgent)
+	}
+
+	page := &Page{URL: solution.URL, Status: solution.Status, Header: make(http.Header), Body: []byte(solution.Response)}
+	for name, value := range solution.Headers {
+		page.Header.Set(name, value)
+	}
+	if IsChallenge(page) {
+		return page, fmt.Errorf("%w at %s: the solver did not pass it", ErrChallenge, pageURL)
+	}
+	if page.Status >= 400 {
+		return nil, fmt.Errorf("solver %s: HTTP %d for %s", s.Endpoint, page.Status, pageURL)
+	}
+	return page, nil
+}
+
+var (
+	solverMu sync.Mutex
+	// solvers are the solvers of the run by endpoint and site, each with its own session
+	solvers = map[string]*Solver{}
+)
+
+// siteSolver returns the solver of a site at endpoint, starting its session on first use. Without a session (the
+// solver failed to create one) every request solves the challenge again.
+func siteSolver(endpoint, site string) *Solver {
+	solverMu.Lock()
+	defer solverMu.Unlock()
+
+	key := endpoint + " " + site
+	if s, ok := solvers[key]; ok {
+		return s
+	}
+
+	s := &Solver{Endpoint: endpoint, Session: "scrape-" + strings.NewReplacer(".", "-", ":", "-").Replace(site)}
+	if err := s.CreateSession(); err != nil {
+		slog.Warn("solver session not created, every page is solved again", "solver", endpoint, "site", site, "error", err)
+		s.Session = ""
+	}
+	solvers[key] = s
+	return s
+}
+
+// CloseSolvers destroys the solver sessions of the run
+func CloseSolvers() {
+	solverMu.Lock()
+	defer solverMu.Unlock()
+
+	for key, s := range solvers {
+		if s.Session != "" {
+			if err := s.DestroySession(); err != nil {
+				slog.Warn("failed to destroy the solver session", "solver", s.Endpoint, "session", s.Session, "error", err)
+			}
+		}
+		delete(solvers, key)
+	}
+}
@@ -0,0 +1,136 @@
+package webClient
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubSolver is a FlareSolverr compatible API: it "solves" every page by returning the site's real page along with a
+// clearance cookie and its User-Agent
+type stubSolver struct {
+	mu       sync.Mutex
+	commands []solverRequest
+	fail     string
+}
+
+func (s *stubSolver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var cmd solverRequest
+	json.NewDecoder(r.Body).Decode(&cmd)
+	s.mu.Lock()
+	s.commands = append(s.commands, cmd)
+	fail := s.fail
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if fail != "" {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]any{"status": "error", "message": fail})
+		return
+	}
+	if cmd.Cmd != "request.get" {
+		json.NewEncoder(w).Encode(map[string]any{"status": "ok", "message": "", "session": cmd.Session})
+		return
+	}
+
+	u, _ := url.Parse(cmd.URL)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":  "ok",
+		"message": "Challenge solved!",
+		"solution": map[string]any{
+			"url":       cmd.URL,
+			"status":    200,
+			"headers":   map[string]string{"Content-Type": "text/html"},
+			"response":  "<html><body>solved " + u.Path + "</body></html>",
+			"userAgent": "solver-agent",
+			"cookies": []map[string]any{
+				{"name": "cf_clearance", "value": "solved", "domain": u.Hostname(), "path": "/", "expires": -1, "httpOnly": true, "secure": false},
+			},
+		},
+	})
+}
+
+func (s *stubSolver) cmds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cmds []string
+	for _, c := range s.commands {
+		cmds = append(cmds, c.Cmd+" "+c.Session)
+	}
+	return cmds
+}
+
+func TestSolver(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("cf_clearance"); err != nil || c.Value != "solved" || r.UserAgent() != "solver-agent" {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, challengeHTML)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		io.WriteString(w, "image")
+	}))
+	defer site.Close()
+	stub := &stubSolver{}
+	solver := httptest.NewServer(stub)
+	defer solver.Close()
+
+	ConfigureRequests(func(host string) RequestOptions {
+		return RequestOptions{Site: "testsite", Solver: solver.URL + "/v1"}
+	})
+	saved := cookieJar
+	cookieJar = NewCookieJar()
+	defer func() {
+		ConfigureRequests(nil)
+		cookieJar = saved
+		agentMu.Lock()
+		clear(browserAgents)
+		agentMu.Unlock()
+	}()
+
+	fetcher := EscalatingFetcher{HTTP: HTTPFetcher{}}
+	page, err := fetcher.Fetch(site.URL + "/series")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page.Body), "solved /series") || page.Header.Get("Content-Type") != "text/html" {
+		t.Errorf("Fetch() = %q %v, want the solver's page", page.Body, page.Header)
+	}
+
+	// the solved cookie and User-Agent are reused by plain HTTP
+	resp, err := NewHTTPClient().Get(site.URL + "/images/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("image request status %d, want 200 with the solver's clearance", resp.StatusCode)
+	}
+
+	CloseSolvers()
+	want := []string{"sessions.create scrape-testsite", "request.get scrape-testsite", "sessions.destroy scrape-testsite"}
+	if got := stub.cmds(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("solver commands = %q, want %q", got, want)
+	}
+}
+
+func TestSolverError(t *testing.T) {
+	stub := &stubSolver{fail: "Error solving the challenge. Timeout after 60.0 seconds."}
+	solver := httptest.NewServer(stub)
+	defer solver.Close()
+
+	s := &Solver{Endpoint: solver.URL + "/v1"}
+	_, err := s.Fetch("https://blocked.test/series")
+	if err == nil || !strings.Contains(err.Error(), "Timeout after 60.0 seconds") {
+		t.Errorf("Fetch() error = %v, want the solver's message", err)
+	}
+	if stub.commands[0].MaxTimeout != DefaultSolverTimeout.Milliseconds() {
+		t.Errorf("maxTimeout = %d, want %d", stub.commands[0].MaxTimeout, DefaultSolverTimeout.Milliseconds())
+	}
+}
@@ -2,38 +2,64 @@ package webClient
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"net/http/cookiejar"
 	"os"
+	"scrape/debugbundle"
 	"strings"
 	"time"
 )
 
-// NewHTTPClient returns a new HTTP client with a cookie jar
-func NewHTTPClient() *http.Client {
-	jar, _ := cookiejar.New(nil)
-	return &http.Client{
-		Jar: jar,
+// NewCollector returns a colly collector for the scrapers. Its requests are sent with the site's header profile, go
+// through the HTTP cache, see ConfigureCache, and are bounded by the site's timeout instead of colly's own. With --debug-dir set every page it
+// fetches is recorded for the failure bundles.
+func NewCollector(options ...func(*colly.Collector)) *colly.Collector {
+	c := colly.NewCollector(options...)
+	c.WithTransport(Transport())
+	c.SetRequestTimeout(0)
+	// the transport sends and stores the cookies, in the jar shared with the other clients and the browser
+	c.DisableCookies()
+	c.OnRequest(profileHeaders)
+
+	if debugbundle.Enabled() {
+		record := func(r *colly.Response) {
+			if strings.HasPrefix(r.Headers.Get("Content-Type"), "image/") {
+				return
+			}
+			debugbundle.Record(&debugbundle.Page{
+				URL:  r.Request.URL.String(),
+				HTML: string(r.Body),
+				Exchanges: []debugbundle.Exchange{{
+					Method:          r.Request.Method,
+					URL:             r.Request.URL.String(),
+					Status:          r.StatusCode,
+					RequestHeaders:  debugbundle.Headers(*r.Request.Headers),
+					ResponseHeaders: debugbundle.Headers(*r.Headers),
+				}},
+			})
+		}
+		c.OnResponse(record)
+		c.OnError(func(r *colly.Response, err error) {
+			if r != nil && r.Request != nil && r.Headers != nil {
+				record(r)
+			}
+		})
 	}
+
+	return c
 }
 
-// NewImageRequest creates a new HTTP GET request for an image, with common anti-bot headers
-func NewImageRequest(url string, referer string) (*http.Request, error) {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+// NewHTTPClient returns a new HTTP client, its requests go through the HTTP cache, are bounded by the site's timeout
+// and use the shared cookie jar
+func NewHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: Transport(),
 	}
-
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36")
-	req.Header.Set("Accept", "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
-	req.Header.Set("Referer", referer)
-
-	return req, nil
 }
 
 // FetchImageBytes fetches image data, checks HTTP status and HTML error pages, and retries on 5xx errors
@@ -105,8 +131,11 @@ func FetchWithBackoff(client *http.Client, req *http.Request) ([]byte, error) {
 	for {
 		resp, err := client.Do(req)
 		if err != nil {
+			if errors.Is(err, ErrNotCached) {
+				return nil, err
+			}
 			if os.IsTimeout(err) || strings.Contains(err.Error(), "Client.Timeout") {
-				log.Printf("Attempt %d: Timeout fetching %s: %v. Backing off for %v", attempt, req.URL, err, backoff)
+				slog.Warn("timeout, backing off", "url", req.URL, "attempt", attempt, "backoff", backoff, "error", err)
 				time.Sleep(backoff)
 
 				if backoff < maxBackoff {
@@ -141,12 +170,13 @@ func FetchWithBackoff(client *http.Client, req *http.Request) ([]byte, error) {
 		}
 		retriesAtMax = 0
 
-		log.Printf("Attempt %d: Successfully fetched %s", attempt, req.URL)
+		slog.Debug("fetched", "url", req.URL, "attempt", attempt)
 		return body, nil
 	}
 }
 
-// Implements an exponential backoff and logs errors when fetching the chapter page HTML
+// Implements an exponential backoff and logs errors when fetching the chapter page HTML. A challenge page is retried
+// in the browser, see NewFetcher.
 func FetchChapterPage(chapterURL string) (string, error) {
 	const (
 		initialBackoff  = 10 * time.Second
@@ -158,25 +188,19 @@ func FetchChapterPage(chapterURL string) (string, error) {
 	retriesAtMax := 0
 	attempt := 1
 
-	var pageHTML string
-
 	for {
-		c := colly.NewCollector()
-		c.SetRequestTimeout(60 * time.Second) // increase timeout for slow pages
-
-		// Capture the full HTML
-		c.OnResponse(func(r *colly.Response) {
-			pageHTML = string(r.Body)
-		})
-
-		err := c.Visit(chapterURL)
-		if err == nil && strings.TrimSpace(pageHTML) != "" {
-			log.Printf("Attempt %d: Successfully fetched chapter page %s", attempt, chapterURL)
-			return pageHTML, nil
+		page, err := NewFetcher().Fetch(chapterURL)
+		if err == nil && strings.TrimSpace(string(page.Body)) != "" {
+			slog.Debug("fetched chapter page", "url", chapterURL, "attempt", attempt)
+			return string(page.Body), nil
+		}
+		// retrying will not put the page in the cache
+		if errors.Is(err, ErrNotCached) {
+			return "", err
 		}
 
 		// Log error and backoff
-		log.Printf("Attempt %d: Failed to fetch chapter page %s: %v. Backing off %v", attempt, chapterURL, err, backoff)
+		slog.Warn("failed to fetch chapter page, backing off", "url", chapterURL, "attempt", attempt, "backoff", backoff, "error", err)
 		time.Sleep(backoff)
 
 		if backoff < maxBackoff {
@@ -194,3 +218,12 @@ func FetchChapterPage(chapterURL string) (string, error) {
 		attempt++
 	}
 }
+
+// FetchDocument fetches pageURL once, retrying a challenge page in the browser, and parses it into a goquery document
+func FetchDocument(pageURL string) (*goquery.Document, error) {
+	page, err := NewFetcher().Fetch(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	return page.Document()
+}
@@ -2,37 +2,88 @@
 package xbato
 
 import (
-	"archive/zip"
-	"context"
 	"fmt"
 	"io"
-	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"scrape/browser"
+	"scrape/debugbundle"
+	"scrape/logging"
 	"scrape/parser"
-	"slices"
+	"scrape/webClient"
 	"strings"
 
 	"github.com/chromedp/chromedp"
 	"github.com/gocolly/colly"
 )
 
+var logger = logging.Site("xbato")
+
+// MangaURL builds the series page URL from the manga shortname
+func MangaURL(mangaName string) string {
+	return fmt.Sprintf("https://xbato.com/series/%s", mangaName)
+}
+
 // get list of all the chapter URLs with detailed logging
 func XbatoChapterUrls(mangaName string) ([]string, error) {
+	return chapterUrls(MangaURL(mangaName))
+}
+
+// SeriesChapters returns the chapters listed on the series page, named from the chapter options on the first chapter
+// page in the same way as DownloadAndCreateCBZ
+func SeriesChapters(mangaURL string) ([]parser.Chapter, error) {
+	chapterURLs, err := chapterUrls(mangaURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(chapterURLs) == 0 {
+		return nil, fmt.Errorf("no chapter URLs found at %s", mangaURL)
+	}
+
+	chapterOptions, err := ChapterOptions(chapterURLs[0])
+	if err != nil {
+		return nil, err
+	}
+	formattedChapterMap := FormatChapterMap(chapterOptions)
+
+	var chapters []parser.Chapter
+	for _, url := range chapterURLs {
+		id := extractChapterID(url)
+		chapterName, ok := formattedChapterMap[id]
+		if !ok {
+			continue
+		}
+		chapters = append(chapters, parser.Chapter{
+			Number:   parser.ChapterNumber(chapterName),
+			Title:    parser.CleanText(chapterOptions[id]),
+			Filename: chapterName + ".cbz",
+			URL:      url,
+		})
+	}
+	parser.SortChapters(chapters)
+
+	return chapters, nil
+}
+
+// ChapterImages returns the page image URLs of a chapter
+func ChapterImages(chapterURL string) ([]string, error) {
+	return GetChapterImageUrls(chapterURL)
+}
+
+// scrape the chapter URLs from the series page
+func chapterUrls(mangaURL string) ([]string, error) {
 	var urls []string
 
-	mangaURL := fmt.Sprintf("https://xbato.com/series/%s", mangaName)
-	log.Printf("[xbato - XbatoChapterUrls] [INFO] Starting scraping for manga: %s", mangaURL)
+	logger.Info("scraping the chapter list", "series", mangaURL)
 
-	c := colly.NewCollector(
-		colly.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36"),
-	)
+	c := webClient.NewCollector()
 
 	// Log when the collector requests a page
 	c.OnRequest(func(r *colly.Request) {
-		log.Printf("[xbato - XbatoChapterUrls] [INFO] Visiting: %s", r.URL.String())
+		logger.Debug("visiting", "url", r.URL.String())
 	})
 
 	// Log each found chapter link
@@ -41,9 +92,9 @@ func XbatoChapterUrls(mangaName string) ([]string, error) {
 		if href != "" {
 			fullURL := "https://xbato.com" + href
 			urls = append(urls, fullURL)
-			log.Printf("[DEBUG] Found chapter URL: %s", fullURL)
+			logger.Debug("found chapter URL", "series", mangaURL, "url", fullURL)
 		} else {
-			log.Printf("[WARN] Found chapter element with empty href")
+			logger.Warn("found chapter element with empty href", "series", mangaURL)
 		}
 	})
 
@@ -51,9 +102,9 @@ func XbatoChapterUrls(mangaName string) ([]string, error) {
 	var scrapeErr error
 	c.OnError(func(resp *colly.Response, err error) {
 		if resp != nil {
-			log.Printf("[xbato - XbatoChapterUrls] [ERROR] Request to %s failed with status %d: %v", resp.Request.URL, resp.StatusCode, err)
+			logger.Error("request failed", "url", resp.Request.URL, "status", resp.StatusCode, "error", err)
 		} else {
-			log.Printf("[xbato - XbatoChapterUrls] [ERROR] Scraping error: %v", err)
+			logger.Error("scraping error", "series", mangaURL, "error", err)
 		}
 		scrapeErr = err
 	})
@@ -61,17 +112,17 @@ func XbatoChapterUrls(mangaName string) ([]string, error) {
 	// Visit the manga page
 	err := c.Visit(mangaURL)
 	if err != nil {
-		log.Printf("[xbato - XbatoChapterUrls] [ERROR] Failed to visit manga page: %v", err)
+		logger.Error("failed to visit the series page", "series", mangaURL, "error", err)
 		return nil, err
 	}
 
 	// Check if scraping encountered an error
 	if scrapeErr != nil {
-		log.Printf("[xbato - XbatoChapterUrls] [ERROR] Scraping failed: %v", scrapeErr)
+		logger.Error("scraping failed", "series", mangaURL, "error", scrapeErr)
 		return nil, scrapeErr
 	}
 
-	log.Printf("[xbato - XbatoChapterUrls] [INFO] Scraping completed, total chapters found: %d", len(urls))
+	logger.Info("chapters found", "series", mangaURL, "chapters", len(urls))
 	return urls, nil
 }
 
@@ -80,7 +131,7 @@ func XbatoChapterUrls(mangaName string) ([]string, error) {
 func ChapterOptions(chapterURL string) (map[string]string, error) {
 	chapters := make(map[string]string)
 
-	c := colly.NewCollector()
+	c := webClient.NewCollector()
 
 	c.OnHTML("optgroup[label='Chapters'] option", func(e *colly.HTMLElement) {
 		value := e.Attr("value")
@@ -107,47 +158,20 @@ func ChapterOptions(chapterURL string) (map[string]string, error) {
 	return chapters, nil
 }
 
-// Formats the resulting resulting chapter map to name the chapters in uniform format eg: ch01, ch02, ch20 etc
+// Formats the resulting resulting chapter map to name the chapters in the uniform format of parser.ChapterFilename
+// (without the .cbz extension) eg: ch001, ch002, ch020 etc. The volume is dropped, chapter numbers run on across
+// volumes.
 func FormatChapterMap(chapters map[string]string) map[string]string {
 	formatted := make(map[string]string)
 
-	// Matches volume and chapter numbers, including decimals
-	volChapRegex := regexp.MustCompile(`(?i)volume\s*(\d+)[^\d]*chapter\s*(\d+(?:\.\d+)?)`)
-	chapOnlyRegex := regexp.MustCompile(`(?i)chapter\s*(\d+(?:\.\d+)?)`)
+	// Matches the chapter number, including decimals, with or without a leading volume
+	chapterRegex := regexp.MustCompile(`(?i)chapter\s*(\d+(?:\.\d+)?)`)
 
 	for id, text := range chapters {
 		normalized := strings.ToLower(text)
 
-		if matches := volChapRegex.FindStringSubmatch(normalized); len(matches) == 3 {
-			vol := matches[1]
-			ch := matches[2]
-
-			// Pad volume
-			if len(vol) == 1 {
-				vol = "0" + vol
-			}
-
-			// Pad chapter left of dot (e.g., 3.5 → 03.5)
-			if parts := strings.Split(ch, "."); len(parts) > 1 && len(parts[0]) == 1 {
-				ch = "0" + ch
-			} else if len(ch) == 1 {
-				ch = "0" + ch
-			}
-
-			formatted[id] = fmt.Sprintf("vol%sch%s", vol, ch)
-			continue
-		}
-
-		if matches := chapOnlyRegex.FindStringSubmatch(normalized); len(matches) == 2 {
-			ch := matches[1]
-
-			if parts := strings.Split(ch, "."); len(parts) > 1 && len(parts[0]) == 1 {
-				ch = "0" + ch
-			} else if len(ch) == 1 {
-				ch = "0" + ch
-			}
-
-			formatted[id] = fmt.Sprintf("ch%s", ch)
+		if matches := chapterRegex.FindStringSubmatch(normalized); len(matches) == 2 {
+			formatted[id] = strings.TrimSuffix(parser.ChapterFilename(matches[1]), ".cbz")
 			continue
 		}
 
@@ -158,45 +182,6 @@ func FormatChapterMap(chapters map[string]string) map[string]string {
 	return formatted
 }
 
-// Creates a CBZ file
-func createCbz(sourceDir, zipName string) error {
-	// Ensure zipName ends with .cbz
-	if filepath.Ext(zipName) != ".cbz" {
-		zipName = zipName + ".cbz"
-	}
-
-	zipFile, err := os.Create(zipName)
-	if err != nil {
-		return err
-	}
-	defer zipFile.Close()
-
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
-	return filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
-		if info.IsDir() {
-			return nil
-		}
-		relPath, err := filepath.Rel(sourceDir, path)
-		if err != nil {
-			return err
-		}
-		f, err := zipWriter.Create(relPath)
-		if err != nil {
-			return err
-		}
-		fsFile, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer fsFile.Close()
-
-		_, err = io.Copy(f, fsFile)
-		return err
-	})
-}
-
 // Extract chapter ID from URL
 // Example URL: "https://xbato.com/chapter/2013166/some-chapter-title"
 // We want to extract "2013166"
@@ -215,92 +200,89 @@ func extractChapterID(chapterURL string) string {
 	return ""
 }
 
-// Download a file (chapter image)
-func downloadFile(url string, filePath string) error {
+// Download a file (chapter image) shown on chapterURL
+func downloadFile(url string, chapterURL string, filePath string) error {
+	resp, err := webClient.GetImage(url, chapterURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status: %s", resp.Status)
+	}
+
 	out, err := os.Create(filePath)
 	if err != nil {
 		return err
 	}
 	defer out.Close()
 
-	c := colly.NewCollector()
-	c.OnResponse(func(r *colly.Response) {
-		_, err = out.Write(r.Body)
-	})
-	return c.Visit(url)
+	_, err = io.Copy(out, resp.Body)
+	return err
 }
 
-func DownloadAndCreateCBZ(chapterURLs []string, chapterMap map[string]string) error {
+// DownloadChapter downloads the chapter images and creates the chapter cbz file
+func DownloadChapter(chapter parser.Chapter) error {
+	chapterName := strings.TrimSuffix(chapter.Filename, ".cbz")
+	fmt.Printf("Downloading chapter: %s\n", chapterName)
+	if chapterName == "" {
+		chapterName = "chapter"
+	}
 
-	// Get list of chapters already downloaded (only *.cbz)
-	downloadedChapters, err := parser.FileList(".")
+	// Use your GetChapterImageUrls func to get image URLs from the page
+	imgLinks, err := GetChapterImageUrls(chapter.URL)
 	if err != nil {
-		log.Fatalf("error getting file list: %v", err)
+		return fmt.Errorf("failed to get image URLs for %s: %w", chapter.URL, err)
+	}
+	if len(imgLinks) == 0 {
+		return fmt.Errorf("%s: %w", chapterName, debugbundle.NoMatch(chapter.URL, "img.page-img", "images"))
 	}
 
-	// filter out non cbz files
-	filteredCbzFiles := parser.FilterCBZFiles(downloadedChapters)
-
-	for _, url := range chapterURLs {
-		chapterName := chapterMap[extractChapterID(url)]
-
-		// dont try and download chapter if it already exists
-		if slices.Contains(filteredCbzFiles, chapterName+".cbz") {
-			continue
-		}
-
-		fmt.Println(chapterName)
-		fmt.Printf("Downloading chapter: %s\n", chapterName)
-		if chapterName == "" {
-			chapterName = "chapter"
-		}
-
-		tempDir := filepath.Join(os.TempDir(), chapterName)
-		os.MkdirAll(tempDir, os.ModePerm)
-
-		// Use your GetChapterImageUrls func to get image URLs from the page
-		imgLinks, err := GetChapterImageUrls(url)
-		if err != nil {
-			return fmt.Errorf("failed to get image URLs for %s: %w", url, err)
-		}
-
-		// Download images
-		for i, link := range imgLinks {
-			fileName := fmt.Sprintf("%03d.jpg", i+1)
-			filePath := filepath.Join(tempDir, fileName)
-			err := downloadFile(link, filePath)
-			if err != nil {
-				log.Printf("Failed downloading image: %v", err)
-				continue
-			}
-		}
+	// Staged images are kept across runs until the CBZ archive is created
+	stage, err := parser.StageChapter(chapter.URL)
+	if err != nil {
+		return err
+	}
 
-		// Create CBZ archive
-		cbzName := fmt.Sprintf("%s.cbz", chapterName)
-		err = createCbz(tempDir, cbzName)
+	// Download images, skipping the ones already staged
+	for i, link := range imgLinks {
+		fileName := parser.PageFilename(i+1, ".jpg")
+		filePath := filepath.Join(stage.Dir, fileName)
+		err := stage.Page(fileName, link, func() error {
+			return downloadFile(link, chapter.URL, filePath)
+		})
 		if err != nil {
-			return err
+			logger.Error("failed to download image", "chapter", chapter.Number, "page", i+1, "url", link, "error", err)
+			continue
 		}
+	}
 
-		// Cleanup temp images
-		os.RemoveAll(tempDir)
-
-		fmt.Printf("Created CBZ: %s\n", cbzName)
+	// Create CBZ archive
+	cbzName := fmt.Sprintf("%s.cbz", chapterName)
+	err = stage.Archive(cbzName, len(imgLinks))
+	if err != nil {
+		return err
 	}
+
+	fmt.Printf("Created CBZ: %s\n", cbzName)
 	return nil
 }
 
 // Use chromedp (headless browser) to worka round the java script BS to get the images from the page)
 func GetChapterImageUrls(url string) ([]string, error) {
-	ctx, cancel := chromedp.NewContext(context.Background())
+	ctx, cancel, err := browser.NewTab(0)
+	if err != nil {
+		return nil, err
+	}
 	defer cancel()
 
 	var attrsList []map[string]string
 	sel := `img.page-img`
 
-	err := chromedp.Run(ctx,
+	err = chromedp.Run(ctx,
 		chromedp.Navigate(url),
 		chromedp.WaitVisible(sel, chromedp.ByQuery),
+		browser.ScrollFor("xbato", sel),
 		chromedp.AttributesAll(sel, &attrsList, chromedp.ByQueryAll, chromedp.AtLeast(1)),
 	)
 	if err != nil {
@@ -316,3 +298,13 @@ func GetChapterImageUrls(url string) ([]string, error) {
 
 	return urls, nil
 }
+
+// SeriesInfo reads the title, synopsis and cover of an xbato series from the OpenGraph tags of its series page
+func SeriesInfo(seriesURL string) (*parser.SeriesInfo, error) {
+	doc, err := webClient.FetchDocument(seriesURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.OpenGraphSeriesInfo(doc, seriesURL), nil
+}
@@ -0,0 +1,44 @@
+package xbato
+
+import (
+	"path/filepath"
+	"scrape/parser"
+	"scrape/replay/replaytest"
+	"slices"
+	"testing"
+)
+
+func TestSeriesChapters(t *testing.T) {
+	replaytest.Serve(t, filepath.Join("testdata", "replay"))
+
+	chapters, err := SeriesChapters(MangaURL("123456"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// named from the chapter options of the first chapter page, the volume is dropped
+	want := []parser.Chapter{
+		{Number: "1", Title: "Vol.1 Chapter 1: Beginning", Filename: "ch001.cbz", URL: "https://xbato.com/chapter/2013166"},
+		{Number: "2", Title: "Volume 1 Chapter 2", Filename: "ch002.cbz", URL: "https://xbato.com/chapter/2013167"},
+		{Number: "11.5", Title: "Volume 2 Chapter 11.5: Side Story", Filename: "ch011.5.cbz", URL: "https://xbato.com/chapter/2013168"},
+	}
+	if !slices.EqualFunc(chapters, want, func(a, b parser.Chapter) bool {
+		return a.Number == b.Number && a.Title == b.Title && a.Filename == b.Filename && a.URL == b.URL
+	}) {
+		t.Errorf("SeriesChapters() = %+v, expected %+v", chapters, want)
+	}
+}
+
+func TestFormatChapterMap(t *testing.T) {
+	got := FormatChapterMap(map[string]string{
+		"1": "Volume 3 Chapter 25",
+		"2": "Chapter 7.5",
+		"3": "Epilogue Part 1",
+	})
+	want := map[string]string{"1": "ch025", "2": "ch007.5", "3": "epilogue_part_1"}
+	for id, name := range want {
+		if got[id] != name {
+			t.Errorf("FormatChapterMap()[%s] = %q, expected %q", id, got[id], name)
+		}
+	}
+}